@@ -0,0 +1,239 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache
+Filename    : github.com/sameeroak1110/datacache/arc.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Adaptive replacement cache (ARC) eviction policy.
+- T1 holds records hit once, T2 holds records hit more than once. B1 and B2 are
+ghost lists holding keys of records recently evicted from T1 and T2 respectively.
+A record added against a ghost key adapts the target size p of T1 in favour of the
+list the ghost belongs to.
+- Ghost of a record is its first key at the time it's added. Only evicted records
+leave ghosts behind; records removed through DeleteRec() and the like don't.
+**************************************************************************** */
+package datacache
+
+import (
+	"container/list"
+	"sync"
+)
+
+
+type arcEntry struct {
+	pElem *list.Element
+	isInT2 bool
+	ghostKey Key               // nil if the record had no key when it was added.
+}
+
+type arcGhost struct {
+	pElem *list.Element
+	isInB2 bool
+}
+
+type arcPolicy struct {
+	lock sync.Mutex
+	capacity int
+	p int                      // target size of T1.
+	pT1 *list.List             // resident records. front is the most recent one.
+	pT2 *list.List
+	pB1 *list.List             // ghost keys. front is the most recent one.
+	pB2 *list.List
+	entryMap map[*Rec]*arcEntry
+	ghostMap map[Key]*arcGhost
+	isFromB2 bool              // true if the last added record has been a B2 ghost hit.
+	pVictim *Rec               // last chosen victim. leaves a ghost behind once it's removed.
+}
+
+
+// Returns ARC eviction policy. capacity is the number of records the cache holds,
+// typically the same as WithMaxRecs().
+func NewARCPolicy(capacity int) EvictionPolicy {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	return &arcPolicy {
+		capacity: capacity,
+		pT1: list.New(),
+		pT2: list.New(),
+		pB1: list.New(),
+		pB2: list.New(),
+		entryMap: make(map[*Rec]*arcEntry),
+		ghostMap: make(map[Key]*arcGhost),
+	}
+}
+
+
+func (pPolicy *arcPolicy) removeGhost(key Key) {
+	if pGhost, isOK := pPolicy.ghostMap[key]; isOK {
+		if pGhost.isInB2 {
+			pPolicy.pB2.Remove(pGhost.pElem)
+		} else {
+			pPolicy.pB1.Remove(pGhost.pElem)
+		}
+		delete(pPolicy.ghostMap, key)
+	}
+}
+
+
+// Trims ghost lists such that |T1| + |B1| <= c and |T1| + |T2| + |B1| + |B2| <= 2c.
+func (pPolicy *arcPolicy) trimGhosts() {
+	for (pPolicy.pB1.Len() > 0) && (pPolicy.pT1.Len() + pPolicy.pB1.Len() > pPolicy.capacity) {
+		pPolicy.removeGhost(pPolicy.pB1.Back().Value)
+	}
+
+	for (pPolicy.pB2.Len() > 0) &&
+	(pPolicy.pT1.Len() + pPolicy.pT2.Len() + pPolicy.pB1.Len() + pPolicy.pB2.Len() > 2 * pPolicy.capacity) {
+		pPolicy.removeGhost(pPolicy.pB2.Back().Value)
+	}
+}
+
+
+func (pPolicy *arcPolicy) OnInsert(pRec *Rec) {
+	pPolicy.lock.Lock()
+	defer pPolicy.lock.Unlock()
+
+	if _, isOK := pPolicy.entryMap[pRec]; isOK {
+		return
+	}
+
+	pEntry := &arcEntry {}
+	if len(pRec.KeyList) > 0 {
+		pEntry.ghostKey = pRec.KeyList[0]
+	}
+
+	pPolicy.isFromB2 = false
+	pGhost, isGhost := pPolicy.ghostMap[pEntry.ghostKey]
+	if (pEntry.ghostKey != nil) && isGhost {
+		if pGhost.isInB2 {  // adapt in favour of T2.
+			delta := 1
+			if pPolicy.pB2.Len() < pPolicy.pB1.Len() {
+				delta = pPolicy.pB1.Len() / pPolicy.pB2.Len()
+			}
+			pPolicy.p = pPolicy.p - delta
+			if pPolicy.p < 0 {
+				pPolicy.p = 0
+			}
+			pPolicy.isFromB2 = true
+		} else {  // adapt in favour of T1.
+			delta := 1
+			if pPolicy.pB1.Len() < pPolicy.pB2.Len() {
+				delta = pPolicy.pB2.Len() / pPolicy.pB1.Len()
+			}
+			pPolicy.p = pPolicy.p + delta
+			if pPolicy.p > pPolicy.capacity {
+				pPolicy.p = pPolicy.capacity
+			}
+		}
+
+		pPolicy.removeGhost(pEntry.ghostKey)
+		pEntry.pElem = pPolicy.pT2.PushFront(pRec)
+		pEntry.isInT2 = true
+	} else {
+		pEntry.pElem = pPolicy.pT1.PushFront(pRec)
+	}
+
+	pPolicy.entryMap[pRec] = pEntry
+	pPolicy.trimGhosts()
+}
+
+
+func (pPolicy *arcPolicy) OnHit(pRec *Rec) {
+	pPolicy.lock.Lock()
+	defer pPolicy.lock.Unlock()
+
+	pEntry, isOK := pPolicy.entryMap[pRec]
+	if !isOK {
+		return
+	}
+
+	if pEntry.isInT2 {
+		pPolicy.pT2.MoveToFront(pEntry.pElem)
+		return
+	}
+
+	pPolicy.pT1.Remove(pEntry.pElem)
+	pEntry.pElem = pPolicy.pT2.PushFront(pRec)
+	pEntry.isInT2 = true
+}
+
+
+func (pPolicy *arcPolicy) OnDelete(pRec *Rec) {
+	pPolicy.lock.Lock()
+	defer pPolicy.lock.Unlock()
+
+	pEntry, isOK := pPolicy.entryMap[pRec]
+	if !isOK {
+		return
+	}
+
+	if pEntry.isInT2 {
+		pPolicy.pT2.Remove(pEntry.pElem)
+	} else {
+		pPolicy.pT1.Remove(pEntry.pElem)
+	}
+	delete(pPolicy.entryMap, pRec)
+
+	if (pRec != pPolicy.pVictim) || (pEntry.ghostKey == nil) {  // deleted, not evicted. leaves no ghost.
+		return
+	}
+	pPolicy.pVictim = nil
+
+	pPolicy.removeGhost(pEntry.ghostKey)
+	pGhost := &arcGhost {
+		isInB2: pEntry.isInT2,
+	}
+	if pEntry.isInT2 {
+		pGhost.pElem = pPolicy.pB2.PushFront(pEntry.ghostKey)
+	} else {
+		pGhost.pElem = pPolicy.pB1.PushFront(pEntry.ghostKey)
+	}
+	pPolicy.ghostMap[pEntry.ghostKey] = pGhost
+	pPolicy.trimGhosts()
+}
+
+
+func (pPolicy *arcPolicy) Victim(isEvictable func(*Rec) bool) *Rec {
+	pPolicy.lock.Lock()
+	defer pPolicy.lock.Unlock()
+
+	t1Len := pPolicy.pT1.Len()
+	pFirst, pSecond := pPolicy.pT2, pPolicy.pT1
+	if (t1Len > 0) && ((t1Len > pPolicy.p) || (pPolicy.isFromB2 && (t1Len == pPolicy.p))) {
+		pFirst, pSecond = pPolicy.pT1, pPolicy.pT2
+	}
+
+	pRec := victimFromBack(pFirst, isEvictable)
+	if pRec == nil {
+		pRec = victimFromBack(pSecond, isEvictable)
+	}
+	pPolicy.pVictim = pRec
+
+	return pRec
+}
+
+
+func (pPolicy *arcPolicy) Reset() {
+	pPolicy.lock.Lock()
+	defer pPolicy.lock.Unlock()
+
+	pPolicy.p = 0
+	pPolicy.pT1.Init()
+	pPolicy.pT2.Init()
+	pPolicy.pB1.Init()
+	pPolicy.pB2.Init()
+	pPolicy.entryMap = make(map[*Rec]*arcEntry)
+	pPolicy.ghostMap = make(map[Key]*arcGhost)
+	pPolicy.isFromB2 = false
+	pPolicy.pVictim = nil
+}
@@ -0,0 +1,650 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache/client
+Filename    : github.com/sameeroak1110/datacache/client/client.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Client of the datacache micro-service (see package server and cmd/datacached).
+- RemoteCache mirrors the DataCache method set. Each method additionally takes a
+context.Context and, unless the DataCache method already does, returns an error as the
+last return value. Hence, moving a cache out of process is largely a constructor change.
+- Keys are sent in the URL path and therefore are converted to strings through fmt.Sprint().
+- Connections are pooled by the underlying http.Client. Idempotent calls are retried
+on transport errors and on 429/502/503/504 responses.
+**************************************************************************** */
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sameeroak1110/datacache"
+)
+
+
+const (
+	defaultMaxRetries = 2
+	defaultRetryBackoff = 50 * time.Millisecond
+	maxIdleConnsPerHost = 64
+	maxErrorBodySize = 64 << 10
+)
+
+type Option func(*RemoteCache)
+
+type RemoteCache struct {
+	baseURL string                  // for instance, http://localhost:8080. no trailing "/".
+	cacheName string                // name the cache is registered with at the server.
+	pHTTPClient *http.Client        // shared, pooled connections.
+	timeout time.Duration           // upper limit of each call including retries. 0 means no limit apart from ctx.
+	maxRetries int                  // retries of an idempotent call.
+	retryBackoff time.Duration      // delay before the first retry. doubled with every retry.
+	newPayloadFn func() interface{} // returns a new, empty payload to decode into. optional.
+}
+
+// Rec is a record as is returned by Scan().
+type Rec struct {
+	KeyList []datacache.Key
+	PDataRec interface{}
+	IsActive bool
+}
+
+// JSON representation of a cache record. Same as that of package server.
+type recJSON struct {
+	Keys []datacache.Key       `json:"keys"`
+	Active bool                `json:"active"`
+	Payload json.RawMessage    `json:"payload"`
+}
+
+type errorJSON struct {
+	Error string    `json:"error"`
+}
+
+type countJSON struct {
+	Count int    `json:"count"`
+}
+
+type aliasJSON struct {
+	Alias string    `json:"alias"`
+}
+
+type stateJSON struct {
+	Active bool    `json:"active"`
+}
+
+
+// http.Client used for the requests. Default is a client with a pooled transport.
+func WithHTTPClient(pHTTPClient *http.Client) Option {
+	return func(pCache *RemoteCache) {
+		if pHTTPClient != nil {
+			pCache.pHTTPClient = pHTTPClient
+		}
+	}
+}
+
+
+// Upper limit of each call, including retries, on top of the deadline of ctx, if any.
+func WithTimeout(timeout time.Duration) Option {
+	return func(pCache *RemoteCache) {
+		pCache.timeout = timeout
+	}
+}
+
+
+// Number of retries of idempotent calls and delay before the first retry. 0 retries disables retrying.
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(pCache *RemoteCache) {
+		if maxRetries >= 0 {
+			pCache.maxRetries = maxRetries
+		}
+		if backoff > 0 {
+			pCache.retryBackoff = backoff
+		}
+	}
+}
+
+
+// Payloads are decoded into the payload returned by newPayload. Without it, payloads are decoded into
+// generic JSON values, i.e., map[string]interface{}, []interface{}, float64 and so on.
+func WithPayloadFactory(newPayload func() interface{}) Option {
+	return func(pCache *RemoteCache) {
+		pCache.newPayloadFn = newPayload
+	}
+}
+
+
+/* ****************************************************************************
+Description :
+Function creates remote datacache instance. No request is made to the server.
+
+Receiver    : NA
+
+Implements  : NA
+
+Arguments   :
+1> baseURL string: URL of the datacache server, for instance, http://localhost:8080.
+2> cacheName string: Name of the cache at the server.
+3> opts ...Option: Optional configuration, for instance, WithTimeout().
+
+Return value:
+1> *RemoteCache: Newly created remote datacache instance.
+2> error: Error in case baseURL isn't a valid http(s) URL or cacheName is empty.
+
+Additional note: NA
+**************************************************************************** */
+func NewRemoteCache(baseURL string, cacheName string, opts ...Option) (*RemoteCache, error) {
+	pURL, err := url.Parse(baseURL)
+	if (err != nil) || ((pURL.Scheme != "http") && (pURL.Scheme != "https")) || (pURL.Host == "") {
+		return nil, errors.New(fmt.Sprintf("Invalid datacache server URL \"%s\".", baseURL))
+	}
+
+	if cacheName == "" {
+		return nil, errors.New("Empty cache name.")
+	}
+
+	pTransport := http.DefaultTransport.(*http.Transport).Clone()
+	pTransport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+
+	pCache := &RemoteCache {
+		baseURL: strings.TrimRight(baseURL, "/"),
+		cacheName: cacheName,
+		pHTTPClient: &http.Client { Transport: pTransport },
+		maxRetries: defaultMaxRetries,
+		retryBackoff: defaultRetryBackoff,
+	}
+
+	for _, opt := range opts {
+		opt(pCache)
+	}
+
+	return pCache, nil
+}
+
+
+// Releases idle pooled connections.
+func (pCache *RemoteCache) Close() {
+	if pCache != nil {
+		pCache.pHTTPClient.CloseIdleConnections()
+	}
+}
+
+
+func (pCache *RemoteCache) cachePath() string {
+	return "/caches/" + url.PathEscape(pCache.cacheName)
+}
+
+
+func (pCache *RemoteCache) keyPath(key datacache.Key) string {
+	return pCache.cachePath() + "/keys/" + url.PathEscape(fmt.Sprint(key))
+}
+
+
+// Applies the client-wide timeout, if any, to ctx.
+func (pCache *RemoteCache) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if pCache.timeout > 0 {
+		return context.WithTimeout(ctx, pCache.timeout)
+	}
+
+	return context.WithCancel(ctx)
+}
+
+
+/* ****************************************************************************
+Description :
+Sends request to the server. Idempotent requests are retried with exponential backoff
+on transport errors and on temporary error responses.
+
+Receiver    :
+pCache *RemoteCache: Remote datacache instance.
+
+Implements  : NA
+
+Arguments   :
+1> ctx context.Context: Request context.
+2> method string: HTTP method.
+3> path string: Escaped URL path.
+4> query url.Values: URL query. Can be nil.
+5> body interface{}: Request body, encoded as JSON. nil if there's no body.
+6> isIdempotent bool: true if the request can be retried.
+
+Return value:
+1> *http.Response: 2xx response. It's caller's responsibility to close its body.
+2> error: *StatusError in case of non-2xx response, transport or context error otherwise.
+
+Additional note: NA
+**************************************************************************** */
+func (pCache *RemoteCache) do(ctx context.Context, method string, path string, query url.Values, body interface{},
+		isIdempotent bool) (*http.Response, error) {
+	var bodyBuf []byte
+
+	if body != nil {
+		var err error
+		if bodyBuf, err = json.Marshal(body); err != nil {
+			return nil, errors.New(fmt.Sprintf("Failed to encode request body: %s", err.Error()))
+		}
+	}
+
+	reqURL := pCache.baseURL + path
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
+	}
+
+	backoff := pCache.retryBackoff
+	for attempt := 0; ; attempt++ {
+		pReq, err := http.NewRequestWithContext(ctx, method, reqURL, bytes.NewReader(bodyBuf))
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			pReq.Header.Set("Content-Type", "application/json")
+		}
+
+		pResp, err := pCache.pHTTPClient.Do(pReq)
+		if err == nil {
+			if (pResp.StatusCode >= 200) && (pResp.StatusCode < 300) {
+				return pResp, nil
+			}
+			err = readStatusError(pResp)
+		}
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		var pStatusErr *StatusError
+		isRetryable := !errors.As(err, &pStatusErr) || pStatusErr.isTemporary()
+		if !isIdempotent || !isRetryable || (attempt >= pCache.maxRetries) {
+			return nil, err
+		}
+
+		select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+
+// Builds *StatusError out of an error response. Response body is closed.
+func readStatusError(pResp *http.Response) error {
+	defer pResp.Body.Close()
+
+	pErr := &StatusError {
+		StatusCode: pResp.StatusCode,
+		Code: pResp.Header.Get(errorCodeHeader),
+	}
+
+	var errResp errorJSON
+	buf, _ := io.ReadAll(io.LimitReader(pResp.Body, maxErrorBodySize))
+	if json.Unmarshal(buf, &errResp) == nil {
+		pErr.Message = errResp.Error
+	} else {
+		pErr.Message = strings.TrimSpace(string(buf))
+	}
+
+	return pErr
+}
+
+
+// Decodes JSON response body into v and closes the body.
+func decodeResp(pResp *http.Response, v interface{}) error {
+	defer pResp.Body.Close()
+
+	if err := json.NewDecoder(pResp.Body).Decode(v); err != nil {
+		return errors.New(fmt.Sprintf("Malformed response: %s", err.Error()))
+	}
+
+	return nil
+}
+
+
+// Decodes raw JSON payload into a new payload.
+func (pCache *RemoteCache) decodePayload(rawPayload json.RawMessage) (interface{}, error) {
+	if pCache.newPayloadFn != nil {
+		pPayload := pCache.newPayloadFn()
+		if err := json.Unmarshal(rawPayload, pPayload); err != nil {
+			return nil, err
+		}
+		return pPayload, nil
+	}
+
+	var payload interface{}
+	if err := json.Unmarshal(rawPayload, &payload); err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+
+// Adds the record through PUT. isForce overrides an existing record.
+func (pCache *RemoteCache) addRec(ctx context.Context, keyList []datacache.Key, pRec interface{}, isForce bool,
+		ttl time.Duration) (int, error) {
+	if pCache == nil {
+		return -1, errors.New("NULL datacache.")
+	}
+
+	if (len(keyList) == 0) || (pRec == nil) {
+		return -1, errors.New("No keys or NULL payload.")
+	}
+
+	if ttl < 0 {
+		return -1, errors.New("NoTTL isn't supported by the remote datacache.")
+	}
+
+	query := url.Values {}
+	for _, key := range keyList[1:] {
+		query.Add("alias", fmt.Sprint(key))
+	}
+	if isForce {
+		query.Set("force", "true")
+	}
+	if ttl > 0 {
+		query.Set("ttl", ttl.String())
+	}
+
+	ctx, cancel := pCache.withTimeout(ctx)
+	defer cancel()
+
+	// forced add is idempotent. plain add isn't as the retry after a lost response reports a conflict.
+	pResp, err := pCache.do(ctx, http.MethodPut, pCache.keyPath(keyList[0]), query, pRec, isForce)
+	if err != nil {
+		return -1, err
+	}
+
+	var cnt countJSON
+	if err = decodeResp(pResp, &cnt); err != nil {
+		return -1, err
+	}
+
+	return cnt.Count, nil
+}
+
+
+/* ****************************************************************************
+Description :
+Remote equivalent of DataCache.AddRec(). Payload pRec is added against all keys of keyList.
+
+Receiver    :
+pCache *RemoteCache: Remote datacache instance.
+
+Implements  : NA
+
+Arguments   :
+1> ctx context.Context: Request context.
+2> keyList []datacache.Key: List of keys that refers to the cache record payload.
+3> pRec interface{}: Record payload. Sent as JSON.
+4> recExistsErrFlag bool: If true: error matching ErrKeyExists is returned in case a record
+is found for any key.
+
+Return value:
+1> int: Number of records in the cache.
+2> error: Error in case of error.
+
+Additional note: NA
+**************************************************************************** */
+func (pCache *RemoteCache) AddRec(ctx context.Context, keyList []datacache.Key, pRec interface{}, recExistsErrFlag bool) (int, error) {
+	return pCache.addRec(ctx, keyList, pRec, !recExistsErrFlag, 0)
+}
+
+
+// Remote equivalent of DataCache.AddRecWithTTL(). NoTTL isn't supported.
+func (pCache *RemoteCache) AddRecWithTTL(ctx context.Context, keyList []datacache.Key, pRec interface{}, recExistsErrFlag bool,
+		ttl time.Duration) (int, error) {
+	return pCache.addRec(ctx, keyList, pRec, !recExistsErrFlag, ttl)
+}
+
+
+// Remote equivalent of DataCache.ForceAddRec().
+func (pCache *RemoteCache) ForceAddRec(ctx context.Context, keyList []datacache.Key, pRec interface{}) (int, error) {
+	return pCache.addRec(ctx, keyList, pRec, true, 0)
+}
+
+
+// Remote equivalent of DataCache.ForceAddRecWithTTL(). NoTTL isn't supported.
+func (pCache *RemoteCache) ForceAddRecWithTTL(ctx context.Context, keyList []datacache.Key, pRec interface{}, ttl time.Duration) (int, error) {
+	return pCache.addRec(ctx, keyList, pRec, true, ttl)
+}
+
+
+// Remote equivalent of DataCache.ReAddRec(). Error matches ErrNotFound if originalKey doesn't exist.
+func (pCache *RemoteCache) ReAddRec(ctx context.Context, originalKey datacache.Key, newKey datacache.Key) (int, error) {
+	if pCache == nil {
+		return -1, errors.New("NULL datacache.")
+	}
+
+	ctx, cancel := pCache.withTimeout(ctx)
+	defer cancel()
+
+	pResp, err := pCache.do(ctx, http.MethodPost, pCache.keyPath(originalKey) + "/aliases", nil,
+		aliasJSON { Alias: fmt.Sprint(newKey) }, false)
+	if err != nil {
+		return -1, err
+	}
+
+	var cnt countJSON
+	if err = decodeResp(pResp, &cnt); err != nil {
+		return -1, err
+	}
+
+	return cnt.Count, nil
+}
+
+
+/* ****************************************************************************
+Description :
+Remote equivalent of DataCache.GetDataRec(). Returns payload of the record referred to by key.
+
+Receiver    :
+pCache *RemoteCache: Remote datacache instance.
+
+Implements  : NA
+
+Arguments   :
+1> ctx context.Context: Request context.
+2> key datacache.Key: Key to fetch cache record.
+
+Return value:
+1> bool: true if successful. false otherwise.
+2> interface{}: Payload decoded through the payload factory. nil in case of error.
+3> error: Error matching ErrNotFound if there's no record for key. Other error otherwise.
+
+Additional note:
+- Returned payload is a copy. Unlike the in-process datacache, changing it doesn't change
+the cached record.
+**************************************************************************** */
+func (pCache *RemoteCache) GetDataRec(ctx context.Context, key datacache.Key) (bool, interface{}, error) {
+	if pCache == nil {
+		return false, nil, errors.New("NULL datacache.")
+	}
+
+	ctx, cancel := pCache.withTimeout(ctx)
+	defer cancel()
+
+	pResp, err := pCache.do(ctx, http.MethodGet, pCache.keyPath(key), nil, nil, true)
+	if err != nil {
+		return false, nil, err
+	}
+
+	var rec recJSON
+	if err = decodeResp(pResp, &rec); err != nil {
+		return false, nil, err
+	}
+
+	pDataRec, err := pCache.decodePayload(rec.Payload)
+	if err != nil {
+		return false, nil, errors.New(fmt.Sprintf("Malformed payload: %s", err.Error()))
+	}
+
+	return true, pDataRec, nil
+}
+
+
+// Remote equivalent of DataCache.DoesKeyExist(). Error matches ErrUnknownCache if the cache doesn't exist.
+func (pCache *RemoteCache) DoesKeyExist(ctx context.Context, key datacache.Key) (bool, error) {
+	if pCache == nil {
+		return false, errors.New("NULL datacache.")
+	}
+
+	ctx, cancel := pCache.withTimeout(ctx)
+	defer cancel()
+
+	pResp, err := pCache.do(ctx, http.MethodHead, pCache.keyPath(key), nil, nil, true)
+	if errors.Is(err, ErrNotFound) && !errors.Is(err, ErrUnknownCache) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	pResp.Body.Close()
+
+	return true, nil
+}
+
+
+// Remote equivalent of DataCache.GetCnt().
+func (pCache *RemoteCache) GetCnt(ctx context.Context) (bool, int, error) {
+	if pCache == nil {
+		return false, 0, errors.New("NULL datacache.")
+	}
+
+	ctx, cancel := pCache.withTimeout(ctx)
+	defer cancel()
+
+	pResp, err := pCache.do(ctx, http.MethodGet, pCache.cachePath() + "/count", nil, nil, true)
+	if err != nil {
+		return false, 0, err
+	}
+
+	var cnt countJSON
+	if err = decodeResp(pResp, &cnt); err != nil {
+		return false, 0, err
+	}
+
+	return true, cnt.Count, nil
+}
+
+
+// Remote equivalent of DataCache.DeleteRec(). Error matches ErrNotFound if key doesn't exist.
+// Isn't retried as a retry after a lost response would report ErrNotFound.
+func (pCache *RemoteCache) DeleteRec(ctx context.Context, key datacache.Key) (int, error) {
+	if pCache == nil {
+		return -1, errors.New("NULL datacache.")
+	}
+
+	ctx, cancel := pCache.withTimeout(ctx)
+	defer cancel()
+
+	pResp, err := pCache.do(ctx, http.MethodDelete, pCache.keyPath(key), nil, nil, false)
+	if err != nil {
+		return -1, err
+	}
+
+	var cnt countJSON
+	if err = decodeResp(pResp, &cnt); err != nil {
+		return -1, err
+	}
+
+	return cnt.Count, nil
+}
+
+
+// Remote equivalent of DataCache.UpdateRecState(). Error matches ErrNotFound if key doesn't exist.
+func (pCache *RemoteCache) UpdateRecState(ctx context.Context, key datacache.Key, recState bool) (bool, error) {
+	if pCache == nil {
+		return false, errors.New("NULL datacache.")
+	}
+
+	ctx, cancel := pCache.withTimeout(ctx)
+	defer cancel()
+
+	pResp, err := pCache.do(ctx, http.MethodPost, pCache.keyPath(key) + "/state", nil, stateJSON { Active: recState }, true)
+	if err != nil {
+		return false, err
+	}
+	pResp.Body.Close()
+
+	return true, nil
+}
+
+
+/* ****************************************************************************
+Description :
+Remote equivalent of DataCache.Scan(). recHandler is invoked on each record (of type *Rec)
+as it's streamed from the server. Iteration stops the moment recHandler returns false.
+
+Receiver    :
+pCache *RemoteCache: Remote datacache instance.
+
+Implements  : NA
+
+Arguments   :
+1> ctx context.Context: Request context.
+2> recHandler datacache.RecHandlerFunc: Handler function of each iterated record.
+
+Return value:
+1> bool: true if all records have been iterated, false otherwise.
+2> error: Returns cause of error.
+
+Additional note:
+- The server doesn't hold the store-lock whilst the stream is written. Hence, a slow recHandler
+doesn't block writers of the cache, however, iteration isn't a consistent snapshot: a record
+deleted meanwhile may be skipped, one added meanwhile may not be returned and one changed
+meanwhile is returned as it's when the server streams it. Stream isn't retried once it has begun.
+**************************************************************************** */
+func (pCache *RemoteCache) Scan(ctx context.Context, recHandler datacache.RecHandlerFunc) (bool, error) {
+	if pCache == nil {
+		return false, errors.New("NULL datacache.")
+	}
+
+	if recHandler == nil {
+		return false, errors.New("Nil or no cache record handler provided.")
+	}
+
+	ctx, cancel := pCache.withTimeout(ctx)
+	defer cancel()
+
+	pResp, err := pCache.do(ctx, http.MethodGet, pCache.cachePath() + "/records", nil, nil, true)
+	if err != nil {
+		return false, err
+	}
+	defer pResp.Body.Close()
+
+	pDecoder := json.NewDecoder(bufio.NewReader(pResp.Body))
+	for {
+		var rec recJSON
+		if err = pDecoder.Decode(&rec); err == io.EOF {
+			return true, nil
+		} else if err != nil {
+			return false, errors.New(fmt.Sprintf("Malformed record stream: %s", err.Error()))
+		}
+
+		pDataRec, err := pCache.decodePayload(rec.Payload)
+		if err != nil {
+			return false, errors.New(fmt.Sprintf("Malformed payload of %#v: %s", rec.Keys, err.Error()))
+		}
+
+		if !recHandler(&Rec { KeyList: rec.Keys, PDataRec: pDataRec, IsActive: rec.Active }) {
+			return false, nil
+		}
+	}
+}
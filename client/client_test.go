@@ -0,0 +1,162 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sameeroak1110/datacache"
+	"github.com/sameeroak1110/datacache/server"
+)
+
+
+// Idempotent calls are retried on temporary error responses alone; others are tried once.
+func TestRemoteCacheRetries(t *testing.T) {
+	testList := []struct {
+		name string
+		statusList []int    // status of each successive response. last one repeats.
+		call func(context.Context, *RemoteCache) error
+		attemptCnt int32
+		isErr bool
+	}{
+		{"get retried", []int{503, 502, 200}, func(ctx context.Context, pCache *RemoteCache) error {
+			_, _, err := pCache.GetCnt(ctx)
+			return err
+		}, 3, false},
+		{"get retries exhausted", []int{503}, func(ctx context.Context, pCache *RemoteCache) error {
+			_, _, err := pCache.GetCnt(ctx)
+			return err
+		}, 4, true},
+		{"not found isn't retried", []int{404}, func(ctx context.Context, pCache *RemoteCache) error {
+			_, _, err := pCache.GetDataRec(ctx, "k")
+			return err
+		}, 1, true},
+		{"delete isn't retried", []int{503, 200}, func(ctx context.Context, pCache *RemoteCache) error {
+			_, err := pCache.DeleteRec(ctx, "k")
+			return err
+		}, 1, true},
+		{"add isn't retried", []int{503, 201}, func(ctx context.Context, pCache *RemoteCache) error {
+			_, err := pCache.AddRec(ctx, []datacache.Key{"k"}, 1, true)
+			return err
+		}, 1, true},
+		{"forced add retried", []int{503, 201}, func(ctx context.Context, pCache *RemoteCache) error {
+			_, err := pCache.ForceAddRec(ctx, []datacache.Key{"k"}, 1)
+			return err
+		}, 2, false},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			var attemptCnt int32
+			pTestServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				idx := int(atomic.AddInt32(&attemptCnt, 1)) - 1
+				if idx >= len(test.statusList) {
+					idx = len(test.statusList) - 1
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(test.statusList[idx])
+				if test.statusList[idx] < 300 {
+					w.Write([]byte(`{"count":1}`))
+				} else {
+					w.Write([]byte(`{"error":"stub"}`))
+				}
+			}))
+			defer pTestServer.Close()
+
+			pCache, err := NewRemoteCache(pTestServer.URL, "c", WithRetries(3, time.Millisecond))
+			if err != nil {
+				t.Fatalf("NewRemoteCache: %v", err)
+			}
+			defer pCache.Close()
+
+			err = test.call(context.Background(), pCache)
+			if ((err != nil) != test.isErr) || (attemptCnt != test.attemptCnt) {
+				t.Fatalf("err %v after %d attempts; want error %v after %d", err, attemptCnt, test.isErr, test.attemptCnt)
+			}
+		})
+	}
+}
+
+
+type testPayload struct {
+	Name string    `json:"name"`
+}
+
+
+// Round trip against the datacache server.
+func TestRemoteCache(t *testing.T) {
+	pDataCache := datacache.Create(nil, nil)
+	defer pDataCache.Close()
+	if err := datacache.Register("client-test", pDataCache); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	defer datacache.Unregister("client-test")
+
+	pTestServer := httptest.NewServer(server.New())
+	defer pTestServer.Close()
+
+	pCache, err := NewRemoteCache(pTestServer.URL, "client-test", WithTimeout(5 * time.Second),
+		WithPayloadFactory(func() interface{} { return &testPayload{} }))
+	if err != nil {
+		t.Fatalf("NewRemoteCache: %v", err)
+	}
+	defer pCache.Close()
+	ctx := context.Background()
+
+	if cnt, err := pCache.AddRec(ctx, []datacache.Key{"k", "k/2"}, testPayload { Name: "a" }, true); (err != nil) || (cnt != 1) {
+		t.Fatalf("AddRec() = %d, %v", cnt, err)
+	}
+	if _, err = pCache.AddRec(ctx, []datacache.Key{"k"}, testPayload { Name: "b" }, true); !errors.Is(err, ErrKeyExists) {
+		t.Fatalf("AddRec() of existing key = %v, want ErrKeyExists", err)
+	}
+	if isOK, pDataRec, err := pCache.GetDataRec(ctx, "k/2"); !isOK || (err != nil) || (pDataRec.(*testPayload).Name != "a") {
+		t.Fatalf("GetDataRec(k/2) = %v, %v, %v", isOK, pDataRec, err)
+	}
+	if _, _, err = pCache.GetDataRec(ctx, "nope"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetDataRec(nope) = %v, want ErrNotFound", err)
+	}
+	if isOK, err := pCache.UpdateRecState(ctx, "k", false); !isOK || (err != nil) {
+		t.Fatalf("UpdateRecState() = %v, %v", isOK, err)
+	}
+
+	var recList []*Rec
+	isOK, err := pCache.Scan(ctx, func(pRec interface{}) bool {
+		recList = append(recList, pRec.(*Rec))
+		return true
+	})
+	if !isOK || (err != nil) || (len(recList) != 1) || recList[0].IsActive || (recList[0].PDataRec.(*testPayload).Name != "a") {
+		t.Fatalf("Scan() = %v, %v with %d records", isOK, err, len(recList))
+	}
+
+	if _, err = pCache.DeleteRec(ctx, "k"); err != nil {
+		t.Fatalf("DeleteRec: %v", err)
+	}
+	if isFound, err := pCache.DoesKeyExist(ctx, "k"); isFound || (err != nil) {
+		t.Fatalf("DoesKeyExist(k) = %v, %v after delete", isFound, err)
+	}
+}
+
+
+// An unknown cache isn't mistaken for a missing key, even by DoesKeyExist() which uses HEAD.
+func TestRemoteCacheUnknownCache(t *testing.T) {
+	pTestServer := httptest.NewServer(server.New())
+	defer pTestServer.Close()
+
+	pCache, err := NewRemoteCache(pTestServer.URL, "client-test-nope", WithTimeout(5 * time.Second))
+	if err != nil {
+		t.Fatalf("NewRemoteCache: %v", err)
+	}
+	defer pCache.Close()
+	ctx := context.Background()
+
+	if isFound, err := pCache.DoesKeyExist(ctx, "k"); isFound || !errors.Is(err, ErrUnknownCache) {
+		t.Fatalf("DoesKeyExist(k) = %v, %v; want ErrUnknownCache", isFound, err)
+	}
+	if _, _, err = pCache.GetDataRec(ctx, "k"); !errors.Is(err, ErrUnknownCache) || !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetDataRec(k) = %v, want ErrUnknownCache matching ErrNotFound", err)
+	}
+}
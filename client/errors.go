@@ -0,0 +1,79 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache/client
+Filename    : github.com/sameeroak1110/datacache/client/errors.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Typed errors of RemoteCache. Every non-2xx response is reported as *StatusError which
+matches ErrNotFound or ErrKeyExists through errors.Is() depending upon the status code.
+404 of an unknown cache additionally matches ErrUnknownCache.
+**************************************************************************** */
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+
+var (
+	ErrNotFound = errors.New("Not found.")              // cache or key doesn't exist.
+	ErrUnknownCache = errors.New("Unknown cache.")      // cache doesn't exist at the server.
+	ErrKeyExists = errors.New("Key exists.")            // record isn't added as one of its keys exists.
+)
+
+// Same as those of package server.
+const (
+	errorCodeHeader = "X-Datacache-Error"
+	errorCodeUnknownCache = "unknown-cache"
+)
+
+// StatusError is the error response of the datacache server.
+type StatusError struct {
+	StatusCode int    // HTTP status code.
+	Code string       // error code sent by the server in X-Datacache-Error header, if any.
+	Message string    // error message sent by the server.
+}
+
+
+func (pErr *StatusError) Error() string {
+	return fmt.Sprintf("datacache server: %d %s: %s", pErr.StatusCode, http.StatusText(pErr.StatusCode), pErr.Message)
+}
+
+
+// Lets errors.Is() match a *StatusError against ErrNotFound, ErrUnknownCache and ErrKeyExists.
+func (pErr *StatusError) Is(target error) bool {
+	switch target {
+		case ErrNotFound:
+			return pErr.StatusCode == http.StatusNotFound
+
+		case ErrUnknownCache:
+			return (pErr.StatusCode == http.StatusNotFound) && (pErr.Code == errorCodeUnknownCache)
+
+		case ErrKeyExists:
+			return pErr.StatusCode == http.StatusConflict
+	}
+
+	return false
+}
+
+
+// Reports whether the request resulting in the error is worth retrying.
+func (pErr *StatusError) isTemporary() bool {
+	switch pErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+	}
+
+	return false
+}
@@ -0,0 +1,137 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache/cmd/datacached
+Filename    : github.com/sameeroak1110/datacache/cmd/datacached/main.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- datacached runs datacache as a micro-service. Caches listed through -caches are created,
+registered and served over HTTP/JSON (see package server).
+- Payloads are kept verbatim as raw JSON. Prometheus metrics are served at /metrics.
+- Optionally, a cache is served over RESP as well, so that redis-cli can drive it.
+- Usage: datacached -addr :8080 -caches users,sessions -max-recs 100000 -ttl 10m
+**************************************************************************** */
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sameeroak1110/datacache"
+	"github.com/sameeroak1110/datacache/resp"
+	"github.com/sameeroak1110/datacache/server"
+)
+
+
+func main() {
+	addr := flag.String("addr", ":8080", "HTTP listen address.")
+	cacheNames := flag.String("caches", "default", "Comma separated names of caches to be served.")
+	maxRecs := flag.Int("max-recs", 0, "Maximum number of records per cache. 0 means unbounded.")
+	defaultTTL := flag.Duration("ttl", 0, "Default TTL of records. 0 means records don't expire.")
+	respAddr := flag.String("resp-addr", "", "RESP (redis protocol) listen address. Empty disables RESP.")
+	respCache := flag.String("resp-cache", "", "Cache served over RESP. Default is the first of -caches.")
+	flag.Parse()
+
+	opts := []datacache.Option {
+		datacache.WithPayloadFactory(func() interface{} { return new(json.RawMessage) }),
+	}
+	if *maxRecs > 0 {
+		opts = append(opts, datacache.WithMaxRecs(*maxRecs))
+	}
+	if *defaultTTL > 0 {
+		opts = append(opts, datacache.WithDefaultTTL(*defaultTTL))
+	}
+
+	cacheList := make([]*datacache.DataCache, 0)
+	firstName := ""
+	for _, name := range strings.Split(*cacheNames, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		pDataCache := datacache.Create(nil, nil, opts...)
+		if err := datacache.Register(name, pDataCache); err != nil {
+			fmt.Println("Failed to register cache:", err.Error())
+			os.Exit(1)
+		}
+		cacheList = append(cacheList, pDataCache)
+		if firstName == "" {
+			firstName = name
+		}
+	}
+
+	if len(cacheList) == 0 {
+		fmt.Println("No cache to serve.")
+		os.Exit(1)
+	}
+
+	pMux := http.NewServeMux()
+	pServer := server.New()
+	pMux.Handle("/caches", pServer)
+	pMux.Handle("/caches/", pServer)
+	pMux.Handle("/metrics", datacache.NewRegistryMetricsHandler())
+
+	pHTTPServer := &http.Server {
+		Addr: *addr,
+		Handler: pMux,
+	}
+
+	go func() {
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+		<-sigChan
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10 * time.Second)
+		defer cancel()
+		pHTTPServer.Shutdown(ctx)
+	}()
+
+	if *respAddr != "" {
+		if *respCache == "" {
+			*respCache = firstName
+		}
+
+		pRESPCache, isOK := datacache.Lookup(*respCache)
+		if !isOK {
+			fmt.Printf("Cache \"%s\" isn't served.\n", *respCache)
+			os.Exit(1)
+		}
+
+		pRESPServer := resp.New(pRESPCache)
+		defer pRESPServer.Close()
+
+		go func() {
+			if err := pRESPServer.ListenAndServe(*respAddr); err != resp.ErrServerClosed {
+				fmt.Println("RESP server failed:", err.Error())
+				os.Exit(1)
+			}
+		}()
+	}
+
+	fmt.Printf("Serving caches %v on %s.\n", datacache.List(), *addr)
+	if err := pHTTPServer.ListenAndServe(); (err != nil) && (err != http.ErrServerClosed) {
+		fmt.Println("HTTP server failed:", err.Error())
+		os.Exit(1)
+	}
+
+	for _, pDataCache := range cacheList {
+		pDataCache.Close()
+	}
+}
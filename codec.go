@@ -0,0 +1,123 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache
+Filename    : github.com/sameeroak1110/datacache/codec.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Pluggable codec used to serialize cache records, for instance, by snapshots.
+- Gob and JSON codecs are built in. Gob is the default.
+- Gob: concrete payload types (typically pointer types) and key types other than
+basic types must be registered through gob.Register().
+- JSON: payload is decoded into the value returned by the payload factory (see
+WithPayloadFactory()) if one is configured, into map[string]interface{} otherwise.
+- JSON: KEYS MUST BE STRINGS. JSON doesn't carry the key type, hence, a numeric key comes back
+as float64 and any other key as string or map, neither of which matches the original key.
+SaveSnapshot() and OpenWAL() reject non-string keys with JSON, and the WAL doesn't log
+mutations which involve them.
+**************************************************************************** */
+package datacache
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+
+type Encoder interface {
+	Encode(v interface{}) error
+}
+
+type Decoder interface {
+	Decode(v interface{}) error
+}
+
+type Codec interface {
+	NewEncoder(w io.Writer) Encoder
+	NewDecoder(r io.Reader) Decoder
+}
+
+type gobCodec struct{}
+type jsonCodec struct{}
+
+var (
+	GobCodec Codec = gobCodec{}
+	JSONCodec Codec = jsonCodec{}
+)
+
+
+func (gobCodec) NewEncoder(w io.Writer) Encoder {
+	return gob.NewEncoder(w)
+}
+
+
+func (gobCodec) NewDecoder(r io.Reader) Decoder {
+	return gob.NewDecoder(r)
+}
+
+
+func (jsonCodec) NewEncoder(w io.Writer) Encoder {
+	return json.NewEncoder(w)
+}
+
+
+func (jsonCodec) NewDecoder(r io.Reader) Decoder {
+	return json.NewDecoder(r)
+}
+
+
+// Returns error in case codec can't round-trip some key of keyList, i.e., for a non-string key with JSONCodec.
+func checkCodecKeys(codec Codec, keyList []Key) error {
+	if _, isOK := codec.(jsonCodec); !isOK {
+		return nil
+	}
+
+	for _, key := range keyList {
+		if _, isOK := key.(string); !isOK {
+			return errors.New(fmt.Sprintf("JSON codec supports string keys only; key %#v is %T.", key, key))
+		}
+	}
+
+	return nil
+}
+
+
+// Codec used to serialize cache records. Default is GobCodec.
+func WithCodec(codec Codec) Option {
+	return func(pDataCache *DataCache) {
+		if codec != nil {
+			pDataCache.codec = codec
+		}
+	}
+}
+
+
+// Payload factory returns a new, empty payload, typically a pointer to a zero-valued struct.
+// Codecs which don't carry type information, such as JSON, decode payloads into it.
+func WithPayloadFactory(newPayload func() interface{}) Option {
+	return func(pDataCache *DataCache) {
+		pDataCache.newPayloadFn = newPayload
+	}
+}
+
+
+// Returns a new payload through the payload factory. nil if there's no payload factory.
+// Besides the codecs, front-ends such as the HTTP server decode incoming payloads into it.
+func (pDataCache *DataCache) NewPayload() interface{} {
+	if (pDataCache == nil) || (pDataCache.newPayloadFn == nil) {
+		return nil
+	}
+
+	return pDataCache.newPayloadFn()
+}
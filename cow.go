@@ -0,0 +1,264 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache
+Filename    : github.com/sameeroak1110/datacache/cow.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Copy-on-write mode for read-mostly caches, for instance, reference data. It's turned on
+through WithCopyOnWrite().
+- The sharded store remains the master copy and is mutated just the way it's otherwise.
+Additionally, an immutable key map is published through atomic.Value. GetDataRec() and
+DoesKeyExist() read the published map and take neither store-lock nor record lock.
+- Key map changes are staged whilst the store is mutated and are published by cloning the
+current map and swapping it, once per batch. A batch is a single write method, for instance,
+a whole Load(), or, with a batch interval, all writes made in the interval.
+- Payload, TTL and state of a record are published separately, per record, each time the
+record lock taken in exclusive mode is released. Hence, they never wait for the batch.
+- Writes of a copy-on-write cache always take WR store-lock. Writes are thus costlier and
+serialized; that's the trade-off for reads.
+**************************************************************************** */
+package datacache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+
+// Published state of a record. Immutable once published.
+type cowState struct {
+	pDataRec interface{}
+	expiresAt time.Time
+	isActive bool
+	version uint64
+}
+
+// Published record. Shared by all keys of the record.
+type cowRec struct {
+	pRec *Rec
+	state atomic.Value  // *cowState
+}
+
+type cowMap map[Key]*cowRec
+
+type cowStore struct {
+	value atomic.Value             // cowMap. immutable once published.
+	lock sync.Mutex                // guards pendingMap.
+	pendingMap map[Key]*cowRec     // staged key map changes. nil value means the key has been removed.
+	batchInterval time.Duration    // 0 means each write method publishes its own batch.
+}
+
+
+func newCOWStore(batchInterval time.Duration) *cowStore {
+	pCOW := &cowStore {
+		pendingMap: make(map[Key]*cowRec),
+		batchInterval: batchInterval,
+	}
+	pCOW.value.Store(make(cowMap))
+
+	return pCOW
+}
+
+
+/* ****************************************************************************
+Description :
+Turns on copy-on-write mode. GetDataRec() and DoesKeyExist() become wait-free, as they
+don't take any lock, whereas each write takes WR store-lock and clones the key map.
+
+Receiver    : NA
+
+Implements  : NA
+
+Arguments   :
+1> batchInterval time.Duration: 0 means each write method publishes its changes to the
+key map before it returns. Otherwise, key map changes are published at most once per
+batchInterval, so that a burst of writes clones the map once.
+
+Return value:
+1> Option: Datacache option.
+
+Additional note:
+- With batchInterval > 0, a key added or removed is visible to GetDataRec() and
+DoesKeyExist() only once its batch is published. Publish() publishes it right away.
+All other methods see the change as soon as the write returns.
+- GetDataRec() of a bounded cache consults the eviction policy, which takes its own lock.
+**************************************************************************** */
+func WithCopyOnWrite(batchInterval time.Duration) Option {
+	return func(pDataCache *DataCache) {
+		if batchInterval < 0 {
+			batchInterval = 0
+		}
+		pDataCache.pCOW = newCOWStore(batchInterval)
+	}
+}
+
+
+func newCOWRec(pRec *Rec) *cowRec {
+	return &cowRec {
+		pRec: pRec,
+	}
+}
+
+
+// Publishes payload, TTL, state and version of the record. Must be invoked whilst the record can't change, i.e.,
+// either in the exclusive record lock or before the record is added in the store.
+func (pCOWRec *cowRec) publish(pRec *Rec) {
+	pCOWRec.state.Store(&cowState {
+		pDataRec: pRec.PDataRec,
+		expiresAt: pRec.expiresAt,
+		isActive: pRec.isActive,
+		version: pRec.version,
+	})
+}
+
+
+// Publishes payload, TTL and state of the record in copy-on-write mode. It's a no-op otherwise.
+func (pRec *Rec) publishCOW() {
+	if pRec.pCOWRec != nil {
+		pRec.pCOWRec.publish(pRec)
+	}
+}
+
+
+// Stages association of key to pRec, or disassociation of key if pRec is nil.
+// Must be invoked in WR store-lock.
+func (pCOW *cowStore) stage(key Key, pRec *Rec) {
+	if pCOW == nil {
+		return
+	}
+
+	var pCOWRec *cowRec
+	if pRec != nil {
+		pCOWRec = pRec.pCOWRec
+	}
+
+	pCOW.lock.Lock()
+	pCOW.pendingMap[key] = pCOWRec
+	pCOW.lock.Unlock()
+}
+
+
+// Clones the published key map, applies staged changes to it and publishes it.
+func (pCOW *cowStore) publish() {
+	pCOW.lock.Lock()
+	defer pCOW.lock.Unlock()
+
+	if len(pCOW.pendingMap) == 0 {
+		return
+	}
+
+	curMap := pCOW.value.Load().(cowMap)
+	freshMap := make(cowMap, len(curMap) + len(pCOW.pendingMap))
+	for key, pCOWRec := range curMap {
+		freshMap[key] = pCOWRec
+	}
+	for key, pCOWRec := range pCOW.pendingMap {
+		if pCOWRec == nil {
+			delete(freshMap, key)
+		} else {
+			freshMap[key] = pCOWRec
+		}
+	}
+
+	pCOW.value.Store(freshMap)
+	pCOW.pendingMap = make(map[Key]*cowRec)
+}
+
+
+// Rebuilds the key map from the store and publishes it right away. Staged changes are discarded as the store
+// already reflects them. Used once the store is replaced or cleared in entirety, for instance, by Reload().
+// Must be invoked in WR store-lock.
+func (pDataCache *DataCache) resetCOWWOLock() {
+	pCOW := pDataCache.pCOW
+	if pCOW == nil {
+		return
+	}
+
+	freshMap := make(cowMap, pDataCache.keyCntWOLock())
+	for _, pShard := range pDataCache.shards {
+		for key, pRec := range pShard.cache {
+			freshMap[key] = pRec.pCOWRec
+		}
+	}
+
+	pCOW.lock.Lock()
+	pCOW.value.Store(freshMap)
+	pCOW.pendingMap = make(map[Key]*cowRec)
+	pCOW.lock.Unlock()
+}
+
+
+// Publishes the staged key map changes of a copy-on-write cache right away, irrespective of the batch
+// interval. It's a no-op otherwise. Publish() shouldn't be invoked in WR store-lock.
+func (pDataCache *DataCache) Publish() {
+	if (pDataCache == nil) || (pDataCache.pCOW == nil) {
+		return
+	}
+
+	pDataCache.cacheLock.RLock()  // a write method which is halfway through isn't published.
+	pDataCache.pCOW.publish()
+	pDataCache.cacheLock.RUnlock()
+}
+
+
+// Publisher go-routine of a copy-on-write cache with batch interval. Publishes staged changes
+// once per interval until Close() is invoked.
+func (pDataCache *DataCache) cowPublisher() {
+	ticker := time.NewTicker(pDataCache.pCOW.batchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-pDataCache.pStopChan:
+			pDataCache.Publish()
+			return
+
+		case <-ticker.C:
+			pDataCache.Publish()
+		}
+	}
+}
+
+
+// Returns published state of the record referred to by key. nil if key isn't found or the record has expired.
+// Takes no lock.
+func (pDataCache *DataCache) lookupCOW(key Key) (*cowRec, *cowState) {
+	pCOWRec, isOK := pDataCache.pCOW.value.Load().(cowMap)[key]
+	if !isOK {
+		return nil, nil
+	}
+
+	pState := pCOWRec.state.Load().(*cowState)
+	if !pState.expiresAt.IsZero() && !time.Now().Before(pState.expiresAt) {
+		return nil, nil
+	}
+
+	return pCOWRec, pState
+}
+
+
+// GetDataRec() of a copy-on-write cache.
+func (pDataCache *DataCache) getDataRecCOW(key Key) (bool, interface{}) {
+	pCOWRec, pState := pDataCache.lookupCOW(key)
+	if pState == nil {
+		pDataCache.pCounters.miss()
+		return false, nil
+	}
+
+	pDataCache.pCounters.hit()
+	if pDataCache.policy != nil {
+		pDataCache.policy.OnHit(pCOWRec.pRec)
+	}
+
+	return true, pState.pDataRec
+}
@@ -0,0 +1,146 @@
+package datacache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+
+// Adds and deletes of a copy-on-write cache become visible to GetDataRec() and DoesKeyExist() before
+// the write returns without batch interval, and once the batch is published with it.
+func TestCOWVisibility(t *testing.T) {
+	testList := []struct {
+		name string
+		batchInterval time.Duration
+		publish func(*DataCache)
+	}{
+		{"no batch", 0, func(*DataCache) {}},
+		{"batch, Publish()", time.Hour, func(pDataCache *DataCache) { pDataCache.Publish() }},
+		{"batch, interval", 10 * time.Millisecond, func(*DataCache) { time.Sleep(50 * time.Millisecond) }},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			pDataCache := Create(nil, nil, WithCopyOnWrite(test.batchInterval))
+			defer pDataCache.Close()
+
+			if _, err := pDataCache.AddRec([]Key{"a", "b"}, "v1", true); err != nil {
+				t.Fatalf("AddRec: %v", err)
+			}
+			if test.batchInterval == time.Hour {
+				if pDataCache.DoesKeyExist("a") {
+					t.Fatalf("key is visible before the batch is published")
+				}
+			}
+
+			test.publish(pDataCache)
+			for _, key := range []Key{"a", "b"} {
+				if isOK, pDataRec := pDataCache.GetDataRec(key); !isOK || (pDataRec != "v1") {
+					t.Fatalf("GetDataRec(%v) = %v, %v after add", key, isOK, pDataRec)
+				}
+				if !pDataCache.DoesKeyExist(key) {
+					t.Fatalf("DoesKeyExist(%v) = false after add", key)
+				}
+			}
+
+			// payload is published per record; it doesn't wait for the batch.
+			if _, err := pDataCache.Update("a", func(interface{}) (interface{}, error) { return "v2", nil }); err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+			if _, pDataRec := pDataCache.GetDataRec("b"); pDataRec != "v2" {
+				t.Fatalf("GetDataRec(b) = %v after Update, want v2", pDataRec)
+			}
+
+			if _, err := pDataCache.DeleteRec("a"); err != nil {
+				t.Fatalf("DeleteRec: %v", err)
+			}
+			test.publish(pDataCache)
+			for _, key := range []Key{"a", "b"} {
+				if isOK, _ := pDataCache.GetDataRec(key); isOK {
+					t.Fatalf("GetDataRec(%v) found the deleted record", key)
+				}
+				if pDataCache.DoesKeyExist(key) {
+					t.Fatalf("DoesKeyExist(%v) = true after delete", key)
+				}
+			}
+		})
+	}
+}
+
+
+// Creates a cache of recCnt records with keys 0 to recCnt-1. Records are loaded through Load(), which is a single batch.
+func newBenchCache(recCnt int, opts ...Option) *DataCache {
+	loadFunc := func() ([]Payload, error) {
+		payloadList := make([]Payload, recCnt)
+		for i := range payloadList {
+			payloadList[i] = Payload { KeyList: []Key{i}, PDataRec: i }
+		}
+		return payloadList, nil
+	}
+
+	pDataCache := Create(loadFunc, nil, opts...)
+	pDataCache.Load(true)
+
+	return pDataCache
+}
+
+
+// Parallel readers of copy-on-write cache against those of the sharded store, which takes RD shard-lock
+// and the shared record lock.
+func BenchmarkGetDataRec(b *testing.B) {
+	const recCnt = 10000
+
+	testList := []struct {
+		name string
+		opts []Option
+	}{
+		{"sharded RWMutex", []Option{WithShards(16)}},
+		{"copy-on-write", []Option{WithCopyOnWrite(0)}},
+	}
+
+	for _, test := range testList {
+		b.Run(test.name, func(b *testing.B) {
+			pDataCache := newBenchCache(recCnt, test.opts...)
+			defer pDataCache.Close()
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					pDataCache.GetDataRec(i % recCnt)
+					i++
+				}
+			})
+		})
+	}
+}
+
+
+// Cost of a write to copy-on-write cache, which clones the key map once per batch. Without batch
+// interval, each write clones it.
+func BenchmarkCOWWrite(b *testing.B) {
+	for _, recCnt := range []int{100, 10000} {
+		for _, batchInterval := range []time.Duration{0, 10 * time.Millisecond} {
+			b.Run(fmt.Sprintf("recs=%d/batch=%v", recCnt, batchInterval), func(b *testing.B) {
+				pDataCache := newBenchCache(recCnt, WithCopyOnWrite(batchInterval))
+				defer pDataCache.Close()
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					pDataCache.ForceAddRec([]Key{recCnt + (i % recCnt)}, i)
+				}
+			})
+		}
+	}
+
+	b.Run("sharded RWMutex", func(b *testing.B) {
+		pDataCache := newBenchCache(10000, WithShards(16))
+		defer pDataCache.Close()
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			pDataCache.ForceAddRec([]Key{10000 + (i % 10000)}, i)
+		}
+	})
+}
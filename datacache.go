@@ -21,8 +21,9 @@ import (
 	"fmt"
 	"sync"
 	"errors"
-	"reflect"
 	"runtime/debug"
+	"sync/atomic"
+	"time"
 )
 
 const mutexLocked = 1
@@ -46,7 +47,8 @@ inclusive for other read store operations.
 ***************************************************************************** */
 func (pDataCache *DataCache) ReadLock() {
 	if pDataCache != nil {
-		pDataCache.cacheLock.RLock()
+		pDataCache.rlockStore()
+		pDataCache.pLockTracker.locked(nil, lockKindStoreRead)
 	}
 }
 
@@ -67,7 +69,9 @@ pDataCache. An attempt to unlock an already unlocked cache store results into pa
 ***************************************************************************** */
 func (pDataCache *DataCache) ReadUnlock() {
 	if pDataCache != nil {
-		pDataCache.cacheLock.RUnlock()
+		pDataCache.pLockTracker.unlocked(nil, lockKindStoreRead)
+		pDataCache.runlockStore()
+		pDataCache.dispatchEvents()  // raised by WOLock methods invoked in the lock.
 	}
 }
 
@@ -90,6 +94,7 @@ Write store-lock is mutually exclusive against any other store-lock.
 func (pDataCache *DataCache) WriteLock() {
 	if pDataCache != nil {
 		pDataCache.cacheLock.Lock()
+		pDataCache.pLockTracker.locked(nil, lockKindStoreWrite)
 	}
 }
 
@@ -110,7 +115,8 @@ pDataCache. An attempt to unlock an already unlocked cache store results into pa
 ***************************************************************************** */
 func (pDataCache *DataCache) WriteUnlock() {
 	if pDataCache != nil {
-		pDataCache.cacheLock.Unlock()
+		pDataCache.pLockTracker.unlocked(nil, lockKindStoreWrite)
+		pDataCache.unlockStore()
 	}
 }
 
@@ -132,7 +138,7 @@ Additional note: NA
 ***************************************************************************** */
 func (pRec *Rec) RecLock() {
 	if (pRec != nil) && (pRec.pRecLock != nil) {
-		pRec.pRecLock.Lock()
+		pRec.lock()
 	}
 }
 
@@ -148,7 +154,7 @@ func (pRec *Rec) RecUnlock_1() {
     if (pRec != nil) && (pRec.pRecLock != nil) {
 		pRec.pUnlockRecLock.Lock()
 		if pRec != nil {
-			pRec.pRecLock.Unlock()
+			pRec.unlock()
 			pRec = nil
 			pRec.pUnlockRecLock.Unlock()
 		}
@@ -182,11 +188,10 @@ func RecUnlock(pRec *Rec) {
 		fmt.Println("Attempting to unlock record.")
 		if pRec.pRecLock != nil {
 			pRec.pUnlockRecLock.Lock()
-			state := reflect.ValueOf(pRec.pRecLock).Elem().FieldByName("state")
-			isLocked := (state.Int() & 1) == 1
-			msg := "Record is already in unlocked state."
-			if isLocked {
-				pRec.pRecLock.Unlock()
+			// only a record held in exclusive mode is unlocked. RWMutex.Unlock() of a record that's unlocked
+			// or held in shared mode, for instance, by GetDataRec(), is a fatal error that recover() can't catch.
+			msg := "Record isn't locked in exclusive mode."
+			if pRec.unlockIfHeld() {
 				msg = "Record has been unlocked."
 			}
 			fmt.Println(msg)
@@ -196,15 +201,180 @@ func RecUnlock(pRec *Rec) {
 	}
 }
 
+// Reports whether the record is active. Caller should hold the record lock.
+func (pRec *Rec) IsActive() bool {
+	if pRec == nil {
+		return false
+	}
+
+	return pRec.isActive
+}
+
 // Unlocks locked datacache record.
 func (pRec *Rec) DataCacheRecUnlock() {
 	if (pRec != nil) && (pRec.pRecLock != nil) {
-		pRec.pRecLock.Unlock()
+		pRec.unlock()
 		pRec = nil
 	}
 }
 
 
+// Creates a new unlocked datacache record. ttl is resolved against the cache-wide default TTL.
+// Janitor go-routine is started with the first record which expires.
+func (pDataCache *DataCache) newRec(keyList []Key, pDataRec interface{}, ttl time.Duration) *Rec {
+	pDataCacheRec := &Rec {
+		PDataRec: pDataRec,
+		KeyList: append([]Key(nil), keyList...),  // record owns its key list. ReAddRec() appends to it.
+		isActive: true,
+		version: nextRecVersion(),
+	}
+	pDataCacheRec.pRecLock = &sync.RWMutex{}
+	pDataCacheRec.pUnlockRecLock = &sync.Mutex{}
+	pDataCacheRec.pLockTracker = pDataCache.pLockTracker
+	if pDataCache.pCOW != nil {
+		pDataCacheRec.pCOWRec = newCOWRec(pDataCacheRec)
+	}
+
+	if ttl == 0 {
+		ttl = pDataCache.defaultTTL
+	}
+	if ttl > 0 {
+		pDataCacheRec.expiresAt = time.Now().Add(ttl)
+		pDataCache.startJanitor()
+	}
+
+	return pDataCacheRec
+}
+
+
+// Associates all keys of pRec to pRec. A key which is already associated to some other record is
+// disassociated from the latter first. Capacity of the cache is enforced once pRec is added, however,
+// pRec by itself is never evicted here.
+// Must be invoked in WR store-lock or in the locks taken by lockKeys() for keys of pRec.
+func (pDataCache *DataCache) storeRecWOLock(pRec *Rec) {
+	pRec.publishCOW()  // before any of its keys is published.
+
+	isOverwrite := false
+	var pOldDataRec interface{}
+	for _, key := range pRec.KeyList {
+		if pOldRec, isOK := pDataCache.lookupWOLock(key); isOK && (pOldRec != pRec) {
+			if !isOverwrite {
+				pOldDataRec = pOldRec.PDataRec
+			}
+			pDataCache.detachKeyWOLock(pOldRec, key, EventReplace)
+			isOverwrite = true
+		}
+		pDataCache.mapKeyWOLock(key, pRec)
+	}
+	pDataCache.pWAL.logAdd(pRec)
+	pDataCache.pCounters.insert(isOverwrite)
+	cause := EventInsert
+	if isOverwrite {
+		cause = EventReplace
+	}
+	pDataCache.raiseEvent(cause, pRec.KeyList, pOldDataRec, pRec.PDataRec, pRec.isActive)
+	pDataCache.raiseHook(hookInsert, cause, pRec.KeyList, pOldDataRec, pRec.PDataRec, pRec.isActive)
+
+	pDataCache.addRecCnt(1)
+	if pDataCache.sizefn != nil {
+		pRec.size = pDataCache.sizefn(pRec.PDataRec)
+		pDataCache.addBytes(pRec.size)
+	}
+
+	if pDataCache.policy != nil {
+		pDataCache.policy.OnInsert(pRec)
+	}
+	pDataCache.evictWOLock(pRec)
+}
+
+
+// Removes pRec from the cache in entirety, i.e., all its keys are disassociated. A key which has since
+// been associated to some other record is left as is. cause is that of the change event.
+// Must be invoked in WR store-lock or in the locks taken by lockKeys() for a key of pRec.
+func (pDataCache *DataCache) removeRecWOLock(pRec *Rec, cause EventCause) {
+	if len(pRec.KeyList) > 0 {
+		pDataCache.pWAL.logKeyOp(walOpDeleteRec, pRec.KeyList[0], nil)
+	}
+
+	for _, key := range pRec.KeyList {
+		if pTmpRec, isOK := pDataCache.lookupWOLock(key); isOK && (pTmpRec == pRec) {
+			pDataCache.unmapKeyWOLock(key)
+		}
+	}
+
+	pDataCache.addRecCnt(-1)
+	pDataCache.addBytes(-pRec.size)
+	if pDataCache.policy != nil {
+		pDataCache.policy.OnDelete(pRec)
+	}
+	pDataCache.raiseEvent(cause, pRec.KeyList, pRec.PDataRec, nil, pRec.isActive)
+	if cause == EventEvict {
+		pDataCache.raiseHook(hookEvict, cause, pRec.KeyList, pRec.PDataRec, nil, pRec.isActive)
+	} else {
+		pDataCache.raiseHook(hookDelete, cause, pRec.KeyList, pRec.PDataRec, nil, pRec.isActive)
+	}
+}
+
+
+// Disassociates a single key from pRec. pRec is removed from the cache once its last key is disassociated.
+// cause is that of OnDelete() hook in that case.
+// Must be invoked in WR store-lock or in the locks taken by lockKeys() for key.
+func (pDataCache *DataCache) detachKeyWOLock(pRec *Rec, key Key, cause EventCause) {
+	if pTmpRec, isOK := pDataCache.lookupWOLock(key); isOK && (pTmpRec == pRec) {
+		pDataCache.unmapKeyWOLock(key)
+	}
+
+	tmpKeyList := make([]Key, 0, len(pRec.KeyList))
+	for _, tmpKey := range pRec.KeyList {
+		if tmpKey != key {
+			tmpKeyList = append(tmpKeyList, tmpKey)
+		}
+	}
+	pRec.KeyList = tmpKeyList
+
+	if len(tmpKeyList) == 0 {
+		pDataCache.addRecCnt(-1)
+		pDataCache.addBytes(-pRec.size)
+		if pDataCache.policy != nil {
+			pDataCache.policy.OnDelete(pRec)
+		}
+		pDataCache.raiseHook(hookDelete, cause, []Key{key}, pRec.PDataRec, nil, pRec.isActive)
+	}
+}
+
+
+// Associates newKey to pRec in addition to its existing keys. newKey is disassociated from the record
+// it's been associated to, if any.
+// Must be invoked in WR store-lock or in the locks taken by lockKeys() for a key of pRec and newKey.
+func (pDataCache *DataCache) aliasRecWOLock(pRec *Rec, newKey Key) {
+	if pOldRec, isOK := pDataCache.lookupWOLock(newKey); isOK {
+		if pOldRec == pRec {
+			return
+		}
+		pDataCache.detachKeyWOLock(pOldRec, newKey, EventAlias)
+	}
+
+	if len(pRec.KeyList) > 0 {
+		pDataCache.pWAL.logKeyOp(walOpAlias, pRec.KeyList[0], newKey)
+	}
+	pRec.KeyList = append(pRec.KeyList, newKey)
+	pDataCache.mapKeyWOLock(newKey, pRec)
+	pDataCache.raiseEvent(EventAlias, pRec.KeyList, nil, pRec.PDataRec, pRec.isActive)
+}
+
+
+// Resets record accounting once all records have been removed.
+// Must be invoked in WR store-lock.
+func (pDataCache *DataCache) resetCntWOLock() {
+	pDataCache.pWAL.logClear()
+	atomic.StoreInt64(&pDataCache.cnt, 0)
+	atomic.StoreInt64(&pDataCache.bytes, 0)
+	if pDataCache.policy != nil {
+		pDataCache.policy.Reset()
+	}
+}
+
+
 
 // Following 2 functions, one with WR store-lock and other without WR store-lock are going to add a record in the cache.
 // For the one without WR store-lock, it's the caller's prerogative to take appropriate lock and release the same once done.
@@ -238,6 +408,38 @@ This method takes WR store-lock and releases the same while returning.
 Therefore, caller shouldn't take any lock before calling this method.
 ***************************************************************************** */
 func (pDataCache *DataCache) AddRec(keyList []Key, pRec interface{}, recExistsErrFlag bool) (int, error) {
+	return pDataCache.AddRecWithTTL(keyList, pRec, recExistsErrFlag, 0)
+}
+
+
+/* *****************************************************************************
+Description :
+Same as AddRec. Additionally, the record expires once ttl has elapsed. Expired record
+isn't visible to GetRec(), GetDataRec() and DoesKeyExist() and is reclaimed by the
+janitor go-routine.
+
+Receiver    :
+pDataCache *DataCache: Datacache instance.
+
+Implements  : NA
+
+Arguments   :
+1> keyList []Key: List of keys that refers to the cache record payload.
+2> pRec interface{}: Record payload.
+3> recExistsErrFlag bool: If true: error is returned in case a record is found for any key.
+If false, record is added forcefully.
+4> ttl time.Duration: Time to live of the record. 0 means cache-wide default TTL.
+NoTTL means the record never expires.
+
+Return value:
+1> int: Number of records in the cache.
+2> error: Error string in case of error.
+
+Additional note:
+This method takes WR store-lock and releases the same while returning.
+Therefore, caller shouldn't take any lock before calling this method.
+***************************************************************************** */
+func (pDataCache *DataCache) AddRecWithTTL(keyList []Key, pRec interface{}, recExistsErrFlag bool, ttl time.Duration) (int, error) {
 	var err error
 
 	if (pDataCache == nil) || (pRec == nil) {
@@ -245,33 +447,23 @@ func (pDataCache *DataCache) AddRec(keyList []Key, pRec interface{}, recExistsEr
 		return -1, err
 	}
 
-	pDataCache.cacheLock.Lock()
-	defer pDataCache.cacheLock.Unlock()
+	unlock := pDataCache.lockKeys(keyList)
+	defer unlock()
 
 	if recExistsErrFlag {
 		for i, _ := range keyList {
-			if _, isOK := pDataCache.cache[keyList[i]]; isOK {
+			if pTmpRec, isOK := pDataCache.lookupWOLock(keyList[i]); isOK && !pTmpRec.isExpired(time.Now()) {
 				err = errors.New(fmt.Sprintf("Key \"%s\" exists.", keyList[i]))
 				return -1, err  // record exists. therefore, record isn't added.
 			}
 		}
 	}
 
-	pDataCacheRec := &Rec {
-		PDataRec: pRec,
-		KeyList: keyList,
-		isActive: true,
-	}
-	pDataCacheRec.pRecLock = &sync.Mutex{}
-	pDataCacheRec.pUnlockRecLock = &sync.Mutex{}
-
-	for i, _ := range keyList {
-		pDataCache.cache[keyList[i]] = pDataCacheRec
-	}
+	pDataCacheRec := pDataCache.newRec(keyList, pRec, ttl)
 
-	pDataCache.cnt = pDataCache.cnt + 1
+	pDataCache.storeRecWOLock(pDataCacheRec)
 
-	return pDataCache.cnt, nil
+	return pDataCache.recCnt(), nil
 }
 
 
@@ -303,6 +495,13 @@ This method takes WR store-lock and releases the same while returning.
 Therefore, caller shouldn't take any lock before calling this method.
 ***************************************************************************** */
 func (pDataCache *DataCache) ForceAddRec(keyList []Key, pRec interface{}) (int, error) {
+	return pDataCache.ForceAddRecWithTTL(keyList, pRec, 0)
+}
+
+
+// Same as ForceAddRec. Additionally, the record expires once ttl has elapsed. 0 means cache-wide default TTL.
+// NoTTL means the record never expires.
+func (pDataCache *DataCache) ForceAddRecWithTTL(keyList []Key, pRec interface{}, ttl time.Duration) (int, error) {
 	var err error
 
 	if (pDataCache == nil) || (pRec == nil) {
@@ -310,30 +509,14 @@ func (pDataCache *DataCache) ForceAddRec(keyList []Key, pRec interface{}) (int,
 		return -1, err
 	}
 
-	pDataCache.cacheLock.Lock()
-	defer pDataCache.cacheLock.Unlock()
-
-	for i, _ := range keyList {
-		if _, isOK := pDataCache.cache[keyList[i]]; isOK {
-			delete(pDataCache.cache, i)
-		}
-	}
-
-	pDataCacheRec := &Rec {
-		PDataRec: pRec,
-		KeyList: keyList,
-		isActive: true,
-	}
-	pDataCacheRec.pRecLock = &sync.Mutex{}
-	pDataCacheRec.pUnlockRecLock = &sync.Mutex{}
+	unlock := pDataCache.lockKeys(keyList)
+	defer unlock()
 
-	for i, _ := range keyList {
-		pDataCache.cache[keyList[i]] = pDataCacheRec
-	}
+	pDataCacheRec := pDataCache.newRec(keyList, pRec, ttl)
 
-	pDataCache.cnt = pDataCache.cnt + 1
+	pDataCache.storeRecWOLock(pDataCacheRec)
 
-	return pDataCache.cnt, nil
+	return pDataCache.recCnt(), nil
 }
 
 
@@ -346,36 +529,26 @@ func (pDataCache *DataCache) AddAndGetRec(keyList []Key, pRec interface{}, recEx
 		return -1, nil, err
 	}
 
-	pDataCache.cacheLock.Lock()
-	defer pDataCache.cacheLock.Unlock()
+	unlock := pDataCache.lockKeys(keyList)
+	defer unlock()
 
 	if recExistsErrFlag {
 		for i, _ := range keyList {
-			if _, isOK := pDataCache.cache[keyList[i]]; isOK {
+			if pTmpRec, isOK := pDataCache.lookupWOLock(keyList[i]); isOK && !pTmpRec.isExpired(time.Now()) {
 				err = errors.New(fmt.Sprintf("Key \"%s\" exists.", keyList[i]))
 				return -1, nil, err  // record exists. therefore, record isn't added.
 			}
 		}
 	}
 
-	pDataCacheRec := &Rec {
-		PDataRec: pRec,
-		KeyList: keyList,
-		isActive: true,
-	}
-	pDataCacheRec.pRecLock = &sync.Mutex{}
-	pDataCacheRec.pUnlockRecLock = &sync.Mutex{}
+	pDataCacheRec := pDataCache.newRec(keyList, pRec, 0)
 
-	for i, _ := range keyList {
-		pDataCache.cache[keyList[i]] = pDataCacheRec
-	}
+	pDataCache.storeRecWOLock(pDataCacheRec)
 
-	pDataCache.cnt = pDataCache.cnt + 1
+	prec, _ := pDataCache.lookupWOLock(keyList[0])
+	prec.lock()    // record is locked
 
-	prec, _ := pDataCache.cache[keyList[0]]
-	prec.pRecLock.Lock()    // record is locked
-
-	return pDataCache.cnt, prec, nil
+	return pDataCache.recCnt(), prec, nil
 }
 
 
@@ -388,33 +561,17 @@ func (pDataCache *DataCache) ForceAddAndGetRec(keyList []Key, pRec interface{})
 		return -1, nil, err
 	}
 
-	pDataCache.cacheLock.Lock()
-	defer pDataCache.cacheLock.Unlock()
+	unlock := pDataCache.lockKeys(keyList)
+	defer unlock()
 
-	for i, _ := range keyList {
-		if _, isOK := pDataCache.cache[keyList[i]]; isOK {
-			delete(pDataCache.cache, i)
-		}
-	}
+	pDataCacheRec := pDataCache.newRec(keyList, pRec, 0)
 
-	pDataCacheRec := &Rec {
-		PDataRec: pRec,
-		KeyList: keyList,
-		isActive: true,
-	}
-	pDataCacheRec.pRecLock = &sync.Mutex{}
-	pDataCacheRec.pUnlockRecLock = &sync.Mutex{}
+	pDataCache.storeRecWOLock(pDataCacheRec)
 
-	for i, _ := range keyList {
-		pDataCache.cache[keyList[i]] = pDataCacheRec
-	}
-
-	pDataCache.cnt = pDataCache.cnt + 1
+	prec, _ := pDataCache.lookupWOLock(keyList[0])
+	prec.lock()    // record is locked
 
-	prec, _ := pDataCache.cache[keyList[0]]
-	prec.pRecLock.Lock()    // record is locked
-
-	return pDataCache.cnt, prec, nil
+	return pDataCache.recCnt(), prec, nil
 }
 
 
@@ -447,13 +604,12 @@ func (pDataCache *DataCache) ReAddRec(originalKey Key, newKey Key) (int, error)
 		return -1, err
 	}
 
-	pDataCache.cacheLock.Lock()
-	defer pDataCache.cacheLock.Unlock()
+	unlock := pDataCache.lockKeys([]Key{originalKey, newKey})
+	defer unlock()
 
 	flag := false
-	if pRec, isOK := pDataCache.cache[originalKey]; isOK {
-		pRec.KeyList = append(pRec.KeyList, newKey)
-		pDataCache.cache[newKey] = pRec
+	if pRec, isOK := pDataCache.lookupWOLock(originalKey); isOK {
+		pDataCache.aliasRecWOLock(pRec, newKey)
 		flag = true
 	}
 
@@ -462,7 +618,7 @@ func (pDataCache *DataCache) ReAddRec(originalKey Key, newKey Key) (int, error)
 		return -1, err
 	}
 
-	return pDataCache.cnt, nil
+	return pDataCache.recCnt(), nil
 }
 
 
@@ -475,13 +631,12 @@ func (pDataCache *DataCache) ReAddAndGetRec(originalKey Key, newKey Key) (int, *
 		return -1, nil, err
 	}
 
-	pDataCache.cacheLock.Lock()
-	defer pDataCache.cacheLock.Unlock()
+	unlock := pDataCache.lockKeys([]Key{originalKey, newKey})
+	defer unlock()
 
 	flag := false
-	if pRec, isOK := pDataCache.cache[originalKey]; isOK {
-		pRec.KeyList = append(pRec.KeyList, newKey)
-		pDataCache.cache[newKey] = pRec
+	if pRec, isOK := pDataCache.lookupWOLock(originalKey); isOK {
+		pDataCache.aliasRecWOLock(pRec, newKey)
 		flag = true
 	}
 
@@ -490,10 +645,10 @@ func (pDataCache *DataCache) ReAddAndGetRec(originalKey Key, newKey Key) (int, *
 		return -1, nil, err
 	}
 
-	prec, _ := pDataCache.cache[newKey]
-	prec.pRecLock.Lock()    // record is locked
+	prec, _ := pDataCache.lookupWOLock(newKey)
+	pDataCache.lockRec(prec)    // record is locked
 
-	return pDataCache.cnt, prec, nil
+	return pDataCache.recCnt(), prec, nil
 }
 
 
@@ -537,28 +692,18 @@ func (pDataCache *DataCache) AddRecWOLock(keyList []Key, pRec interface{}, recEx
 
 	if recExistsErrFlag {
 		for i, _ := range keyList {
-			if _, isOK := pDataCache.cache[keyList[i]]; isOK {
+			if pTmpRec, isOK := pDataCache.lookupWOLock(keyList[i]); isOK && !pTmpRec.isExpired(time.Now()) {
 				err = errors.New(fmt.Sprintf("Key \"%s\" exists.", keyList[i]))  // record exists. therefore, record isn't added.
 				return -1, err
 			}
 		}
 	}
 
-	pDataCacheRec := &Rec {
-		PDataRec: pRec,
-		KeyList: keyList,
-		isActive: true,
-	}
-	pDataCacheRec.pRecLock = &sync.Mutex{}
-	pDataCacheRec.pUnlockRecLock = &sync.Mutex{}
-
-	for i, _ := range keyList {
-		pDataCache.cache[keyList[i]] = pDataCacheRec
-	}
+	pDataCacheRec := pDataCache.newRec(keyList, pRec, 0)
 
-	pDataCache.cnt = pDataCache.cnt + 1
+	pDataCache.storeRecWOLock(pDataCacheRec)
 
-	return pDataCache.cnt, nil
+	return pDataCache.recCnt(), nil
 }
 func (pDataCache *DataCache) ForceAddRecWOLock(keyList []Key, pRec interface{}) (int, error) {
 	var err error
@@ -568,27 +713,29 @@ func (pDataCache *DataCache) ForceAddRecWOLock(keyList []Key, pRec interface{})
 		return -1, err
 	}
 
-	for i, _ := range keyList {
-		if _, isOK := pDataCache.cache[keyList[i]]; isOK {
-			delete(pDataCache.cache, i)
-		}
-	}
+	pDataCacheRec := pDataCache.newRec(keyList, pRec, 0)
 
-	pDataCacheRec := &Rec {
-		PDataRec: pRec,
-		KeyList: keyList,
-		isActive: true,
-	}
-	pDataCacheRec.pRecLock = &sync.Mutex{}
-	pDataCacheRec.pUnlockRecLock = &sync.Mutex{}
+	pDataCache.storeRecWOLock(pDataCacheRec)
+
+	return pDataCache.recCnt(), nil
+}
 
-	for i, _ := range keyList {
-		pDataCache.cache[keyList[i]] = pDataCacheRec
+
+// Same as ForceAddRecWOLock(). Additionally, the record expires once ttl has elapsed. 0 means cache-wide
+// default TTL. NoTTL means the record never expires.
+func (pDataCache *DataCache) ForceAddRecWithTTLWOLock(keyList []Key, pRec interface{}, ttl time.Duration) (int, error) {
+	var err error
+
+	if (pDataCache == nil) || (pRec == nil) {
+		err = errors.New("NULL datacache or payload.")
+		return -1, err
 	}
 
-	pDataCache.cnt = pDataCache.cnt + 1
+	pDataCacheRec := pDataCache.newRec(keyList, pRec, ttl)
 
-	return pDataCache.cnt, nil
+	pDataCache.storeRecWOLock(pDataCacheRec)
+
+	return pDataCache.recCnt(), nil
 }
 
 
@@ -603,31 +750,21 @@ func (pDataCache *DataCache) AddAndGetRecWOLock(keyList []Key, pRec interface{},
 
 	if recExistsErrFlag {
 		for i, _ := range keyList {
-			if _, isOK := pDataCache.cache[keyList[i]]; isOK {
+			if pTmpRec, isOK := pDataCache.lookupWOLock(keyList[i]); isOK && !pTmpRec.isExpired(time.Now()) {
 				err = errors.New(fmt.Sprintf("Key \"%s\" exists.", keyList[i]))  // record exists. therefore, record isn't added.
 				return -1, nil, err
 			}
 		}
 	}
 
-	pDataCacheRec := &Rec {
-		PDataRec: pRec,
-		KeyList: keyList,
-		isActive: true,
-	}
-	pDataCacheRec.pRecLock = &sync.Mutex{}
-	pDataCacheRec.pUnlockRecLock = &sync.Mutex{}
-
-	for i, _ := range keyList {
-		pDataCache.cache[keyList[i]] = pDataCacheRec
-	}
+	pDataCacheRec := pDataCache.newRec(keyList, pRec, 0)
 
-	pDataCache.cnt = pDataCache.cnt + 1
+	pDataCache.storeRecWOLock(pDataCacheRec)
 
-	prec, _ := pDataCache.cache[keyList[0]]
-	prec.pRecLock.Lock()    // record is locked
+	prec, _ := pDataCache.lookupWOLock(keyList[0])
+	prec.lock()    // record is locked
 
-	return pDataCache.cnt, prec, nil
+	return pDataCache.recCnt(), prec, nil
 }
 func (pDataCache *DataCache) ForceAddAndGetRecWOLock(keyList []Key, pRec interface{}) (int, *Rec, error) {
 	var err error
@@ -637,30 +774,14 @@ func (pDataCache *DataCache) ForceAddAndGetRecWOLock(keyList []Key, pRec interfa
 		return -1, nil, err
 	}
 
-	for i, _ := range keyList {
-		if _, isOK := pDataCache.cache[keyList[i]]; isOK {
-			delete(pDataCache.cache, i)
-		}
-	}
+	pDataCacheRec := pDataCache.newRec(keyList, pRec, 0)
 
-	pDataCacheRec := &Rec {
-		PDataRec: pRec,
-		KeyList: keyList,
-		isActive: true,
-	}
-	pDataCacheRec.pRecLock = &sync.Mutex{}
-	pDataCacheRec.pUnlockRecLock = &sync.Mutex{}
+	pDataCache.storeRecWOLock(pDataCacheRec)
 
-	for i, _ := range keyList {
-		pDataCache.cache[keyList[i]] = pDataCacheRec
-	}
+	prec, _ := pDataCache.lookupWOLock(keyList[0])
+	prec.lock()    // record is locked
 
-	pDataCache.cnt = pDataCache.cnt + 1
-
-	prec, _ := pDataCache.cache[keyList[0]]
-	prec.pRecLock.Lock()    // record is locked
-
-	return pDataCache.cnt, prec, nil
+	return pDataCache.recCnt(), prec, nil
 }
 
 
@@ -695,9 +816,8 @@ func (pDataCache *DataCache) ReAddRecWOLock(originalKey Key, newKey Key) (int, e
 	}
 
 	flag := false
-	if pRec, isOK := pDataCache.cache[originalKey]; isOK {
-		pRec.KeyList = append(pRec.KeyList, newKey)
-		pDataCache.cache[newKey] = pRec
+	if pRec, isOK := pDataCache.lookupWOLock(originalKey); isOK {
+		pDataCache.aliasRecWOLock(pRec, newKey)
 		flag = true
 	}
 
@@ -706,7 +826,7 @@ func (pDataCache *DataCache) ReAddRecWOLock(originalKey Key, newKey Key) (int, e
 		return -1, err
 	}
 
-	return pDataCache.cnt, nil
+	return pDataCache.recCnt(), nil
 }
 
 
@@ -720,9 +840,8 @@ func (pDataCache *DataCache) ReAddAndGetRecWOLock(originalKey Key, newKey Key) (
 	}
 
 	flag := false
-	if pRec, isOK := pDataCache.cache[originalKey]; isOK {
-		pRec.KeyList = append(pRec.KeyList, newKey)
-		pDataCache.cache[newKey] = pRec
+	if pRec, isOK := pDataCache.lookupWOLock(originalKey); isOK {
+		pDataCache.aliasRecWOLock(pRec, newKey)
 		flag = true
 	}
 
@@ -731,10 +850,10 @@ func (pDataCache *DataCache) ReAddAndGetRecWOLock(originalKey Key, newKey Key) (
 		return -1, nil, err
 	}
 
-	prec, _ := pDataCache.cache[newKey]
-	prec.pRecLock.Lock()    // record is locked
+	prec, _ := pDataCache.lookupWOLock(newKey)
+	pDataCache.lockRec(prec)    // record is locked
 
-	return pDataCache.cnt, prec, nil
+	return pDataCache.recCnt(), prec, nil
 }
 
 
@@ -762,16 +881,23 @@ func (pDataCache *DataCache) DeleteKey(key Key) (err error) {
 		return errors.New("Nil datacache")
 	}
 
-	pDataCache.cacheLock.Lock()
+	unlock := pDataCache.lockKeys([]Key{key})
 	defer func() {
-		pDataCache.cacheLock.Unlock()
+		unlock()
 		if err1 := recover(); err1 != nil {
 			err = errors.New("Recovered from panic, dumping stack.")
 			debug.PrintStack()
 		}
 	}()
 
-	delete(pDataCache.cache, key)
+	if pRec, isOK := pDataCache.lookupWOLock(key); isOK {
+		pDataCache.pWAL.logKeyOp(walOpDeleteKey, key, nil)
+		pDataCache.detachKeyWOLock(pRec, key, EventDelete)
+		if len(pRec.KeyList) == 0 {  // last key of the record; record is removed.
+			pDataCache.pCounters.delete(1)
+		}
+		pDataCache.raiseEvent(EventDelete, []Key{key}, pRec.PDataRec, nil, pRec.isActive)
+	}
 	return nil
 }
 
@@ -801,17 +927,17 @@ func (pDataCache *DataCache) DeleteRec(key Key) (int, error) {
 		return -1, errors.New("Nil datacache")
 	}
 
-	pDataCache.cacheLock.Lock()
+	unlock := pDataCache.lockKeys([]Key{key})
 	/* defer func() {
-		pDataCache.cacheLock.Unlock()
+		pDataCache.unlockStore()
 		if err = recover().(error); err != nil {
 			debug.PrintStack()
 		}
 	}() */
 
-	pRec, isOK := pDataCache.cache[key]
+	pRec, isOK := pDataCache.lookupWOLock(key)
 	if !isOK {  // record with key "key" doesn't exist
-		pDataCache.cacheLock.Unlock()
+		unlock()
 		return -1, errors.New("Key doesn't exist.")
 	}
 
@@ -825,11 +951,9 @@ func (pDataCache *DataCache) DeleteRec(key Key) (int, error) {
 	// record. And if this go-routine isn't careful enough to handle the panic in the recover of defer, the panic is
 	// going to crash the server as this cache record is actually a dangling reference.
 	//
-	// The trick is to hold the record lock in an auxiliary variable (which is actually going to be a pointer to type
-	// sync.Mutex) and block on the Lock() method. Since it's a blocking call, it helps in resolving contention between
+	// The trick is to block on the record lock through lockRec(). Since it's a blocking call, it helps in resolving contention between
 	// the former and the latter go-routines, as depicted in the above example.
 	// Either of them wins the contention and other one is blocked.
-	pTmpRecLock := pRec.pRecLock  // pTmpRecLock just points to pRec.pRecLock
 
 	/* if pTmpRecLock != nil {
 		pTmpRecLock.Lock()            // this go-routing waits on the blocking Lock() in case some other go-routine has already been holding this record.
@@ -842,18 +966,14 @@ func (pDataCache *DataCache) DeleteRec(key Key) (int, error) {
 		pTmpRecLock = nil  // that's it, done. pRec will never be in use hereon.
 	} */
 
-	pTmpRecLock.Lock() // this go-routing waits on the blocking Lock() in case some other go-routine is already holding this record.
-	pTmpRecLock.Unlock()
-	keylist := pRec.KeyList
-	for _, key := range keylist {
-		delete(pDataCache.cache, key)
-	}
-	pTmpRecLock = nil  // that's it, done. pRec will never be in use hereon.
-	pRec = nil
+	pDataCache.lockRec(pRec) // this go-routing waits on the blocking Lock() in case some other go-routine is already holding this record.
+	pRec.unlock()
+	pDataCache.removeRecWOLock(pRec, EventDelete)  // all keys disassociated.
+	pDataCache.pCounters.delete(1)
+	pRec = nil  // that's it, done. pRec will never be in use hereon.
 
-	pDataCache.cnt = pDataCache.cnt - 1
-	cnt := pDataCache.cnt
-	pDataCache.cacheLock.Unlock()
+	cnt := pDataCache.recCnt()
+	unlock()
 
 	return cnt, nil
 }
@@ -892,7 +1012,7 @@ func (pDataCache *DataCache) DeleteRecWOLock(key Key) (bool, int) {
 		}
 	}() */
 
-	pRec, isOK := pDataCache.cache[key]
+	pRec, isOK := pDataCache.lookupWOLock(key)
 	if !isOK {  // record with key "key" doesn't exist
 		return true, 0
 	}
@@ -907,11 +1027,9 @@ func (pDataCache *DataCache) DeleteRecWOLock(key Key) (bool, int) {
 	// record. And if this go-routine isn't careful enough to handle the panic in the recover of defer, the panic is
 	// going to crash the server as this cache record is actually a dangling reference.
 	//
-	// The trick is to hold the record lock in an auxiliary variable (which is actually going to be a pointer to type
-	// sync.Mutex) and block on the Lock() method. Since it's a blocking call, it helps in resolving contention between
+	// The trick is to block on the record lock through lockRec(). Since it's a blocking call, it helps in resolving contention between
 	// the former and the latter go-routines, as depicted in the above example.
 	// Either of them wins the contention and other one is blocked.
-	pTmpRecLock := pRec.pRecLock  // pTmpRecLock just points to pRec.pRecLock
 
 	/* if pTmpRecLock != nil {
 		pTmpRecLock.Lock()            // this go-routing waits on the blocking Lock() in case some other go-routine is already holding this record.
@@ -924,18 +1042,13 @@ func (pDataCache *DataCache) DeleteRecWOLock(key Key) (bool, int) {
 		pTmpRecLock = nil  // that's it, done. pRec will never be in use hereon.
 	} */
 
-	pTmpRecLock.Lock() // this go-routing waits on the blocking Lock() in case some other go-routine is already holding this record.
-	keylist := pRec.KeyList
-	for _, key := range keylist {
-		delete(pDataCache.cache, key)
-	}
-	pTmpRecLock.Unlock()
-	pTmpRecLock = nil  // that's it, done. pRec will never be in use hereon.
-	pRec = nil
-
-	pDataCache.cnt = pDataCache.cnt - 1
+	pDataCache.lockRec(pRec) // this go-routing waits on the blocking Lock() in case some other go-routine is already holding this record.
+	pDataCache.removeRecWOLock(pRec, EventDelete)  // all keys disassociated.
+	pDataCache.pCounters.delete(1)
+	pRec.unlock()
+	pRec = nil  // that's it, done. pRec will never be in use hereon.
 
-	return true, pDataCache.cnt
+	return true, pDataCache.recCnt()
 }
 
 
@@ -948,21 +1061,30 @@ func (pDataCache *DataCache) DeleteCache() bool {
 
 	pDataCache.cacheLock.Lock()
 
-	for key, prec := range pDataCache.cache {
-		pTmpRecLock := prec.pRecLock // pTmpRecLock just points to pRec.pRecLock
-		if pTmpRecLock != nil {
-			pTmpRecLock.Lock() // this go-routing waits on the blocking Lock() in case some other go-routine is already holding this record.
-		}
+	if pDataCache.isHooked(hookDelete) {
+		pDataCache.raiseRecHooksWOLock(hookDelete, EventClear, pDataCache.uniqueRecs())
+	}
+	for _, pShard := range pDataCache.shards {
+		for key, prec := range pShard.cache {
+			pTmpRecLock := prec.pRecLock // pTmpRecLock just points to pRec.pRecLock
+			if pTmpRecLock != nil {
+				prec.lock() // this go-routing waits on the blocking Lock() in case some other go-routine is already holding this record.
+			}
 
-		delete(pDataCache.cache, key) // that's it, done. prec will never be in use once all its keys are removed from the map.
+			delete(pShard.cache, key) // that's it, done. prec will never be in use once all its keys are removed from the map.
 
-		if pTmpRecLock != nil {
-			pTmpRecLock.Unlock()
+			if pTmpRecLock != nil {
+				prec.unlock()
+			}
+			pTmpRecLock = nil
 		}
-		pTmpRecLock = nil
 	}
+	pDataCache.pCounters.delete(pDataCache.recCnt())
+	pDataCache.resetCntWOLock()
+	pDataCache.resetCOWWOLock()
+	pDataCache.raiseEvent(EventClear, nil, nil, nil, false)
 
-	pDataCache.cacheLock.Unlock()
+	pDataCache.unlockStore()
 	return true
 }
 
@@ -974,19 +1096,28 @@ func (pDataCache *DataCache) DeleteCacheWOLock() bool {
 		return false
 	}
 
-	for key, prec := range pDataCache.cache {
-		pTmpRecLock := prec.pRecLock // pTmpRecLock just points to pRec.pRecLock
-		if pTmpRecLock != nil {
-			pTmpRecLock.Lock() // this go-routing waits on the blocking Lock() in case some other go-routine is already holding this record.
-		}
+	if pDataCache.isHooked(hookDelete) {
+		pDataCache.raiseRecHooksWOLock(hookDelete, EventClear, pDataCache.uniqueRecs())
+	}
+	for _, pShard := range pDataCache.shards {
+		for key, prec := range pShard.cache {
+			pTmpRecLock := prec.pRecLock // pTmpRecLock just points to pRec.pRecLock
+			if pTmpRecLock != nil {
+				prec.lock() // this go-routing waits on the blocking Lock() in case some other go-routine is already holding this record.
+			}
 
-		delete(pDataCache.cache, key)  // that's it, done. prec will never be in use once all its keys are removed from the map.
+			delete(pShard.cache, key)  // that's it, done. prec will never be in use once all its keys are removed from the map.
 
-		if pTmpRecLock != nil {
-			pTmpRecLock.Unlock()
+			if pTmpRecLock != nil {
+				prec.unlock()
+			}
+			pTmpRecLock = nil
 		}
-		pTmpRecLock = nil
 	}
+	pDataCache.pCounters.delete(pDataCache.recCnt())
+	pDataCache.resetCntWOLock()
+	pDataCache.resetCOWWOLock()
+	pDataCache.raiseEvent(EventClear, nil, nil, nil, false)
 
 	return true
 }
@@ -1018,17 +1149,21 @@ func (pDataCache *DataCache) UpdateRecState(key Key, recState bool) bool {
 		return false
 	}
 
-	pDataCache.cacheLock.RLock()
-	defer pDataCache.cacheLock.RUnlock()
+	defer pDataCache.dispatchEvents()  // RD store-lock is released by then.
+	pDataCache.rlockKey(key)
+	defer pDataCache.runlockKey(key)
 
-	pRec, isOK := pDataCache.cache[key]
+	pRec, isOK := pDataCache.lookupWOLock(key)
 	if !isOK {
 		return false
 	}
 
-	pRec.pRecLock.Lock()
+	pDataCache.lockRec(pRec)
 	pRec.isActive = recState
-	pRec.pRecLock.Unlock()
+	pDataCache.pWAL.logState(key, recState)
+	pDataCache.raiseEvent(EventStateChange, pRec.KeyList, pRec.PDataRec, pRec.PDataRec, recState)
+	pDataCache.raiseHook(hookStateChange, EventStateChange, pRec.KeyList, pRec.PDataRec, pRec.PDataRec, recState)
+	pRec.unlock()
 
 	return true
 }
@@ -1064,14 +1199,17 @@ func (pDataCache *DataCache) UpdateRecStateWOLock(key Key, recState bool) bool {
 		return false
 	}
 
-	pRec, isOK := pDataCache.cache[key]
+	pRec, isOK := pDataCache.lookupWOLock(key)
 	if !isOK {
 		return false
 	}
 
-	pRec.pRecLock.Lock()  // pRec shouldn't've been in locked state. it's a deadlock otherwise.
+	pDataCache.lockRec(pRec)  // pRec shouldn't've been in locked state. it's a deadlock otherwise.
 	pRec.isActive = recState
-	pRec.pRecLock.Unlock()
+	pDataCache.pWAL.logState(key, recState)
+	pDataCache.raiseEvent(EventStateChange, pRec.KeyList, pRec.PDataRec, pRec.PDataRec, recState)
+	pDataCache.raiseHook(hookStateChange, EventStateChange, pRec.KeyList, pRec.PDataRec, pRec.PDataRec, recState)
+	pRec.unlock()
 
 	return true
 }
@@ -1092,7 +1230,7 @@ Arguments   :
 
 Return value:
 1> bool: true if successful. false if failed.
-2> *Rec: Found datacache record. Or nil if cache record isn't found.
+2> *Rec: Found datacache record. Or nil if cache record isn't found or has expired.
 
 Additional note:
 - Method takes RD store-lock. Caller go-routine shouldn't invoke this method in any
@@ -1104,15 +1242,18 @@ func (pDataCache *DataCache) GetRec(key Key) (bool, *Rec) {
 		return false, nil
 	}
 
-	pDataCache.ReadLock()
-	defer pDataCache.ReadUnlock()
+	pRec := pDataCache.rlockKeyAndRec(key, false)  // record is locked
+	defer pDataCache.runlockKey(key)
 
-	pRec, isOK := pDataCache.cache[key]
-	if !isOK {
+	if pRec == nil {
+		pDataCache.pCounters.miss()
 		return false, nil
 	}
 
-	pRec.pRecLock.Lock()  // record is locked
+	pDataCache.pCounters.hit()
+	if pDataCache.policy != nil {
+		pDataCache.policy.OnHit(pRec)
+	}
 
 	return true, pRec
 }
@@ -1133,7 +1274,7 @@ Arguments   :
 
 Return value:
 1> bool: true if successful. false if failed.
-2> *Rec: Found datacache record. Or nil if cache record isn't found.
+2> *Rec: Found datacache record. Or nil if cache record isn't found or has expired.
 
 Additional note:
 - Method doesn't take any store-lock and thus doesn't release any. Caller go-routine
@@ -1147,12 +1288,17 @@ func (pDataCache *DataCache) GetRecWOLock(key Key) (bool, *Rec) {
 		return false, nil
 	}
 
-	pRec, isOK := pDataCache.cache[key]
-	if !isOK {
+	pRec, isOK := pDataCache.lookupWOLock(key)
+	if !isOK || pRec.isExpired(time.Now()) {
+		pDataCache.pCounters.miss()
 		return false, nil
 	}
 
-	pRec.pRecLock.Lock()  // record is locked
+	pDataCache.lockRec(pRec)  // record is locked
+	pDataCache.pCounters.hit()
+	if pDataCache.policy != nil {
+		pDataCache.policy.OnHit(pRec)
+	}
 
 	return true, pRec
 }
@@ -1188,18 +1334,25 @@ func (pDataCache *DataCache) GetDataRec(key Key) (bool, interface{}) {
 		return false, nil
 	}
 
-	pDataCache.cacheLock.RLock()
-	defer pDataCache.cacheLock.RUnlock()
+	if pDataCache.pCOW != nil {
+		return pDataCache.getDataRecCOW(key)
+	}
 
-	pRec, isOK := pDataCache.cache[key]
-	if !isOK {
+	pRec := pDataCache.rlockKeyAndRec(key, true)
+	defer pDataCache.runlockKey(key)
+
+	if pRec == nil {
 		//return false, interface{}
+		pDataCache.pCounters.miss()
 		return false, nil
 	}
 
-	pRec.pRecLock.Lock()
 	pDataRec := pRec.PDataRec
-	pRec.pRecLock.Unlock()
+	pRec.runlock()
+	pDataCache.pCounters.hit()
+	if pDataCache.policy != nil {
+		pDataCache.policy.OnHit(pRec)
+	}
 
 	return true, pDataRec
 }
@@ -1236,14 +1389,19 @@ func (pDataCache *DataCache) GetDataRecWOLock(key Key) (bool, interface{}) {
 		return false, nil
 	}
 
-	pRec, isOK := pDataCache.cache[key]
-	if !isOK {
+	pRec, isOK := pDataCache.lookupWOLock(key)
+	if !isOK || pRec.isExpired(time.Now()) {
+		pDataCache.pCounters.miss()
 		return false, nil
 	}
 
-	pRec.pRecLock.Lock()
+	pDataCache.rlockRec(pRec)
 	pDataRec := pRec.PDataRec
-	pRec.pRecLock.Unlock()
+	pRec.runlock()
+	pDataCache.pCounters.hit()
+	if pDataCache.policy != nil {
+		pDataCache.policy.OnHit(pRec)
+	}
 
 	return true, pDataRec
 }
@@ -1261,7 +1419,7 @@ Arguments   :
 1> key Key: Key to the cache record.
 
 Return value:
-1> bool: true if key exists. false if it doesn't.
+1> bool: true if key exists. false if it doesn't or if the record has expired.
 
 Additional note: NA
 ***************************************************************************** */
@@ -1270,10 +1428,15 @@ func (pDataCache *DataCache) DoesKeyExist(key Key) bool {
 		return false
 	}
 
-	pDataCache.ReadLock()
-	defer pDataCache.ReadUnlock()
+	if pDataCache.pCOW != nil {
+		_, pState := pDataCache.lookupCOW(key)
+		return pState != nil
+	}
+
+	pDataCache.rlockKey(key)
+	defer pDataCache.runlockKey(key)
 
-	if _, isOK := pDataCache.cache[key]; !isOK {
+	if pRec, isOK := pDataCache.lookupWOLock(key); !isOK || pRec.isExpired(time.Now()) {
 		return false
 	}
 
@@ -1293,7 +1456,7 @@ Arguments   :
 1> key Key: Key to the cache record.
 
 Return value:
-1> bool: true if key exists. false if it doesn't.
+1> bool: true if key exists. false if it doesn't or if the record has expired.
 
 Additional note:
 - As the name suggests, the method doesn't take any store lock. It rather assumes
@@ -1304,7 +1467,7 @@ func (pDataCache *DataCache) DoesKeyExistWOLock(key Key) bool {
 		return false
 	}
 
-	if _, isOK := pDataCache.cache[key]; !isOK {
+	if pRec, isOK := pDataCache.lookupWOLock(key); !isOK || pRec.isExpired(time.Now()) {
 		return false
 	}
 
@@ -1328,18 +1491,15 @@ Return value:
 2> int: Number of records in the cache.
 
 Additional note:
-This method is guarded through WR store-lock and releases the same while returning.
-Therefore, caller shouldn't take any lock before calling this method.
+Record count is maintained atomically. Hence, the method doesn't take any store-lock and
+may be invoked in any store-lock.
 ***************************************************************************** */
 func (pDataCache *DataCache) GetCnt() (bool, int) {
 	if pDataCache == nil {
 		return false, 0
 	}
 
-	pDataCache.cacheLock.Lock()
-	defer pDataCache.cacheLock.Unlock()
-
-	return true, pDataCache.cnt
+	return true, pDataCache.recCnt()
 }
 
 
@@ -1369,11 +1529,11 @@ func (pDataCache *DataCache) SetCnt(val int) (bool, int) {
 	}
 
 	pDataCache.cacheLock.Lock()
-	defer pDataCache.cacheLock.Unlock()
+	defer pDataCache.unlockStore()
 
-	pDataCache.cnt = val
+	atomic.StoreInt64(&pDataCache.cnt, int64(val))
 
-	return true, pDataCache.cnt
+	return true, pDataCache.recCnt()
 }
 
 
@@ -1414,7 +1574,7 @@ func (pDataCache *DataCache) Load(isLoaderProvided bool) (bool, error) {
 		if pDataCache.reciteratefn == nil {
 			pDataCache.singletonFlag = true
 		}
-		pDataCache.cacheLock.Unlock()
+		pDataCache.unlockStore()
 	}()
 
 	if pDataCache.singletonFlag {
@@ -1431,29 +1591,21 @@ func (pDataCache *DataCache) Load(isLoaderProvided bool) (bool, error) {
 		return false, err
 	}
 
+	startTime := time.Now()
 	recList, err := pDataCache.loadfn()
 	if err != nil {
 		err = errors.New("Load function failed.")
 		return false, err
 	}
+	pDataCache.pCounters.load(time.Since(startTime))
 
 	for i, _ := range recList {
-		pDataCacheRec := &Rec {
-			PDataRec: recList[i].PDataRec,
-			KeyList: recList[i].KeyList,
-			isActive: true,
-		}
-		pDataCacheRec.pRecLock = &sync.Mutex{}
-		pDataCacheRec.pUnlockRecLock = &sync.Mutex{}
+		pDataCacheRec := pDataCache.newRec(recList[i].KeyList, recList[i].PDataRec, recList[i].TTL)
 		//fmt.Printf("dbgrm::  dataRec: %#v\nkeyList: $#v\n", *pDataCacheRec.PDataRec, pDataCacheRec.KeyList)
 
-		for j, _ := range recList[i].KeyList {
-			pDataCache.cache[recList[i].KeyList[j]] = pDataCacheRec
-		}
+		pDataCache.storeRecWOLock(pDataCacheRec)
 	}
 
-	pDataCache.cnt = len(recList)
-
 	return true, nil
 }
 
@@ -1469,7 +1621,9 @@ Receiver    :
 Implements  : NA
 
 Arguments   :
-1> isIteratorProvided bool: Method reports error if this flag is true and iterator function
+1> cacheName string: Name of the cache for error messages. Falls back to the name the cache
+is registered under (see Register()) if empty.
+2> isIteratorProvided bool: Method reports error if this flag is true and iterator function
 isn't provided.
 
 Return value:
@@ -1481,8 +1635,8 @@ Additional note:
 does so.
 - The method by itself takes WR store-lock and releases the same once done.
 - Should be invoked only during server start-up and just after the cache is loaded.
-and before any other subsystem, such as webserver, is initialized. That's the reason, each
-iterated record isn't guarded in its own record lock.
+and before any other subsystem, such as webserver, is initialized. Each iterated record is
+guarded in its record lock in shared mode.
 **************************************************************************** */
 func (pDataCache *DataCache) Iterate(cacheName string, isIteratorProvided bool) (bool, error) {
 	var err error
@@ -1495,11 +1649,11 @@ func (pDataCache *DataCache) Iterate(cacheName string, isIteratorProvided bool)
 	pDataCache.cacheLock.Lock()
 	defer func() {
 		pDataCache.singletonFlag = true
-		pDataCache.cacheLock.Unlock()
+		pDataCache.unlockStore()
 	}()
 
 	if pDataCache.singletonFlag {
-		err = errors.New("Already executed load-time iteration sequence.")
+		err = errors.New(fmt.Sprintf("Cache \"%s\": already executed load-time iteration sequence.", pDataCache.cacheName(cacheName)))
 		return false, err
 	}
 
@@ -1508,14 +1662,14 @@ func (pDataCache *DataCache) Iterate(cacheName string, isIteratorProvided bool)
 			return true, nil
 		}
 
-		err = errors.New("Nil datacache iterator.")
+		err = errors.New(fmt.Sprintf("Cache \"%s\": nil datacache iterator.", pDataCache.cacheName(cacheName)))
 		return false, err
 	}
 
-	for _, pRec := range pDataCache.cache {
-		//pRec.RecLock()
+	for _, pRec := range pDataCache.uniqueRecs() {
+		pDataCache.rlockRec(pRec)
 		pDataCache.reciteratefn(pRec.PDataRec)
-		//pRec.RecUnlock()
+		pRec.runlock()
 	}
 
 	return true, nil
@@ -1561,7 +1715,7 @@ func (pDataCache *DataCache) LoadAndIterate(isLoaderProvided bool, isIteratorPro
 	pDataCache.cacheLock.Lock()
 	defer func() {
 		pDataCache.singletonFlag = true
-		pDataCache.cacheLock.Unlock()
+		pDataCache.unlockStore()
 	}()
 
 	if pDataCache.singletonFlag {
@@ -1578,28 +1732,20 @@ func (pDataCache *DataCache) LoadAndIterate(isLoaderProvided bool, isIteratorPro
 		return false, err
 	}
 
+	startTime := time.Now()
 	recList, err := pDataCache.loadfn()
 	if err != nil {
 		err = errors.New("Load function failed.")
 		return false, err
 	}
+	pDataCache.pCounters.load(time.Since(startTime))
 
 	for i, _ := range recList {
-		pDataCacheRec := &Rec {
-			PDataRec: recList[i].PDataRec,
-			KeyList: recList[i].KeyList,
-			isActive: true,
-		}
-		pDataCacheRec.pRecLock = &sync.Mutex{}
-		pDataCacheRec.pUnlockRecLock = &sync.Mutex{}
+		pDataCacheRec := pDataCache.newRec(recList[i].KeyList, recList[i].PDataRec, recList[i].TTL)
 
-		for j, _ := range recList[i].KeyList {
-			pDataCache.cache[recList[i].KeyList[j]] = pDataCacheRec
-		}
+		pDataCache.storeRecWOLock(pDataCacheRec)
 	}
 
-	pDataCache.cnt = len(recList)
-
 	if pDataCache.reciteratefn == nil {
 		if !isIteratorProvided {
 			return true, nil
@@ -1609,8 +1755,10 @@ func (pDataCache *DataCache) LoadAndIterate(isLoaderProvided bool, isIteratorPro
 		return false, err
 	}
 
-	for _, pRec := range pDataCache.cache {
+	for _, pRec := range pDataCache.uniqueRecs() {
+		pDataCache.rlockRec(pRec)
 		pDataCache.reciteratefn(pRec.PDataRec)
+		pRec.runlock()
 	}
 
 	return true, nil
@@ -1628,7 +1776,8 @@ Receiver    :
 Implements  : NA
 
 Arguments   :
-1> cacheName string: Just for a log message. Isn't being used right now.
+1> cacheName string: Name of the cache for error messages. Falls back to the name the cache
+is registered under (see Register()) if empty.
 2> recHandler RecHandlerFunc: Handler function of each iterated record.
 
 Return value:
@@ -1653,7 +1802,7 @@ func (pDataCache *DataCache) AuxIterate(cacheName string, recHandler RecHandlerF
 	}
 
 	if recHandler == nil {
-		err = errors.New("Nil or no cache record handler provided.")
+		err = errors.New(fmt.Sprintf("Cache \"%s\": nil or no cache record handler provided.", pDataCache.cacheName(cacheName)))
 		return false, err
 	}
 
@@ -1661,11 +1810,11 @@ func (pDataCache *DataCache) AuxIterate(cacheName string, recHandler RecHandlerF
 	defer pDataCache.WriteUnlock()
 
 	if recHandler == nil {
-		err = errors.New("Nil datacache iterator.")
+		err = errors.New(fmt.Sprintf("Cache \"%s\": nil datacache iterator.", pDataCache.cacheName(cacheName)))
 		return false, err
 	}
 
-	for _, pRec := range pDataCache.cache {
+	for _, pRec := range pDataCache.uniqueRecs() {
 		pRec.RecLock()
 		recHandler(pRec)
 		RecUnlock(pRec)
@@ -1675,6 +1824,63 @@ func (pDataCache *DataCache) AuxIterate(cacheName string, recHandler RecHandlerF
 }
 
 
+/* ****************************************************************************
+Description :
+Read-only iteration over the cache. recHandler is invoked on each unexpired record (of
+type *Rec) once, even though the record may be mapped to multiple keys. Iteration stops
+the moment recHandler returns false.
+
+Receiver    :
+1> pDataCache *DataCache: DataCache store
+
+Implements  : NA
+
+Arguments   :
+1> recHandler RecHandlerFunc: Handler function of each iterated record.
+
+Return value:
+1> bool: true if all records have been iterated, false otherwise.
+2> error: Returns cause of error.
+
+Additional note:
+- Caller go-routine shouldn't invoke this method in any store-lock. It's deadlock in case it
+does so.
+- The method takes RD store-lock and releases the same once done. recHandler is guarded in the
+record lock, in shared mode, of each iterated record.
+- Unlike AuxIterate(), other readers of the cache aren't blocked. However, writers are blocked
+until the iteration is over. recHandler shouldn't modify the cache.
+**************************************************************************** */
+func (pDataCache *DataCache) Scan(recHandler RecHandlerFunc) (bool, error) {
+	if pDataCache == nil {
+		return false, errors.New("Nil datacache.")
+	}
+
+	if recHandler == nil {
+		return false, errors.New("Nil or no cache record handler provided.")
+	}
+
+	pDataCache.ReadLock()
+	defer pDataCache.ReadUnlock()
+
+	now := time.Now()
+	for _, pRec := range pDataCache.uniqueRecs() {
+		if pRec.isExpired(now) {
+			continue
+		}
+
+		pDataCache.rlockRec(pRec)
+		isOK := recHandler(pRec)
+		pRec.runlock()
+
+		if !isOK {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+
 /* ****************************************************************************
 Description :
 Function creates datacache instance.
@@ -1689,17 +1895,50 @@ Each cache may have its own specific way of loading the cache. Load() invokes
 the function referred by loadFunc to load the cache.
 2> iteratorFunc RecHandlerFunc: Callback used by Iterate().
 iteratorFunc is invoked on payload of each iterated datacache record.
+3> opts ...Option: Optional configuration, for instance, WithDefaultTTL().
 
 Return value:
 1> *DataCache: Newly created datacache instance.
 
-Additional note: NA
+Additional note:
+- Background go-routines, such as the janitor and the refresher, are stopped through Close().
 **************************************************************************** */
-func Create(loadFunc LoadFunc, iteratorFunc RecHandlerFunc) *DataCache {
+func Create(loadFunc LoadFunc, iteratorFunc RecHandlerFunc, opts ...Option) *DataCache {
 	pDataCache := &DataCache {
-		cache: make(CacheStore),
+		shards: newShards(1),
 		loadfn: loadFunc,
 		reciteratefn: iteratorFunc,
+		janitorInterval: defaultJanitorInterval,
+		pStopChan: make(chan struct{}),
+		loadCallMap: make(map[Key]*loadCall),
+		codec: GobCodec,
+		pCounters: newCacheCounters(),
+		pWatch: newWatchHub(),
+		pHooks: newHookRegistry(),
+	}
+
+	for _, opt := range opts {
+		if opt != nil {
+			opt(pDataCache)
+		}
+	}
+
+	if (pDataCache.maxRecs == 0) && (pDataCache.maxBytes == 0) {
+		pDataCache.policy = nil  // unbounded cache never evicts.
+	} else if pDataCache.policy == nil {
+		pDataCache.policy = NewLRUPolicy()
+	}
+
+	if (pDataCache.refreshInterval > 0) && (pDataCache.loadfn != nil) {
+		go pDataCache.refresher()
+	}
+
+	if (pDataCache.pLockTracker != nil) && (pDataCache.pLockTracker.threshold > 0) {
+		go pDataCache.lockWatchdog()
+	}
+
+	if (pDataCache.pCOW != nil) && (pDataCache.pCOW.batchInterval > 0) {
+		go pDataCache.cowPublisher()
 	}
 
 	return pDataCache
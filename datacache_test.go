@@ -0,0 +1,39 @@
+package datacache
+
+import (
+	"testing"
+)
+
+
+// RecUnlock() of a record that isn't held in exclusive mode must be a no-op. RWMutex.Unlock() of such
+// a record is a fatal error which recover() can't catch.
+func TestRecUnlockNotHeld(t *testing.T) {
+	pDataCache := Create(nil, nil)
+	defer pDataCache.Close()
+
+	if _, err := pDataCache.AddRec([]Key{"k"}, "v", true); err != nil {
+		t.Fatalf("AddRec: %v", err)
+	}
+
+	isOK, pRec := pDataCache.GetRec("k")
+	if !isOK {
+		t.Fatalf("GetRec failed")
+	}
+	RecUnlock(pRec)
+	RecUnlock(pRec)  // double unlock.
+
+	isOK, pHandle := pDataCache.GetRecForRead("k")
+	if !isOK {
+		t.Fatalf("GetRecForRead failed")
+	}
+	RecUnlock(pRec)  // held in shared mode only.
+	if pRec.tryLock() {
+		t.Fatalf("RecUnlock released the shared lock")
+	}
+	pHandle.Release()
+
+	if !pRec.tryLock() {
+		t.Fatalf("record is still locked")
+	}
+	pRec.unlockUnmodified()
+}
@@ -0,0 +1,194 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache
+Filename    : github.com/sameeroak1110/datacache/eviction.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Bounded capacity and pluggable eviction policies.
+- DataCache consults its EvictionPolicy whenever a record is added, hit (through
+GetRec() or GetDataRec() and their WOLock counterparts) and removed. Hits happen in
+RD store-lock, hence, every policy must guard its own state in its own lock.
+- Eviction happens in WR store-lock whilst a record is being added. Evicted record
+is removed in entirety, i.e., all its keys are disassociated at once. A record which
+is held locked by some go-routine is never evicted; the policy is asked for the next
+candidate instead.
+- LRU and FIFO policies are implemented here. LFU, ARC and W-TinyLFU have their own
+source files.
+**************************************************************************** */
+package datacache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+
+/* *****************************************************************************
+EvictionPolicy decides which record is evicted once the cache grows beyond its capacity.
+
+- OnInsert(): pRec has just been added in the cache.
+- OnHit(): pRec has been fetched. Invoked in RD store-lock, possibly concurrently.
+- OnDelete(): pRec has been removed from the cache, either evicted or deleted.
+- Victim(): Returns the record to be evicted. Policy should offer records in the order
+of its preference until isEvictable returns true, and return nil if there's no such
+record. isEvictable never blocks.
+- Reset(): All records have been removed from the cache.
+
+OnInsert(), OnDelete(), Victim() and Reset() are invoked in WR store-lock.
+Policy mustn't invoke any DataCache method.
+***************************************************************************** */
+type EvictionPolicy interface {
+	OnInsert(pRec *Rec)
+	OnHit(pRec *Rec)
+	OnDelete(pRec *Rec)
+	Victim(isEvictable func(*Rec) bool) *Rec
+	Reset()
+}
+
+
+// Returns true if the cache has grown beyond its capacity.
+// Must be invoked in WR store-lock.
+func (pDataCache *DataCache) isOverCapacityWOLock() bool {
+	if (pDataCache.maxRecs > 0) && (pDataCache.recCnt() > pDataCache.maxRecs) {
+		return true
+	}
+
+	if (pDataCache.maxBytes > 0) && (atomic.LoadInt64(&pDataCache.bytes) > pDataCache.maxBytes) {
+		return true
+	}
+
+	return false
+}
+
+
+// Evicts records, as is chosen by the eviction policy, until the cache is within its capacity.
+// pExclude, typically the record being added, isn't evicted. A record is evicted only if its record
+// lock can be taken without blocking. Therefore, the cache may temporarily remain beyond capacity if
+// every other record is held locked.
+// Must be invoked in WR store-lock.
+func (pDataCache *DataCache) evictWOLock(pExclude *Rec) {
+	if pDataCache.policy == nil {
+		return
+	}
+
+	for pDataCache.isOverCapacityWOLock() {
+		pVictim := pDataCache.policy.Victim(func(pRec *Rec) bool {
+			if pRec == pExclude {
+				return false
+			}
+
+			return pRec.tryLock()  // victim remains locked until it's removed.
+		})
+		if pVictim == nil {
+			return
+		}
+
+		pDataCache.removeRecWOLock(pVictim, EventEvict)
+		pVictim.unlock()
+		pDataCache.pCounters.evict()
+	}
+}
+
+
+// Returns the first record of the list, walking from back to front, for which isEvictable returns true.
+func victimFromBack(pList *list.List, isEvictable func(*Rec) bool) *Rec {
+	for pElem := pList.Back(); pElem != nil; pElem = pElem.Prev() {
+		pRec := pElem.Value.(*Rec)
+		if isEvictable(pRec) {
+			return pRec
+		}
+	}
+
+	return nil
+}
+
+
+// LRU and FIFO policies. Both keep records in a list in the order of their insertion.
+// LRU additionally moves a record to the front on each hit.
+type listPolicy struct {
+	lock sync.Mutex
+	pList *list.List                  // front is the most recent record, back is the eviction candidate.
+	elemMap map[*Rec]*list.Element
+	moveOnHit bool                    // true for LRU, false for FIFO.
+}
+
+
+// Returns least-recently-used eviction policy. It's the default policy of a bounded cache.
+func NewLRUPolicy() EvictionPolicy {
+	return &listPolicy {
+		pList: list.New(),
+		elemMap: make(map[*Rec]*list.Element),
+		moveOnHit: true,
+	}
+}
+
+
+// Returns first-in-first-out eviction policy. Hits don't affect the order of eviction.
+func NewFIFOPolicy() EvictionPolicy {
+	return &listPolicy {
+		pList: list.New(),
+		elemMap: make(map[*Rec]*list.Element),
+	}
+}
+
+
+func (pPolicy *listPolicy) OnInsert(pRec *Rec) {
+	pPolicy.lock.Lock()
+	defer pPolicy.lock.Unlock()
+
+	if _, isOK := pPolicy.elemMap[pRec]; !isOK {
+		pPolicy.elemMap[pRec] = pPolicy.pList.PushFront(pRec)
+	}
+}
+
+
+func (pPolicy *listPolicy) OnHit(pRec *Rec) {
+	if !pPolicy.moveOnHit {
+		return
+	}
+
+	pPolicy.lock.Lock()
+	defer pPolicy.lock.Unlock()
+
+	if pElem, isOK := pPolicy.elemMap[pRec]; isOK {
+		pPolicy.pList.MoveToFront(pElem)
+	}
+}
+
+
+func (pPolicy *listPolicy) OnDelete(pRec *Rec) {
+	pPolicy.lock.Lock()
+	defer pPolicy.lock.Unlock()
+
+	if pElem, isOK := pPolicy.elemMap[pRec]; isOK {
+		pPolicy.pList.Remove(pElem)
+		delete(pPolicy.elemMap, pRec)
+	}
+}
+
+
+func (pPolicy *listPolicy) Victim(isEvictable func(*Rec) bool) *Rec {
+	pPolicy.lock.Lock()
+	defer pPolicy.lock.Unlock()
+
+	return victimFromBack(pPolicy.pList, isEvictable)
+}
+
+
+func (pPolicy *listPolicy) Reset() {
+	pPolicy.lock.Lock()
+	defer pPolicy.lock.Unlock()
+
+	pPolicy.pList.Init()
+	pPolicy.elemMap = make(map[*Rec]*list.Element)
+}
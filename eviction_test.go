@@ -0,0 +1,156 @@
+package datacache
+
+import (
+	"testing"
+)
+
+
+// Adds keys in the order given, hits the ones of hitList, then adds "new". Returns the keys evicted.
+func evictedKeys(pDataCache *DataCache, keyList []string, hitList []string) []string {
+	for _, key := range keyList {
+		pDataCache.AddRec([]Key{key}, key, true)
+	}
+	for _, key := range hitList {
+		pDataCache.GetDataRec(key)
+	}
+	pDataCache.AddRec([]Key{"new"}, "new", true)
+
+	var evictedList []string
+	for _, key := range keyList {
+		if !pDataCache.DoesKeyExist(key) {
+			evictedList = append(evictedList, key)
+		}
+	}
+
+	return evictedList
+}
+
+
+func TestLRUEviction(t *testing.T) {
+	testList := []struct {
+		name string
+		hitList []string
+		evicted string
+	}{
+		{"no hits", nil, "a"},
+		{"oldest hit", []string{"a"}, "b"},
+		{"all hit", []string{"c", "a", "b"}, "c"},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			pDataCache := Create(nil, nil, WithMaxRecs(3))
+			defer pDataCache.Close()
+
+			evictedList := evictedKeys(pDataCache, []string{"a", "b", "c"}, test.hitList)
+			if (len(evictedList) != 1) || (evictedList[0] != test.evicted) {
+				t.Fatalf("evicted %v, want [%s]", evictedList, test.evicted)
+			}
+			if _, cnt := pDataCache.GetCnt(); cnt != 3 {
+				t.Fatalf("GetCnt() = %d, want 3", cnt)
+			}
+		})
+	}
+}
+
+
+func TestMaxBytesEviction(t *testing.T) {
+	sizeFn := func(pDataRec interface{}) int64 {
+		return int64(len(pDataRec.(string)))
+	}
+	pDataCache := Create(nil, nil, WithMaxBytes(10, sizeFn))
+	defer pDataCache.Close()
+
+	pDataCache.AddRec([]Key{"a"}, "aaaa", true)
+	pDataCache.AddRec([]Key{"b"}, "bbbb", true)
+	pDataCache.AddRec([]Key{"c"}, "cccccc", true)  // 14 bytes. a is evicted.
+
+	if pDataCache.DoesKeyExist("a") || !pDataCache.DoesKeyExist("b") || !pDataCache.DoesKeyExist("c") {
+		t.Fatalf("unexpected cache contents after exceeding max bytes")
+	}
+}
+
+
+// Record held locked isn't evicted; the next candidate is. A record is evicted with all its keys.
+func TestEvictionSkipsLockedRec(t *testing.T) {
+	pDataCache := Create(nil, nil, WithMaxRecs(2))
+	defer pDataCache.Close()
+
+	pDataCache.AddRec([]Key{"a"}, "a", true)
+	pDataCache.AddRec([]Key{"b1", "b2"}, "b", true)
+	_, pRec := pDataCache.GetRec("a")
+	pRec.DataCacheRecUnlock()
+	pDataCache.AddRec([]Key{"c"}, "c", true)  // LRU victim is b once a is hit.
+	if pDataCache.DoesKeyExist("b1") || pDataCache.DoesKeyExist("b2") {
+		t.Fatalf("evicted record is still referred to by one of its keys")
+	}
+
+	isOK, pRec := pDataCache.GetRec("a")  // a is the most recent one now, however, c ...
+	if !isOK {
+		t.Fatalf("GetRec(a) failed")
+	}
+	pDataCache.GetDataRec("c")  // ... is hit after it. a, the LRU victim, is locked.
+	pDataCache.AddRec([]Key{"d"}, "d", true)
+	pRec.DataCacheRecUnlock()
+
+	if !pDataCache.DoesKeyExist("a") || pDataCache.DoesKeyExist("c") {
+		t.Fatalf("locked record has been evicted")
+	}
+}
+
+
+func TestEvictionPolicies(t *testing.T) {
+	testList := []struct {
+		name string
+		policy func() EvictionPolicy
+		hitList []string
+		evicted string
+	}{
+		{"FIFO ignores hits", NewFIFOPolicy, []string{"a", "a"}, "a"},
+		{"LFU, never hit", NewLFUPolicy, []string{"a", "a", "b"}, "c"},
+		{"LFU, tie on hits", NewLFUPolicy, []string{"b", "c"}, "a"},
+		{"ARC, T1 before T2", func() EvictionPolicy { return NewARCPolicy(3) }, []string{"a"}, "b"},
+		{"W-TinyLFU, candidate rejected", func() EvictionPolicy { return NewTinyLFUPolicy(3) }, []string{"a", "b"}, "c"},
+		{"W-TinyLFU, candidate admitted", func() EvictionPolicy { return NewTinyLFUPolicy(3) }, []string{"c", "c"}, "a"},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			pDataCache := Create(nil, nil, WithMaxRecs(3), WithEvictionPolicy(test.policy()))
+			defer pDataCache.Close()
+
+			evictedList := evictedKeys(pDataCache, []string{"a", "b", "c"}, test.hitList)
+			if (len(evictedList) != 1) || (evictedList[0] != test.evicted) {
+				t.Fatalf("evicted %v, want [%s]", evictedList, test.evicted)
+			}
+		})
+	}
+}
+
+
+// Record re-added against the ghost key of an evicted record enters T2 straight away.
+func TestARCGhost(t *testing.T) {
+	pPolicy := NewARCPolicy(2).(*arcPolicy)
+	pDataCache := Create(nil, nil, WithMaxRecs(2), WithEvictionPolicy(pPolicy))
+	defer pDataCache.Close()
+
+	pDataCache.AddRec([]Key{"a"}, "a", true)
+	pDataCache.AddRec([]Key{"b"}, "b", true)
+	pDataCache.GetDataRec("b")                // b moves to T2.
+	pDataCache.AddRec([]Key{"c"}, "c", true)  // a is evicted from T1 and leaves a ghost.
+	if _, isOK := pPolicy.ghostMap["a"]; !isOK {
+		t.Fatalf("evicted record has left no ghost")
+	}
+
+	pDataCache.DeleteRec("b")  // deleted, not evicted. leaves no ghost.
+	if _, isOK := pPolicy.ghostMap["b"]; isOK {
+		t.Fatalf("deleted record has left a ghost")
+	}
+
+	pDataCache.AddRec([]Key{"a"}, "a", true)
+	_, pRec := pDataCache.GetRec("a")
+	pRec.DataCacheRecUnlock()
+	if !pPolicy.entryMap[pRec].isInT2 {
+		t.Fatalf("record added against its ghost isn't in T2")
+	}
+}
@@ -0,0 +1,318 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache
+Filename    : github.com/sameeroak1110/datacache/handle.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Handle is an alternative to the locked *Rec returned by GetRec(). The record lock is
+held until Release() is invoked on the handle.
+- A handle may be acquired on a lease. Once the lease expires, the record lock is forcibly
+reclaimed, the handle is invalidated and the expiry is reported along with the source
+location where the handle was acquired. It catches the missing Release() which otherwise
+would hold the record forever.
+**************************************************************************** */
+package datacache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+
+// LeaseExpiry describes a handle whose lease has expired.
+type LeaseExpiry struct {
+	Key Key                  // key the handle was acquired with.
+	AcquiredAt time.Time
+	Lease time.Duration
+	Site string              // file:line where the handle was acquired.
+}
+
+// invoked once the lease of a handle expires. Record lock has already been reclaimed by then.
+type LeaseExpiryFunc func(LeaseExpiry)
+
+type Handle struct {
+	pDataCache *DataCache
+	pRec *Rec
+	key Key
+	lease time.Duration
+	acquiredAt time.Time
+	site string
+	isShared bool            // true if the record is locked in shared mode.
+
+	lock sync.Mutex          // guards the fields below.
+	isValid bool             // false once released or once the lease has expired.
+	pLeaseTimer *time.Timer  // nil if the handle doesn't expire.
+}
+
+
+// Returns file:line of the caller skip frames above the caller of callerSite().
+func callerSite(skip int) string {
+	_, file, line, isOK := runtime.Caller(skip + 1)
+	if !isOK {
+		return "unknown"
+	}
+
+	return fmt.Sprintf("%s:%d", file, line)
+}
+
+
+// Wraps locked record in a handle and arms the lease timer, if any.
+func (pDataCache *DataCache) newHandle(key Key, pRec *Rec, lease time.Duration, site string, isShared bool) *Handle {
+	pHandle := &Handle {
+		pDataCache: pDataCache,
+		pRec: pRec,
+		key: key,
+		lease: lease,
+		acquiredAt: time.Now(),
+		site: site,
+		isShared: isShared,
+		isValid: true,
+	}
+
+	if lease > 0 {
+		pHandle.lock.Lock()
+		pHandle.pLeaseTimer = time.AfterFunc(lease, pHandle.expire)
+		pHandle.lock.Unlock()
+	}
+
+	return pHandle
+}
+
+
+/* ****************************************************************************
+Description :
+Acquires the record referred to by key. The record remains locked until Release() is
+invoked on the returned handle or, if the cache has a default lease (see WithDefaultLease()),
+until the lease expires.
+
+Receiver    :
+pDataCache *DataCache: Instance of datacache.
+
+Implements  : NA
+
+Arguments   :
+1> key Key: Key to the cache record.
+
+Return value:
+1> bool: true if successful. false if cache record isn't found or has expired.
+2> *Handle: Handle of the locked record. nil in case of error.
+
+Additional note:
+- Same locking rules as GetRec(). Caller go-routine shouldn't invoke this method in any store-lock.
+**************************************************************************** */
+func (pDataCache *DataCache) AcquireRec(key Key) (bool, *Handle) {
+	if pDataCache == nil {
+		return false, nil
+	}
+
+	isOK, pRec := pDataCache.GetRec(key)
+	if !isOK {
+		return false, nil
+	}
+
+	return true, pDataCache.newHandle(key, pRec, pDataCache.defaultLease, callerSite(1), false)
+}
+
+
+// Same as AcquireRec(). The handle expires once lease has elapsed. lease <= 0 means the handle doesn't expire.
+func (pDataCache *DataCache) AcquireRecWithLease(key Key, lease time.Duration) (bool, *Handle) {
+	if pDataCache == nil {
+		return false, nil
+	}
+
+	isOK, pRec := pDataCache.GetRec(key)
+	if !isOK {
+		return false, nil
+	}
+
+	return true, pDataCache.newHandle(key, pRec, lease, callerSite(1), false)
+}
+
+
+// Context-aware equivalent of AcquireRecWithLease(). Gives up waiting for the record lock the way
+// GetRecContext() does.
+func (pDataCache *DataCache) AcquireRecContext(ctx context.Context, key Key, lease time.Duration) (bool, *Handle, error) {
+	if pDataCache == nil {
+		return false, nil, errors.New("Nil datacache.")
+	}
+
+	isOK, pRec, err := pDataCache.GetRecContext(ctx, key)
+	if !isOK {
+		return false, nil, err
+	}
+
+	return true, pDataCache.newHandle(key, pRec, lease, callerSite(1), false), nil
+}
+
+
+/* ****************************************************************************
+Description :
+Releases the record lock held by the handle. The handle is invalid hereon.
+
+Receiver    :
+pHandle *Handle: Handle of a locked record.
+
+Implements  : NA
+
+Arguments   : NA
+
+Return value:
+1> bool: true if the record lock is released. false if the handle has already been released
+or its lease has expired.
+
+Additional note:
+- It's safe to invoke Release() more than once, for instance, through defer.
+**************************************************************************** */
+func (pHandle *Handle) Release() bool {
+	if pHandle == nil {
+		return false
+	}
+
+	pHandle.lock.Lock()
+	defer pHandle.lock.Unlock()
+
+	if !pHandle.isValid {
+		return false
+	}
+
+	pHandle.isValid = false
+	if pHandle.pLeaseTimer != nil {
+		pHandle.pLeaseTimer.Stop()
+	}
+	pHandle.unlockRec()
+
+	return true
+}
+
+
+// Releases the record lock in the mode it's held. Caller holds handle lock.
+func (pHandle *Handle) unlockRec() {
+	if pHandle.isShared {
+		pHandle.pRec.runlock()
+		return
+	}
+
+	pHandle.pRec.unlock()
+}
+
+
+// Lease timer callback. Reclaims the record lock unless the handle has been released meanwhile.
+func (pHandle *Handle) expire() {
+	pHandle.lock.Lock()
+	if !pHandle.isValid {
+		pHandle.lock.Unlock()
+		return
+	}
+
+	pHandle.isValid = false
+	pHandle.unlockRec()
+	pHandle.lock.Unlock()
+
+	leaseExpiry := LeaseExpiry {
+		Key: pHandle.key,
+		AcquiredAt: pHandle.acquiredAt,
+		Lease: pHandle.lease,
+		Site: pHandle.site,
+	}
+
+	if pHandle.pDataCache.leaseExpiryfn != nil {
+		pHandle.pDataCache.leaseExpiryfn(leaseExpiry)
+		return
+	}
+
+	fmt.Printf("%s Lease (%s) of record %#v acquired at %s has expired. Record lock is reclaimed.\n",
+		pHandle.pDataCache.logTag(), leaseExpiry.Lease, leaseExpiry.Key, leaseExpiry.Site)
+}
+
+
+// Reports whether the handle still holds the record lock.
+func (pHandle *Handle) IsValid() bool {
+	if pHandle == nil {
+		return false
+	}
+
+	pHandle.lock.Lock()
+	defer pHandle.lock.Unlock()
+
+	return pHandle.isValid
+}
+
+
+// Key the handle was acquired with.
+func (pHandle *Handle) Key() Key {
+	if pHandle == nil {
+		return nil
+	}
+
+	return pHandle.key
+}
+
+
+/* ****************************************************************************
+Description :
+Returns payload of the record held by the handle.
+
+Receiver    :
+pHandle *Handle: Handle of a locked record.
+
+Implements  : NA
+
+Arguments   : NA
+
+Return value:
+1> bool: true if the handle is valid. false if it has been released or its lease has expired.
+2> interface{}: Payload of the record. nil if the handle isn't valid.
+
+Additional note:
+- Payload is a pointer. Updates made through it after the handle is invalidated aren't
+guarded by the record lock. Check IsValid(), or use Do(), if the lease may expire midway.
+**************************************************************************** */
+func (pHandle *Handle) Payload() (bool, interface{}) {
+	if pHandle == nil {
+		return false, nil
+	}
+
+	pHandle.lock.Lock()
+	defer pHandle.lock.Unlock()
+
+	if !pHandle.isValid {
+		return false, nil
+	}
+
+	return true, pHandle.pRec.PDataRec
+}
+
+
+// Invokes fn on the record held by the handle. The lease can't expire whilst fn is running.
+// fn shouldn't invoke Release() on the same handle. It's a deadlock otherwise. fn mustn't modify
+// the payload of a handle acquired through GetRecForRead().
+// Returns false, without invoking fn, if the handle isn't valid.
+func (pHandle *Handle) Do(fn func(*Rec)) bool {
+	if (pHandle == nil) || (fn == nil) {
+		return false
+	}
+
+	pHandle.lock.Lock()
+	defer pHandle.lock.Unlock()
+
+	if !pHandle.isValid {
+		return false
+	}
+
+	fn(pHandle.pRec)
+
+	return true
+}
@@ -0,0 +1,118 @@
+package datacache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+
+func TestHandleRelease(t *testing.T) {
+	testList := []struct {
+		name string
+		acquire func(*DataCache) (bool, *Handle)
+	}{
+		{"AcquireRec", func(pDataCache *DataCache) (bool, *Handle) {
+			return pDataCache.AcquireRec("k")
+		}},
+		{"AcquireRecWithLease", func(pDataCache *DataCache) (bool, *Handle) {
+			return pDataCache.AcquireRecWithLease("k", time.Hour)
+		}},
+		{"AcquireRecContext", func(pDataCache *DataCache) (bool, *Handle) {
+			isOK, pHandle, _ := pDataCache.AcquireRecContext(nil, "k", 0)
+			return isOK, pHandle
+		}},
+		{"GetRecForRead", func(pDataCache *DataCache) (bool, *Handle) {
+			return pDataCache.GetRecForRead("k")
+		}},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			pDataCache := Create(nil, nil)
+			defer pDataCache.Close()
+			pDataCache.AddRec([]Key{"k"}, "v", true)
+
+			isOK, pHandle := test.acquire(pDataCache)
+			if !isOK {
+				t.Fatalf("acquire failed")
+			}
+			if isOK, pDataRec := pHandle.Payload(); !isOK || (pDataRec != "v") || (pHandle.Key() != "k") {
+				t.Fatalf("Payload() = %v, %v", isOK, pDataRec)
+			}
+			if _, err := pDataCache.TryDeleteRec("k"); err == nil {
+				t.Fatalf("record isn't locked by the handle")
+			}
+
+			if !pHandle.Release() || pHandle.Release() || pHandle.IsValid() {
+				t.Fatalf("Release() isn't true exactly once")
+			}
+			if isOK, _ := pHandle.Payload(); isOK || pHandle.Do(func(*Rec) {}) {
+				t.Fatalf("released handle is usable")
+			}
+			if _, err := pDataCache.TryDeleteRec("k"); err != nil {
+				t.Fatalf("record is still locked after Release(): %v", err)
+			}
+		})
+	}
+
+	pDataCache := Create(nil, nil)
+	defer pDataCache.Close()
+	if isOK, pHandle := pDataCache.AcquireRec("nope"); isOK || (pHandle != nil) {
+		t.Fatalf("AcquireRec(nope) = %v, %v", isOK, pHandle)
+	}
+}
+
+
+// Lease expiry reclaims the record lock and reports the site the handle was acquired at.
+func TestHandleLeaseExpiry(t *testing.T) {
+	expiryChan := make(chan LeaseExpiry, 1)
+	pDataCache := Create(nil, nil, WithDefaultLease(20 * time.Millisecond), WithLeaseExpiryHandler(func(leaseExpiry LeaseExpiry) {
+		expiryChan <- leaseExpiry
+	}))
+	defer pDataCache.Close()
+	pDataCache.AddRec([]Key{"k"}, "v", true)
+
+	_, pHandle := pDataCache.AcquireRec("k")
+
+	select {
+		case leaseExpiry := <-expiryChan:
+			if (leaseExpiry.Key != "k") || (leaseExpiry.Lease != 20 * time.Millisecond) ||
+			!strings.Contains(leaseExpiry.Site, "handle_test.go") {
+				t.Fatalf("unexpected expiry %+v", leaseExpiry)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("lease hasn't expired")
+	}
+
+	if pHandle.IsValid() || pHandle.Release() {
+		t.Fatalf("expired handle is valid")
+	}
+	if _, err := pDataCache.TryDeleteRec("k"); err != nil {
+		t.Fatalf("record lock isn't reclaimed: %v", err)
+	}
+}
+
+
+// Lease can't expire whilst Do() runs.
+func TestHandleDoHoldsLease(t *testing.T) {
+	pDataCache := Create(nil, nil, WithLeaseExpiryHandler(func(LeaseExpiry) {}))
+	defer pDataCache.Close()
+	pDataCache.AddRec([]Key{"k"}, "v", true)
+
+	_, pHandle := pDataCache.AcquireRecWithLease("k", 10 * time.Millisecond)
+	var err error
+	pHandle.Do(func(pRec *Rec) {
+		time.Sleep(50 * time.Millisecond)  // well past the lease.
+		_, err = pDataCache.TryDeleteRec("k")
+	})
+	if err == nil {
+		t.Fatalf("record lock is reclaimed whilst Do() ran")
+	}
+
+	runWithTimeout(t, time.Second, func() {
+		for pHandle.IsValid() {
+			time.Sleep(time.Millisecond)
+		}
+	})
+}
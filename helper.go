@@ -18,8 +18,14 @@ Description :
 package datacache
 
 import (
+	"encoding/binary"
 	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
 	"reflect"
+	"strconv"
+	"strings"
 )
 
 
@@ -71,3 +77,137 @@ func inspectStructV(val reflect.Value) {
 		}
 	}
 }
+
+
+// Returns 64-bit FNV-1a hash of a cache key. Keys of commonly used types are hashed without
+// formatting them. Equal keys always hash to the same value.
+func hashKey(key Key) uint64 {
+	var buf [8]byte
+
+	pHash := fnv.New64a()
+	switch tmpKey := key.(type) {
+	case string:
+		pHash.Write([]byte(tmpKey))
+
+	case int:
+		binary.LittleEndian.PutUint64(buf[:], uint64(tmpKey))
+		pHash.Write(buf[:])
+
+	case int64:
+		binary.LittleEndian.PutUint64(buf[:], uint64(tmpKey))
+		pHash.Write(buf[:])
+
+	case uint64:
+		binary.LittleEndian.PutUint64(buf[:], tmpKey)
+		pHash.Write(buf[:])
+
+	case int32:
+		binary.LittleEndian.PutUint64(buf[:], uint64(tmpKey))
+		pHash.Write(buf[:])
+
+	case uint32:
+		binary.LittleEndian.PutUint64(buf[:], uint64(tmpKey))
+		pHash.Write(buf[:])
+
+	case float64:
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(normalizeFloat(tmpKey)))
+		pHash.Write(buf[:])
+
+	case float32:
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(normalizeFloat(float64(tmpKey))))
+		pHash.Write(buf[:])
+
+	default:
+		writeKey(pHash, key)
+	}
+
+	return pHash.Sum64()
+}
+
+
+// Returns canonical string form of a cache key. Equal keys always have the same form.
+func formatKey(key Key) string {
+	var sb strings.Builder
+	writeKey(&sb, key)
+	return sb.String()
+}
+
+
+// Writes canonical form of a cache key, i.e., its type followed by its value, to w. Unlike %v, pointers
+// are written as their address rather than the value they point to, and -0 is written as 0, hence,
+// the form of a key follows == of the key.
+func writeKey(w io.Writer, key Key) {
+	fmt.Fprintf(w, "%T:", key)
+	writeKeyV(w, reflect.ValueOf(key))
+}
+
+
+func writeKeyV(w io.Writer, val reflect.Value) {
+	switch val.Kind() {
+	case reflect.Invalid:
+		io.WriteString(w, "nil")
+
+	case reflect.Bool:
+		fmt.Fprintf(w, "%t", val.Bool())
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		fmt.Fprintf(w, "%d", val.Int())
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		fmt.Fprintf(w, "%d", val.Uint())
+
+	case reflect.Float32, reflect.Float64:
+		io.WriteString(w, strconv.FormatFloat(normalizeFloat(val.Float()), 'g', -1, 64))
+
+	case reflect.Complex64, reflect.Complex128:
+		c := val.Complex()
+		fmt.Fprintf(w, "(%s,%s)", strconv.FormatFloat(normalizeFloat(real(c)), 'g', -1, 64),
+			strconv.FormatFloat(normalizeFloat(imag(c)), 'g', -1, 64))
+
+	case reflect.String:
+		io.WriteString(w, strconv.Quote(val.String()))
+
+	case reflect.Ptr, reflect.Chan, reflect.UnsafePointer:
+		fmt.Fprintf(w, "0x%x", val.Pointer())
+
+	case reflect.Interface:
+		if val.IsNil() {
+			io.WriteString(w, "nil")
+		} else {
+			fmt.Fprintf(w, "%s:", val.Elem().Type())
+			writeKeyV(w, val.Elem())
+		}
+
+	case reflect.Array:
+		io.WriteString(w, "[")
+		for i := 0; i < val.Len(); i++ {
+			if i > 0 {
+				io.WriteString(w, ",")
+			}
+			writeKeyV(w, val.Index(i))
+		}
+		io.WriteString(w, "]")
+
+	case reflect.Struct:
+		io.WriteString(w, "{")
+		for i := 0; i < val.NumField(); i++ {
+			if i > 0 {
+				io.WriteString(w, ",")
+			}
+			writeKeyV(w, val.Field(i))
+		}
+		io.WriteString(w, "}")
+
+	default:  // not comparable, hence, can't be a key.
+		fmt.Fprintf(w, "%v", val)
+	}
+}
+
+
+// -0 and +0 are equal keys.
+func normalizeFloat(f float64) float64 {
+	if f == 0 {
+		return 0
+	}
+	return f
+}
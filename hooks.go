@@ -0,0 +1,249 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache
+Filename    : github.com/sameeroak1110/datacache/hooks.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Synchronous lifecycle hooks: OnInsert(), OnDelete(), OnEvict() and OnStateChange().
+Unlike Watch(), a hook runs inline, in the go-routine of the write method that's caused it,
+before the write method returns. For instance, a hook may close the file handles held in
+a payload once its record is removed.
+- Lock conditions: a hook is invoked once the write method has released every store-lock and
+record lock it's taken. Hence, a hook may call back into the cache, including write methods.
+Hooks of the nested call run before the nested call returns.
+- Hooks are raised whilst the cache is mutated, in store-locks, and are queued along with
+id of the raising go-routine. The same go-routine runs them once it releases the locks.
+Raising a hook reads the stack of the go-routine for its id. Hence, it costs about a
+microsecond per record mutation, but only while some hook is registered.
+**************************************************************************** */
+package datacache
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+)
+
+
+// Lifecycle hook. Event is the same as that of Watch(). Payload mustn't be retained beyond the hook
+// in case the hook releases resources held in it.
+type HookFunc func(Event)
+
+type hookKind int
+
+const (
+	hookInsert hookKind = iota
+	hookDelete
+	hookEvict
+	hookStateChange
+	hookKindCnt
+)
+
+type hookEntry struct {
+	id uint64
+	hookFunc HookFunc
+}
+
+type pendingHook struct {
+	goroutineID uint64  // go-routine which raised the hook. only it runs the hook.
+	kind hookKind
+	event Event
+}
+
+type hookRegistry struct {
+	lock sync.Mutex                      // guards hookList and pendingList.
+	hookList [hookKindCnt][]hookEntry
+	hookCnt [hookKindCnt]int32           // accessed atomically. hooks of a kind aren't raised while it's 0.
+	pendingList []pendingHook
+	pendingCnt int32                     // accessed atomically. len(pendingList).
+	nextID uint64
+}
+
+
+func newHookRegistry() *hookRegistry {
+	return &hookRegistry{}
+}
+
+
+/* ****************************************************************************
+Description :
+Registers hookFunc to be invoked each time a record is added to the cache, for instance,
+through AddRec(), ForceAddRec(), Load() or Upsert().
+
+Receiver    :
+pDataCache *DataCache: Instance of datacache.
+
+Implements  : NA
+
+Arguments   :
+1> hookFunc HookFunc: Hook. Cause of the event is EventInsert, EventReplace in case the
+record has been added over existing keys, or EventReload for records loaded by Reload().
+
+Return value:
+1> func(): Unregisters the hook. It's safe to invoke it more than once.
+
+Additional note:
+- Hook runs in the go-routine of the write method after the method has released all store-locks
+and record locks it's taken. The only exceptions are AddAndGetRec(), ForceAddAndGetRec() and
+ReAddAndGetRec(), which return the record locked; hook mustn't lock that record, as it's still
+held by the same go-routine.
+- Hooks of WOLock methods run once the caller releases the store-lock through WriteUnlock()
+or ReadUnlock().
+- A panic in the hook is recovered and logged. Rest of the hooks still run.
+**************************************************************************** */
+func (pDataCache *DataCache) OnInsert(hookFunc HookFunc) func() {
+	return pDataCache.addHook(hookInsert, hookFunc)
+}
+
+
+// Registers hookFunc to be invoked once a record leaves the cache other than by eviction, i.e.,
+// once its last key is removed. Cause of the event is EventDelete, EventExpire, EventClear
+// (DeleteCache()), EventReload (records dropped by Reload()), or EventReplace and EventAlias
+// in case all keys of the record have been taken over by some other record. OldPayload is the
+// payload of the removed record. Lock conditions are the same as those of OnInsert().
+func (pDataCache *DataCache) OnDelete(hookFunc HookFunc) func() {
+	return pDataCache.addHook(hookDelete, hookFunc)
+}
+
+
+// Registers hookFunc to be invoked once a record is evicted by the eviction policy of a bounded
+// cache. Lock conditions are the same as those of OnInsert().
+func (pDataCache *DataCache) OnEvict(hookFunc HookFunc) func() {
+	return pDataCache.addHook(hookEvict, hookFunc)
+}
+
+
+// Registers hookFunc to be invoked once a record is activated or deactivated through UpdateRecState()
+// and its variants. Lock conditions are the same as those of OnInsert().
+func (pDataCache *DataCache) OnStateChange(hookFunc HookFunc) func() {
+	return pDataCache.addHook(hookStateChange, hookFunc)
+}
+
+
+func (pDataCache *DataCache) addHook(kind hookKind, hookFunc HookFunc) func() {
+	if (pDataCache == nil) || (hookFunc == nil) {
+		return func() {}
+	}
+
+	pHooks := pDataCache.pHooks
+	pHooks.lock.Lock()
+	pHooks.nextID = pHooks.nextID + 1
+	id := pHooks.nextID
+	pHooks.hookList[kind] = append(pHooks.hookList[kind], hookEntry { id: id, hookFunc: hookFunc })
+	atomic.AddInt32(&pHooks.hookCnt[kind], 1)
+	pHooks.lock.Unlock()
+
+	return func() {
+		pHooks.lock.Lock()
+		defer pHooks.lock.Unlock()
+
+		for i, entry := range pHooks.hookList[kind] {
+			if entry.id == id {
+				pHooks.hookList[kind] = append(pHooks.hookList[kind][:i:i], pHooks.hookList[kind][i+1:]...)
+				atomic.AddInt32(&pHooks.hookCnt[kind], -1)
+				return
+			}
+		}
+	}
+}
+
+
+// Queues hook of the kind for the calling go-routine. Invoked whilst the cache is mutated, in store-locks.
+func (pDataCache *DataCache) raiseHook(kind hookKind, cause EventCause, keyList []Key, pOldDataRec interface{},
+pNewDataRec interface{}, isActive bool) {
+	pHooks := pDataCache.pHooks
+	if (pHooks == nil) || (atomic.LoadInt32(&pHooks.hookCnt[kind]) == 0) {
+		return
+	}
+
+	pending := pendingHook {
+		goroutineID: curGoroutineID(),
+		kind: kind,
+		event: newEvent(cause, keyList, pOldDataRec, pNewDataRec, isActive),
+	}
+
+	pHooks.lock.Lock()
+	pHooks.pendingList = append(pHooks.pendingList, pending)
+	atomic.AddInt32(&pHooks.pendingCnt, 1)
+	pHooks.lock.Unlock()
+}
+
+
+// Reports whether any hook of the kind is registered. Used to skip collecting records for raiseRecHooksWOLock().
+func (pDataCache *DataCache) isHooked(kind hookKind) bool {
+	return (pDataCache.pHooks != nil) && (atomic.LoadInt32(&pDataCache.pHooks.hookCnt[kind]) > 0)
+}
+
+
+// Raises hook of the kind for each record of recList. Used when records are added or removed in bulk,
+// for instance, by DeleteCache() and Reload(). Must be invoked in WR store-lock.
+func (pDataCache *DataCache) raiseRecHooksWOLock(kind hookKind, cause EventCause, recList []*Rec) {
+	for _, pRec := range recList {
+		if kind == hookInsert {
+			pDataCache.raiseHook(kind, cause, pRec.KeyList, nil, pRec.PDataRec, pRec.isActive)
+		} else {
+			pDataCache.raiseHook(kind, cause, pRec.KeyList, pRec.PDataRec, nil, pRec.isActive)
+		}
+	}
+}
+
+
+// Runs hooks raised by the calling go-routine, in the order they've been raised. Must be invoked once
+// all store-locks and record locks taken by the write method are released.
+func (pDataCache *DataCache) runHooks() {
+	pHooks := pDataCache.pHooks
+	if (pHooks == nil) || (atomic.LoadInt32(&pHooks.pendingCnt) == 0) {
+		return
+	}
+
+	id := curGoroutineID()
+	var myList []pendingHook
+
+	pHooks.lock.Lock()
+	tmpList := pHooks.pendingList[:0]
+	for _, pending := range pHooks.pendingList {
+		if pending.goroutineID == id {
+			myList = append(myList, pending)
+		} else {
+			tmpList = append(tmpList, pending)
+		}
+	}
+	for i := len(tmpList); i < len(pHooks.pendingList); i++ {
+		pHooks.pendingList[i] = pendingHook{}  // payloads aren't retained.
+	}
+	pHooks.pendingList = tmpList
+	atomic.AddInt32(&pHooks.pendingCnt, -int32(len(myList)))
+	pHooks.lock.Unlock()
+
+	for _, pending := range myList {
+		pHooks.lock.Lock()
+		hookList := pHooks.hookList[pending.kind]  // later registrations don't change this snapshot.
+		pHooks.lock.Unlock()
+
+		for _, entry := range hookList {
+			pDataCache.callHook(entry.hookFunc, pending.event)
+		}
+	}
+}
+
+
+func (pDataCache *DataCache) callHook(hookFunc HookFunc, event Event) {
+	defer func() {
+		if err1 := recover(); err1 != nil {
+			fmt.Println(pDataCache.logTag(), "Recovered from panic in", event.Cause, "hook:", err1)
+			debug.PrintStack()
+		}
+	}()
+
+	hookFunc(event)
+}
@@ -0,0 +1,202 @@
+package datacache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+
+// Runs fn in a go-routine and fails the test in case it doesn't return in time, for instance, because
+// a hook calling back into the cache has deadlocked.
+func runWithTimeout(t *testing.T, timeout time.Duration, fn func()) {
+	t.Helper()
+
+	doneChan := make(chan struct{})
+	go func() {
+		defer close(doneChan)
+		fn()
+	}()
+
+	select {
+	case <-doneChan:
+	case <-time.After(timeout):
+		t.Fatalf("didn't return in %v; deadlock?", timeout)
+	}
+}
+
+
+// Hook calls AddRec(), GetRec() and DeleteRec() on the cache which has raised it. Each write path has to
+// release its store-locks and record locks before it runs the hook.
+func TestHookCallsBack(t *testing.T) {
+	testList := []struct {
+		name string
+		opts []Option
+		add func(*DataCache) error
+	}{
+		{"single shard", nil, func(pDataCache *DataCache) error {
+			_, err := pDataCache.AddRec([]Key{"k"}, "v", true)
+			return err
+		}},
+		{"sharded", []Option{WithShards(8)}, func(pDataCache *DataCache) error {
+			_, err := pDataCache.AddRec([]Key{"k", "k2"}, "v", true)
+			return err
+		}},
+		{"copy-on-write", []Option{WithCopyOnWrite(0)}, func(pDataCache *DataCache) error {
+			_, err := pDataCache.AddRec([]Key{"k"}, "v", true)
+			return err
+		}},
+		{"WriteLock and WOLock", []Option{WithShards(8)}, func(pDataCache *DataCache) error {
+			pDataCache.WriteLock()
+			defer pDataCache.WriteUnlock()
+			_, err := pDataCache.AddRecWOLock([]Key{"k"}, "v", true)
+			return err
+		}},
+		{"txn commit", []Option{WithShards(8)}, func(pDataCache *DataCache) error {
+			pTxn, err := pDataCache.BeginTxn(nil, []Key{"k"})
+			if err != nil {
+				return err
+			}
+			defer pTxn.Rollback()
+			if err = pTxn.Add([]Key{"k"}, "v"); err != nil {
+				return err
+			}
+			return pTxn.Commit()
+		}},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			pDataCache := Create(nil, nil, test.opts...)
+			defer pDataCache.Close()
+
+			var lock sync.Mutex
+			var errList []string
+			isHookDone := false
+			deleteCnt := 0
+
+			pDataCache.OnInsert(func(event Event) {
+				if event.KeyList[0] != "k" {
+					return
+				}
+
+				var tmpErrList []string
+				if isOK, pRec := pDataCache.GetRec("k"); !isOK {
+					tmpErrList = append(tmpErrList, "GetRec(k) failed in the hook")
+				} else {
+					pRec.DataCacheRecUnlock()
+				}
+				if _, err := pDataCache.AddRec([]Key{"nested"}, "n", true); err != nil {
+					tmpErrList = append(tmpErrList, "AddRec: " + err.Error())
+				}
+				if _, err := pDataCache.DeleteRec("nested"); err != nil {
+					tmpErrList = append(tmpErrList, "DeleteRec: " + err.Error())
+				}
+
+				lock.Lock()  // not held above; the nested delete runs the delete hook, which takes it.
+				errList = tmpErrList
+				isHookDone = true
+				lock.Unlock()
+			})
+			pDataCache.OnDelete(func(event Event) {
+				lock.Lock()
+				deleteCnt++
+				lock.Unlock()
+			})
+
+			runWithTimeout(t, 5 * time.Second, func() {
+				if err := test.add(pDataCache); err != nil {
+					t.Errorf("add: %v", err)
+				}
+			})
+
+			lock.Lock()
+			defer lock.Unlock()
+			if !isHookDone {
+				t.Fatalf("insert hook hasn't run before the write returned")
+			}
+			for _, errStr := range errList {
+				t.Error(errStr)
+			}
+			if deleteCnt != 1 {  // nested delete hook runs before the nested DeleteRec() returns.
+				t.Fatalf("delete hook ran %d times, want 1", deleteCnt)
+			}
+			if !pDataCache.DoesKeyExist("k") || pDataCache.DoesKeyExist("nested") {
+				t.Fatalf("unexpected cache contents")
+			}
+		})
+	}
+}
+
+
+// Pending hooks are keyed by id of the go-routine which raised them. WOLock methods raise hooks under the
+// caller's WriteLock() and the hooks run in WriteUnlock(), which is a separate call of the public API; the
+// go-routine is the only thing the two calls share. Passing a pending list through the unlock path would
+// change the signatures of WriteUnlock() and every WOLock method. Lock debug mode relies on the same id.
+// Hence, the id must be stable within a go-routine and distinct across go-routines.
+func TestCurGoroutineID(t *testing.T) {
+	id := curGoroutineID()
+	if (id == 0) || (id != curGoroutineID()) {
+		t.Fatalf("curGoroutineID() = %d, not stable", id)
+	}
+
+	const goroutineCnt = 16
+	idChan := make(chan uint64, goroutineCnt)
+	var wg sync.WaitGroup
+	for i := 0; i < goroutineCnt; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			idChan <- curGoroutineID()
+		}()
+	}
+	wg.Wait()
+	close(idChan)
+
+	isSeenMap := map[uint64]bool { id: true }
+	for tmpID := range idChan {
+		if (tmpID == 0) || isSeenMap[tmpID] {
+			t.Fatalf("curGoroutineID() = %d, not distinct", tmpID)
+		}
+		isSeenMap[tmpID] = true
+	}
+}
+
+
+// Hooks raised by one go-routine are run by it alone, even though other go-routines dispatch meanwhile.
+func TestHookRunsInRaisingGoroutine(t *testing.T) {
+	pDataCache := Create(nil, nil, WithShards(8))
+	defer pDataCache.Close()
+
+	var lock sync.Mutex
+	idMap := make(map[Key]uint64)
+	pDataCache.OnInsert(func(event Event) {
+		lock.Lock()
+		idMap[event.KeyList[0]] = curGoroutineID()
+		lock.Unlock()
+	})
+
+	const goroutineCnt = 8
+	var wg sync.WaitGroup
+	wantIDList := make([]uint64, goroutineCnt)
+	for i := 0; i < goroutineCnt; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			wantIDList[i] = curGoroutineID()
+			for j := 0; j < 100; j++ {
+				pDataCache.AddRec([]Key{i * 1000 + j}, j, true)
+			}
+		}(i)
+	}
+	runWithTimeout(t, 5 * time.Second, wg.Wait)
+
+	for key, id := range idMap {
+		if want := wantIDList[key.(int) / 1000]; id != want {
+			t.Fatalf("hook of key %v ran in go-routine %d, want %d", key, id, want)
+		}
+	}
+	if len(idMap) != goroutineCnt * 100 {
+		t.Fatalf("%d hooks ran, want %d", len(idMap), goroutineCnt * 100)
+	}
+}
@@ -0,0 +1,143 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache
+Filename    : github.com/sameeroak1110/datacache/lfu.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Least-frequently-used eviction policy.
+- Records are kept in buckets of equal hit count. Buckets are ordered by ascending
+hit count. Hence, insert, hit and delete are O(1). Within a bucket, least-recently
+promoted record is evicted first.
+**************************************************************************** */
+package datacache
+
+import (
+	"container/list"
+	"sync"
+)
+
+
+type lfuBucket struct {
+	freq uint64
+	pRecList *list.List       // records with freq hits. front is the most recent one.
+}
+
+type lfuEntry struct {
+	pBucketElem *list.Element  // bucket the record belongs to.
+	pRecElem *list.Element     // position of the record within its bucket.
+}
+
+type lfuPolicy struct {
+	lock sync.Mutex
+	pBucketList *list.List     // buckets in ascending order of freq.
+	entryMap map[*Rec]*lfuEntry
+}
+
+
+// Returns least-frequently-used eviction policy.
+func NewLFUPolicy() EvictionPolicy {
+	return &lfuPolicy {
+		pBucketList: list.New(),
+		entryMap: make(map[*Rec]*lfuEntry),
+	}
+}
+
+
+// Removes the record from its bucket. Bucket is removed once it's empty.
+func (pPolicy *lfuPolicy) unlink(pEntry *lfuEntry) {
+	pBucket := pEntry.pBucketElem.Value.(*lfuBucket)
+	pBucket.pRecList.Remove(pEntry.pRecElem)
+	if pBucket.pRecList.Len() == 0 {
+		pPolicy.pBucketList.Remove(pEntry.pBucketElem)
+	}
+}
+
+
+func (pPolicy *lfuPolicy) OnInsert(pRec *Rec) {
+	pPolicy.lock.Lock()
+	defer pPolicy.lock.Unlock()
+
+	if _, isOK := pPolicy.entryMap[pRec]; isOK {
+		return
+	}
+
+	pBucketElem := pPolicy.pBucketList.Front()
+	if (pBucketElem == nil) || (pBucketElem.Value.(*lfuBucket).freq != 1) {
+		pBucketElem = pPolicy.pBucketList.PushFront(&lfuBucket {
+			freq: 1,
+			pRecList: list.New(),
+		})
+	}
+
+	pPolicy.entryMap[pRec] = &lfuEntry {
+		pBucketElem: pBucketElem,
+		pRecElem: pBucketElem.Value.(*lfuBucket).pRecList.PushFront(pRec),
+	}
+}
+
+
+func (pPolicy *lfuPolicy) OnHit(pRec *Rec) {
+	pPolicy.lock.Lock()
+	defer pPolicy.lock.Unlock()
+
+	pEntry, isOK := pPolicy.entryMap[pRec]
+	if !isOK {
+		return
+	}
+
+	freq := pEntry.pBucketElem.Value.(*lfuBucket).freq + 1
+	pNextElem := pEntry.pBucketElem.Next()
+	if (pNextElem == nil) || (pNextElem.Value.(*lfuBucket).freq != freq) {
+		pNextElem = pPolicy.pBucketList.InsertAfter(&lfuBucket {
+			freq: freq,
+			pRecList: list.New(),
+		}, pEntry.pBucketElem)
+	}
+
+	pPolicy.unlink(pEntry)
+	pEntry.pBucketElem = pNextElem
+	pEntry.pRecElem = pNextElem.Value.(*lfuBucket).pRecList.PushFront(pRec)
+}
+
+
+func (pPolicy *lfuPolicy) OnDelete(pRec *Rec) {
+	pPolicy.lock.Lock()
+	defer pPolicy.lock.Unlock()
+
+	if pEntry, isOK := pPolicy.entryMap[pRec]; isOK {
+		pPolicy.unlink(pEntry)
+		delete(pPolicy.entryMap, pRec)
+	}
+}
+
+
+func (pPolicy *lfuPolicy) Victim(isEvictable func(*Rec) bool) *Rec {
+	pPolicy.lock.Lock()
+	defer pPolicy.lock.Unlock()
+
+	for pBucketElem := pPolicy.pBucketList.Front(); pBucketElem != nil; pBucketElem = pBucketElem.Next() {
+		if pRec := victimFromBack(pBucketElem.Value.(*lfuBucket).pRecList, isEvictable); pRec != nil {
+			return pRec
+		}
+	}
+
+	return nil
+}
+
+
+func (pPolicy *lfuPolicy) Reset() {
+	pPolicy.lock.Lock()
+	defer pPolicy.lock.Unlock()
+
+	pPolicy.pBucketList.Init()
+	pPolicy.entryMap = make(map[*Rec]*lfuEntry)
+}
@@ -0,0 +1,181 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache
+Filename    : github.com/sameeroak1110/datacache/loader.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Read-through loading of a record on cache miss.
+- Concurrent misses for the same key are collapsed into a single loader call
+(singleflight). Go-routines missing the same key whilst the loader is running
+wait for and share its result. Hence, a cold key doesn't stampede the database.
+**************************************************************************** */
+package datacache
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+
+// Loads a single record on cache miss. Returned Payload should have key in its KeyList. key is
+// added otherwise. Payload with nil PDataRec means there's no record for key.
+type KeyLoadFunc func(key Key) (Payload, error)
+
+// an in-flight loader call. waiters block on wg.
+type loadCall struct {
+	wg sync.WaitGroup
+	isFound bool
+	pDataRec interface{}
+	err error
+}
+
+
+// Per-key loader invoked by GetOrLoad() on cache miss.
+func WithKeyLoader(keyLoadFunc KeyLoadFunc) Option {
+	return func(pDataCache *DataCache) {
+		pDataCache.keyloadfn = keyLoadFunc
+	}
+}
+
+
+/* ****************************************************************************
+Description :
+Returns payload of the cache record referred to by key. On cache miss, invokes the
+per-key loader, adds the loaded record (against all its keys) in the cache and returns
+its payload.
+
+Receiver    :
+pDataCache *DataCache: Instance of datacache.
+
+Implements  : NA
+
+Arguments   :
+1> key Key: Key to fetch cache record.
+
+Return value:
+1> bool: true if the record is found or loaded. false otherwise.
+2> interface{}: Payload of the record. nil if it isn't found.
+3> error: Error returned by the loader, or error in case there's no loader.
+
+Additional note:
+- Method takes RD store-lock and, on cache miss, WR store-lock. Caller go-routine shouldn't invoke
+this method in any store-lock. It's a deadlock otherwise.
+- Loader is invoked without any store-lock. Only one loader call per key is in flight at a time.
+Go-routines missing the same key meanwhile share the result of that call.
+- If some other go-routine adds a record for key whilst the loader is running, that record is
+retained and the loaded one is discarded. Likewise, the loaded record isn't added against any other
+of its keys which refers to some record by then.
+- A panic in the loader is recovered. It's reported as error to the caller and to the go-routines
+waiting for the same key.
+**************************************************************************** */
+func (pDataCache *DataCache) GetOrLoad(key Key) (isFound bool, pDataRec interface{}, err error) {
+	if pDataCache == nil {
+		return false, nil, errors.New("Nil datacache.")
+	}
+
+	if isOK, pTmpDataRec := pDataCache.GetDataRec(key); isOK {
+		return true, pTmpDataRec, nil
+	}
+
+	if pDataCache.keyloadfn == nil {
+		return false, nil, errors.New("Nil datacache key loader.")
+	}
+
+	pDataCache.loadLock.Lock()
+	if pCall, isOK := pDataCache.loadCallMap[key]; isOK {  // some other go-routine is loading the same key.
+		pDataCache.loadLock.Unlock()
+		pCall.wg.Wait()
+		return pCall.isFound, pCall.pDataRec, pCall.err
+	}
+
+	pCall := &loadCall {}
+	pCall.wg.Add(1)
+	pDataCache.loadCallMap[key] = pCall
+	pDataCache.loadLock.Unlock()
+
+	defer func() {
+		if err1 := recover(); err1 != nil {
+			pCall.isFound = false
+			pCall.pDataRec = nil
+			pCall.err = errors.New(fmt.Sprintf("Key loader panicked: %v", err1))
+			debug.PrintStack()
+			isFound, pDataRec, err = pCall.isFound, pCall.pDataRec, pCall.err
+		}
+
+		pDataCache.loadLock.Lock()
+		delete(pDataCache.loadCallMap, key)
+		pDataCache.loadLock.Unlock()
+		pCall.wg.Done()
+	}()
+
+	// some other go-routine may've loaded key in between the miss and loadLock, and already be done with it.
+	pRec := pDataCache.rlockKeyAndRec(key, true)
+	if pRec != nil {
+		pCall.isFound, pCall.pDataRec = true, pRec.PDataRec
+		pRec.runlock()
+	}
+	pDataCache.runlockKey(key)
+
+	if pRec == nil {
+		pCall.isFound, pCall.pDataRec, pCall.err = pDataCache.load(key)
+	}
+
+	return pCall.isFound, pCall.pDataRec, pCall.err
+}
+
+
+// Invokes the loader for key and adds the loaded record in the cache. Invoked without any store-lock.
+func (pDataCache *DataCache) load(key Key) (bool, interface{}, error) {
+	payload, err := pDataCache.keyloadfn(key)
+	if err != nil {
+		return false, nil, err
+	}
+
+	if payload.PDataRec == nil {
+		return false, nil, nil
+	}
+
+	keyList := payload.KeyList
+	isKeyFound := false
+	for i := range keyList {
+		if keyList[i] == key {
+			isKeyFound = true
+			break
+		}
+	}
+	if !isKeyFound {
+		keyList = append(keyList[:len(keyList):len(keyList)], key)  // copied, so that the loader's KeyList isn't written to.
+	}
+
+	unlock := pDataCache.lockKeys(keyList)
+	defer unlock()
+
+	now := time.Now()
+	if pRec, isOK := pDataCache.lookupWOLock(key); isOK && !pRec.isExpired(now) {  // added meanwhile.
+		return true, pRec.PDataRec, nil
+	}
+
+	// other keys added meanwhile are retained, the loaded record is added against the free ones only.
+	freeKeyList := make([]Key, 0, len(keyList))
+	for _, tmpKey := range keyList {
+		if pRec, isOK := pDataCache.lookupWOLock(tmpKey); !isOK || pRec.isExpired(now) {
+			freeKeyList = append(freeKeyList, tmpKey)
+		}
+	}
+
+	pDataCache.storeRecWOLock(pDataCache.newRec(freeKeyList, payload.PDataRec, payload.TTL))
+
+	return true, payload.PDataRec, nil
+}
@@ -0,0 +1,145 @@
+package datacache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+
+func TestGetOrLoad(t *testing.T) {
+	testList := []struct {
+		name string
+		keyLoadFunc KeyLoadFunc
+		isFound bool
+		pDataRec interface{}
+		isErr bool
+	}{
+		{"loaded", func(key Key) (Payload, error) {
+			return Payload { KeyList: []Key{key}, PDataRec: "v" }, nil
+		}, true, "v", false},
+		{"key added to KeyList", func(key Key) (Payload, error) {
+			return Payload { KeyList: []Key{"alias"}, PDataRec: "v" }, nil
+		}, true, "v", false},
+		{"not found", func(key Key) (Payload, error) {
+			return Payload{}, nil
+		}, false, nil, false},
+		{"loader error", func(key Key) (Payload, error) {
+			return Payload{}, errors.New("db down")
+		}, false, nil, true},
+		{"loader panic", func(key Key) (Payload, error) {
+			panic("boom")
+		}, false, nil, true},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			pDataCache := Create(nil, nil, WithKeyLoader(test.keyLoadFunc))
+			defer pDataCache.Close()
+
+			isFound, pDataRec, err := pDataCache.GetOrLoad("k")
+			if (isFound != test.isFound) || (pDataRec != test.pDataRec) || ((err != nil) != test.isErr) {
+				t.Fatalf("GetOrLoad() = %v, %v, %v; want %v, %v, error %v", isFound, pDataRec, err,
+					test.isFound, test.pDataRec, test.isErr)
+			}
+			if pDataCache.DoesKeyExist("k") != test.isFound {
+				t.Fatalf("DoesKeyExist(k) = %v, want %v", !test.isFound, test.isFound)
+			}
+		})
+	}
+}
+
+
+// Key appended to KeyList of the loaded payload mustn't be written into the loader's backing array.
+func TestGetOrLoadKeyListCopy(t *testing.T) {
+	keyList := make([]Key, 1, 4)
+	keyList[0] = "alias"
+	pDataCache := Create(nil, nil, WithKeyLoader(func(key Key) (Payload, error) {
+		return Payload { KeyList: keyList, PDataRec: "v" }, nil
+	}))
+	defer pDataCache.Close()
+
+	pDataCache.GetOrLoad("k")
+	if tmpKey := keyList[:2][1]; tmpKey != nil {
+		t.Fatalf("loader's KeyList backing array has been written to: %v", tmpKey)
+	}
+	if !pDataCache.DoesKeyExist("alias") || !pDataCache.DoesKeyExist("k") {
+		t.Fatalf("record isn't added against both keys")
+	}
+}
+
+
+// Concurrent misses of a key invoke the loader once.
+func TestGetOrLoadSingleflight(t *testing.T) {
+	var loadCnt int32
+	pDataCache := Create(nil, nil, WithKeyLoader(func(key Key) (Payload, error) {
+		atomic.AddInt32(&loadCnt, 1)
+		time.Sleep(20 * time.Millisecond)
+		return Payload { KeyList: []Key{key}, PDataRec: "v" }, nil
+	}))
+	defer pDataCache.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, pDataRec, err := pDataCache.GetOrLoad("k"); (pDataRec != "v") || (err != nil) {
+				t.Errorf("GetOrLoad() = %v, %v", pDataRec, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if loadCnt != 1 {
+		t.Fatalf("loader invoked %d times, want 1", loadCnt)
+	}
+}
+
+
+// Record added in between the miss and loadLock isn't loaded again.
+func TestGetOrLoadRecheck(t *testing.T) {
+	var loadCnt int32
+	pDataCache := Create(nil, nil, WithKeyLoader(func(key Key) (Payload, error) {
+		atomic.AddInt32(&loadCnt, 1)
+		return Payload { KeyList: []Key{key}, PDataRec: "loaded" }, nil
+	}))
+	defer pDataCache.Close()
+
+	pDataCache.loadLock.Lock()
+	resultChan := make(chan interface{}, 1)
+	go func() {
+		_, pDataRec, _ := pDataCache.GetOrLoad("k")
+		resultChan <- pDataRec
+	}()
+	time.Sleep(20 * time.Millisecond)  // GetOrLoad() has missed and waits for loadLock by now.
+	pDataCache.AddRec([]Key{"k"}, "added", true)
+	pDataCache.loadLock.Unlock()
+
+	if pDataRec := <-resultChan; (pDataRec != "added") || (loadCnt != 0) {
+		t.Fatalf("GetOrLoad() = %v with %d loads, want added with none", pDataRec, loadCnt)
+	}
+}
+
+
+// Record added against another key of the loaded record whilst the loader is running is retained.
+func TestGetOrLoadAliasAddedMeanwhile(t *testing.T) {
+	pDataCache := Create(nil, nil)
+	defer pDataCache.Close()
+	pDataCache.keyloadfn = func(key Key) (Payload, error) {
+		pDataCache.AddRec([]Key{"alias"}, "added", true)
+		return Payload { KeyList: []Key{key, "alias"}, PDataRec: "loaded" }, nil
+	}
+
+	if _, pDataRec, err := pDataCache.GetOrLoad("k"); (pDataRec != "loaded") || (err != nil) {
+		t.Fatalf("GetOrLoad(k) = %v, %v; want loaded", pDataRec, err)
+	}
+	if _, pDataRec := pDataCache.GetDataRec("alias"); pDataRec != "added" {
+		t.Fatalf("GetDataRec(alias) = %v, want added", pDataRec)
+	}
+	if _, pDataRec := pDataCache.GetDataRec("k"); pDataRec != "loaded" {
+		t.Fatalf("GetDataRec(k) = %v, want loaded", pDataRec)
+	}
+}
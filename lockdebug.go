@@ -0,0 +1,288 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache
+Filename    : github.com/sameeroak1110/datacache/lockdebug.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Lock debug mode. Each record lock and store-lock (ReadLock()/WriteLock()) held in the
+debug mode records the holding go-routine, its stack and the acquisition time.
+- DumpLocks() reports the locks being held. The watchdog go-routine logs the locks held
+longer than the threshold, so that leaked locks can be found in production.
+- Debug mode is off by default. It's turned on through WithLockDebug(). It's costly as the
+stack is captured with every lock.
+**************************************************************************** */
+package datacache
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+
+const maxLockStackSize = 8 << 10
+
+const (
+	lockKindRec = "record"
+	lockKindRecRead = "record-read"
+	lockKindStoreRead = "store-read"
+	lockKindStoreWrite = "store-write"
+)
+
+type lockHolder struct {
+	kind string
+	pRec *Rec                // nil for store-locks.
+	goroutineID uint64
+	stack []byte
+	acquiredAt time.Time
+	isReported bool          // true once reported by the watchdog.
+}
+
+type lockTracker struct {
+	lock sync.Mutex
+	holderMap map[*Rec][]*lockHolder  // keyed by record. nil key for store-lock. rd locks may be held many times.
+	threshold time.Duration           // locks held longer are logged by the watchdog. 0 if there's no watchdog.
+}
+
+
+func newLockTracker(threshold time.Duration) *lockTracker {
+	return &lockTracker {
+		holderMap: make(map[*Rec][]*lockHolder),
+		threshold: threshold,
+	}
+}
+
+
+// Turns on lock debug mode. threshold > 0 starts the watchdog which logs, once, each lock held
+// longer than threshold. The watchdog runs every threshold, until Close() is invoked.
+func WithLockDebug(threshold time.Duration) Option {
+	return func(pDataCache *DataCache) {
+		if threshold < 0 {
+			threshold = 0
+		}
+		pDataCache.pLockTracker = newLockTracker(threshold)
+	}
+}
+
+
+// Parses id of the go-routine out of the first line of its stack, "goroutine 42 [running]:".
+func goroutineID(stack []byte) uint64 {
+	stack = bytes.TrimPrefix(stack, []byte("goroutine "))
+	if i := bytes.IndexByte(stack, ' '); i > 0 {
+		id, _ := strconv.ParseUint(string(stack[:i]), 10, 64)
+		return id
+	}
+
+	return 0
+}
+
+
+// Returns id of the calling go-routine.
+func curGoroutineID() uint64 {
+	stack := make([]byte, 64)
+	return goroutineID(stack[:runtime.Stack(stack, false)])
+}
+
+
+func newLockHolder(kind string, pRec *Rec) *lockHolder {
+	stack := make([]byte, maxLockStackSize)
+	stack = stack[:runtime.Stack(stack, false)]
+
+	return &lockHolder {
+		kind: kind,
+		pRec: pRec,
+		goroutineID: goroutineID(stack),
+		stack: stack,
+		acquiredAt: time.Now(),
+	}
+}
+
+
+// Records the calling go-routine as a holder of the lock. pRec is nil for store-lock.
+func (pTracker *lockTracker) locked(pRec *Rec, kind string) {
+	if pTracker != nil {
+		pHolder := newLockHolder(kind, pRec)
+		pTracker.lock.Lock()
+		pTracker.holderMap[pRec] = append(pTracker.holderMap[pRec], pHolder)
+		pTracker.lock.Unlock()
+	}
+}
+
+
+// Forgets the latest lock of the kind held by the calling go-routine. In case the lock is
+// released by some other go-routine, for instance, on lease expiry, the oldest lock of the
+// kind is forgotten.
+func (pTracker *lockTracker) unlocked(pRec *Rec, kind string) {
+	if pTracker == nil {
+		return
+	}
+
+	id := curGoroutineID()
+
+	pTracker.lock.Lock()
+	defer pTracker.lock.Unlock()
+
+	holderList := pTracker.holderMap[pRec]
+	idx := -1
+	for i := len(holderList) - 1; i >= 0; i-- {
+		if holderList[i].kind != kind {
+			continue
+		}
+		if holderList[i].goroutineID == id {
+			idx = i
+			break
+		}
+		idx = i  // oldest of the kind, unless the calling go-routine's is found.
+	}
+
+	if idx < 0 {
+		return
+	}
+
+	holderList = append(holderList[:idx], holderList[idx + 1:]...)
+	if len(holderList) == 0 {
+		delete(pTracker.holderMap, pRec)
+		return
+	}
+	pTracker.holderMap[pRec] = holderList
+}
+
+
+// Returns holders of all locks, oldest first. If isUnreportedOnly is true, only the holders which
+// have crossed threshold and haven't been reported are returned, and are marked reported.
+func (pTracker *lockTracker) holders(isUnreportedOnly bool) []lockHolder {
+	pTracker.lock.Lock()
+	defer pTracker.lock.Unlock()
+
+	now := time.Now()
+	holderList := make([]lockHolder, 0)
+	collect := func(pHolder *lockHolder) {
+		if isUnreportedOnly {
+			if pHolder.isReported || (now.Sub(pHolder.acquiredAt) < pTracker.threshold) {
+				return
+			}
+			pHolder.isReported = true
+		}
+		holderList = append(holderList, *pHolder)
+	}
+
+	for _, tmpHolderList := range pTracker.holderMap {
+		for _, pHolder := range tmpHolderList {
+			collect(pHolder)
+		}
+	}
+
+	sort.Slice(holderList, func(i, j int) bool {
+		return holderList[i].acquiredAt.Before(holderList[j].acquiredAt)
+	})
+
+	return holderList
+}
+
+
+/* ****************************************************************************
+Description :
+Formats lock holders. Keys of a locked record are read only if RD store-lock can be taken
+right away, so that a leaked WR store-lock doesn't hang the report.
+
+Receiver    :
+pDataCache *DataCache: Instance of datacache.
+
+Implements  : NA
+
+Arguments   :
+1> holderList []lockHolder: Lock holders.
+
+Return value:
+1> string: Report, one lock per paragraph.
+
+Additional note: NA
+**************************************************************************** */
+func (pDataCache *DataCache) formatLockHolders(holderList []lockHolder) string {
+	var sb strings.Builder
+
+	isStoreLocked := pDataCache.tryRLockStore()
+	now := time.Now()
+	for _, holder := range holderList {
+		fmt.Fprintf(&sb, "%s lock held by goroutine %d for %s (since %s)", holder.kind, holder.goroutineID,
+			now.Sub(holder.acquiredAt), holder.acquiredAt.Format(time.RFC3339Nano))
+		if holder.pRec != nil {
+			if isStoreLocked {
+				fmt.Fprintf(&sb, ", keys %#v", holder.pRec.KeyList)
+			} else {
+				sb.WriteString(", keys unknown as store-lock is held")
+			}
+		}
+		sb.WriteString(":\n")
+		sb.Write(holder.stack)
+		sb.WriteString("\n")
+	}
+	if isStoreLocked {
+		pDataCache.runlockStore()
+	}
+
+	return sb.String()
+}
+
+
+/* ****************************************************************************
+Description :
+Reports all record locks and store-locks being held, oldest first, along with the stack
+of the go-routine which took each lock.
+
+Receiver    :
+pDataCache *DataCache: Instance of datacache.
+
+Implements  : NA
+
+Arguments   : NA
+
+Return value:
+1> string: Report. Empty if nothing is held or lock debug mode is off.
+
+Additional note:
+- Method doesn't block on store-lock. It can be invoked in any store-lock, for instance,
+from a signal handler or an admin endpoint of a hung server.
+**************************************************************************** */
+func (pDataCache *DataCache) DumpLocks() string {
+	if (pDataCache == nil) || (pDataCache.pLockTracker == nil) {
+		return ""
+	}
+
+	return pDataCache.formatLockHolders(pDataCache.pLockTracker.holders(false))
+}
+
+
+// Watchdog go-routine. Logs locks held longer than threshold until Close() is invoked.
+func (pDataCache *DataCache) lockWatchdog() {
+	ticker := time.NewTicker(pDataCache.pLockTracker.threshold)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-pDataCache.pStopChan:
+			return
+
+		case <-ticker.C:
+			holderList := pDataCache.pLockTracker.holders(true)
+			if len(holderList) > 0 {
+				fmt.Printf("%s %d lock(s) held longer than %s:\n%s", pDataCache.logTag(), len(holderList),
+					pDataCache.pLockTracker.threshold, pDataCache.formatLockHolders(holderList))
+			}
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package datacache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+
+func TestDumpLocks(t *testing.T) {
+	testList := []struct {
+		name string
+		lock func(*DataCache) func()    // returns the unlock function.
+		reportList []string             // expected substrings of the report.
+	}{
+		{"record", func(pDataCache *DataCache) func() {
+			_, pRec := pDataCache.GetRec("k")
+			return pRec.DataCacheRecUnlock
+		}, []string{"record lock held by goroutine", `keys []datacache.Key{"k"}`, "lockdebug_test.go"}},
+		{"record read", func(pDataCache *DataCache) func() {
+			_, pHandle := pDataCache.GetRecForRead("k")
+			return func() { pHandle.Release() }
+		}, []string{"record-read lock held by goroutine"}},
+		{"store read", func(pDataCache *DataCache) func() {
+			pDataCache.ReadLock()
+			return pDataCache.ReadUnlock
+		}, []string{"store-read lock held by goroutine"}},
+		{"store write", func(pDataCache *DataCache) func() {
+			pDataCache.WriteLock()
+			return pDataCache.WriteUnlock
+		}, []string{"store-write lock held by goroutine"}},
+		{"record whilst store write", func(pDataCache *DataCache) func() {
+			_, pRec := pDataCache.GetRec("k")
+			pDataCache.WriteLock()
+			return func() {
+				pDataCache.WriteUnlock()
+				pRec.DataCacheRecUnlock()
+			}
+		}, []string{"keys unknown as store-lock is held", "store-write lock"}},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			pDataCache := Create(nil, nil, WithLockDebug(0))
+			defer pDataCache.Close()
+			pDataCache.AddRec([]Key{"k"}, "v", true)
+
+			if report := pDataCache.DumpLocks(); report != "" {
+				t.Fatalf("report before locking:\n%s", report)
+			}
+
+			unlock := test.lock(pDataCache)
+			report := pDataCache.DumpLocks()
+			for _, str := range test.reportList {
+				if !strings.Contains(report, str) {
+					t.Errorf("report lacks %q:\n%s", str, report)
+				}
+			}
+
+			unlock()
+			if report = pDataCache.DumpLocks(); report != "" {
+				t.Fatalf("report after unlocking:\n%s", report)
+			}
+		})
+	}
+}
+
+
+// Record lock reclaimed by the lease timer, in some other go-routine, is forgotten as well.
+func TestDumpLocksLeaseExpiry(t *testing.T) {
+	expiryChan := make(chan LeaseExpiry, 1)
+	pDataCache := Create(nil, nil, WithLockDebug(0), WithLeaseExpiryHandler(func(leaseExpiry LeaseExpiry) {
+		expiryChan <- leaseExpiry
+	}))
+	defer pDataCache.Close()
+	pDataCache.AddRec([]Key{"k"}, "v", true)
+
+	pDataCache.AcquireRecWithLease("k", 10 * time.Millisecond)
+	<-expiryChan
+	if report := pDataCache.DumpLocks(); report != "" {
+		t.Fatalf("reclaimed lock is reported:\n%s", report)
+	}
+}
+
+
+// Watchdog reports each lock once, after it's crossed the threshold.
+func TestLockTrackerReportsOnce(t *testing.T) {
+	pTracker := newLockTracker(10 * time.Millisecond)
+	pTracker.locked(nil, lockKindStoreRead)
+
+	if holderList := pTracker.holders(true); len(holderList) != 0 {
+		t.Fatalf("%d holders reported before the threshold", len(holderList))
+	}
+	time.Sleep(20 * time.Millisecond)
+	if holderList := pTracker.holders(true); len(holderList) != 1 {
+		t.Fatalf("%d holders reported, want 1", len(holderList))
+	}
+	if holderList := pTracker.holders(true); len(holderList) != 0 {
+		t.Fatalf("holder reported twice")
+	}
+
+	pTracker.unlocked(nil, lockKindStoreRead)
+	if holderList := pTracker.holders(false); len(holderList) != 0 {
+		t.Fatalf("%d holders after unlock", len(holderList))
+	}
+}
@@ -0,0 +1,215 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache
+Filename    : github.com/sameeroak1110/datacache/metrics.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- http.Handler exporting metrics of a set of named caches in the Prometheus text
+exposition format (version 0.0.4).
+- Operations are counted by each cache itself (see Stats()), rather than by the
+handler, so that they're counted once whether or not they're exported. The handler
+just renders them. It's written with the standard library only.
+- Counters are exported since the cache was created, irrespective of ResetStats().
+Hence, Prometheus counters never go backwards.
+**************************************************************************** */
+package datacache
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+
+type MetricsHandler struct {
+	lock sync.RWMutex
+	cacheMap map[string]*DataCache
+	isRegistry bool                 // exports caches of the process-wide registry rather than cacheMap.
+}
+
+type metricDesc struct {
+	name string
+	help string
+	metricType string
+	value func(CacheStats) float64
+}
+
+var metricDescList = []metricDesc {
+	{"datacache_hits_total", "Fetches through GetRec() and GetDataRec() which found a record.", "counter",
+		func(stats CacheStats) float64 { return float64(stats.Hits) }},
+	{"datacache_misses_total", "Fetches through GetRec() and GetDataRec() which didn't find a record.", "counter",
+		func(stats CacheStats) float64 { return float64(stats.Misses) }},
+	{"datacache_inserts_total", "Records added.", "counter",
+		func(stats CacheStats) float64 { return float64(stats.Inserts) }},
+	{"datacache_overwrites_total", "Records added over existing keys.", "counter",
+		func(stats CacheStats) float64 { return float64(stats.Overwrites) }},
+	{"datacache_updates_total", "Payloads replaced in place.", "counter",
+		func(stats CacheStats) float64 { return float64(stats.Updates) }},
+	{"datacache_deletes_total", "Records deleted.", "counter",
+		func(stats CacheStats) float64 { return float64(stats.Deletes) }},
+	{"datacache_evictions_total", "Records evicted by the eviction policy.", "counter",
+		func(stats CacheStats) float64 { return float64(stats.Evictions) }},
+	{"datacache_expirations_total", "Expired records reclaimed.", "counter",
+		func(stats CacheStats) float64 { return float64(stats.Expirations) }},
+	{"datacache_lock_waits_total", "Record locks which couldn't be taken right away.", "counter",
+		func(stats CacheStats) float64 { return float64(stats.LockWaits) }},
+	{"datacache_lock_wait_seconds_total", "Time spent waiting for record locks.", "counter",
+		func(stats CacheStats) float64 { return stats.LockWaitTime.Seconds() }},
+	{"datacache_lock_timeouts_total", "Timed and Try record locks which gave up.", "counter",
+		func(stats CacheStats) float64 { return float64(stats.LockTimeouts) }},
+	{"datacache_records", "Records in the cache.", "gauge",
+		func(stats CacheStats) float64 { return float64(stats.Records) }},
+	{"datacache_keys", "Keys in the cache, including aliases.", "gauge",
+		func(stats CacheStats) float64 { return float64(stats.Keys) }},
+	{"datacache_load_duration_seconds", "Time taken by the last load or reload.", "gauge",
+		func(stats CacheStats) float64 { return stats.LoadDuration.Seconds() }},
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+
+/* ****************************************************************************
+Description :
+Creates metrics handler for the given caches.
+
+Receiver    : NA
+
+Implements  : NA
+
+Arguments   :
+1> cacheMap map[string]*DataCache: Caches keyed by name. Name is exported as "cache" label.
+
+Return value:
+1> *MetricsHandler: Newly created metrics handler. Caches can be added or removed later.
+
+Additional note: NA
+**************************************************************************** */
+func NewMetricsHandler(cacheMap map[string]*DataCache) *MetricsHandler {
+	pHandler := &MetricsHandler {
+		cacheMap: make(map[string]*DataCache, len(cacheMap)),
+	}
+
+	for name, pDataCache := range cacheMap {
+		if pDataCache != nil {
+			pHandler.cacheMap[name] = pDataCache
+		}
+	}
+
+	return pHandler
+}
+
+
+// Creates metrics handler which exports every cache in the registry (see Register()) under
+// the name it's registered with. Add() and Remove() have no effect on such a handler.
+func NewRegistryMetricsHandler() *MetricsHandler {
+	return &MetricsHandler {
+		cacheMap: make(map[string]*DataCache),
+		isRegistry: true,
+	}
+}
+
+
+// Adds a cache to be exported. Cache of the same name, if any, is replaced.
+func (pHandler *MetricsHandler) Add(name string, pDataCache *DataCache) {
+	if (pHandler == nil) || (pDataCache == nil) {
+		return
+	}
+
+	pHandler.lock.Lock()
+	pHandler.cacheMap[name] = pDataCache
+	pHandler.lock.Unlock()
+}
+
+
+// Removes a cache. Its metrics aren't exported hereon.
+func (pHandler *MetricsHandler) Remove(name string) {
+	if pHandler == nil {
+		return
+	}
+
+	pHandler.lock.Lock()
+	delete(pHandler.cacheMap, name)
+	pHandler.lock.Unlock()
+}
+
+
+// Returns stats of each cache, sorted by cache name. Caches are collected once the lock guarding
+// cacheMap is released, as stats of a cache takes its RD store-lock, which may be held for long.
+func (pHandler *MetricsHandler) collect() ([]string, []CacheStats) {
+	if pHandler.isRegistry {
+		registry.lock.RLock()
+		cacheMap := copyCacheMap(registry.cacheMap)
+		registry.lock.RUnlock()
+
+		return collectStats(cacheMap)
+	}
+
+	pHandler.lock.RLock()
+	cacheMap := copyCacheMap(pHandler.cacheMap)
+	pHandler.lock.RUnlock()
+
+	return collectStats(cacheMap)
+}
+
+
+func copyCacheMap(cacheMap map[string]*DataCache) map[string]*DataCache {
+	tmpCacheMap := make(map[string]*DataCache, len(cacheMap))
+	for name, pDataCache := range cacheMap {
+		tmpCacheMap[name] = pDataCache
+	}
+
+	return tmpCacheMap
+}
+
+
+// Returns sorted cache names and stats of the respective caches, with counters since creation of each cache.
+func collectStats(cacheMap map[string]*DataCache) ([]string, []CacheStats) {
+	nameList := make([]string, 0, len(cacheMap))
+	for name := range cacheMap {
+		nameList = append(nameList, name)
+	}
+	sort.Strings(nameList)
+
+	statsList := make([]CacheStats, len(nameList))
+	for i, name := range nameList {
+		statsList[i] = cacheMap[name].stats(false)
+	}
+
+	return nameList, statsList
+}
+
+
+// Renders metrics of all caches in the Prometheus text exposition format.
+func (pHandler *MetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if (r.Method != http.MethodGet) && (r.Method != http.MethodHead) {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	nameList, statsList := pHandler.collect()
+
+	var buf bytes.Buffer
+	for _, desc := range metricDescList {
+		fmt.Fprintf(&buf, "# HELP %s %s\n", desc.name, desc.help)
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", desc.name, desc.metricType)
+		for i, name := range nameList {
+			fmt.Fprintf(&buf, "%s{cache=\"%s\"} %v\n", desc.name, labelValueReplacer.Replace(name), desc.value(statsList[i]))
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,131 @@
+package datacache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+
+// Fetches metrics from the handler. Returns status code and body.
+func scrapeMetrics(pHandler http.Handler, method string) (int, string) {
+	pRecorder := httptest.NewRecorder()
+	pHandler.ServeHTTP(pRecorder, httptest.NewRequest(method, "/metrics", nil))
+	body, _ := io.ReadAll(pRecorder.Result().Body)
+
+	return pRecorder.Code, string(body)
+}
+
+
+func TestMetricsHandler(t *testing.T) {
+	pUsers := Create(nil, nil)
+	defer pUsers.Close()
+	pUsers.AddRec([]Key{"a", "a2"}, "a", true)
+	pUsers.GetDataRec("a")
+	pUsers.GetDataRec("missing")
+
+	pOrders := Create(nil, nil)
+	defer pOrders.Close()
+
+	pHandler := NewMetricsHandler(map[string]*DataCache {"users": pUsers})
+	pHandler.Add("orders\"x", pOrders)
+
+	code, body := scrapeMetrics(pHandler, http.MethodGet)
+	if code != http.StatusOK {
+		t.Fatalf("status %d, want 200", code)
+	}
+
+	for _, line := range []string {
+		"# TYPE datacache_hits_total counter",
+		`datacache_hits_total{cache="users"} 1`,
+		`datacache_misses_total{cache="users"} 1`,
+		`datacache_inserts_total{cache="users"} 1`,
+		"# TYPE datacache_records gauge",
+		`datacache_records{cache="users"} 1`,
+		`datacache_keys{cache="users"} 2`,
+		`datacache_records{cache="orders\"x"} 0`,
+	} {
+		if !strings.Contains(body, line + "\n") {
+			t.Errorf("metrics lack %q", line)
+		}
+	}
+
+	pHandler.Remove("orders\"x")
+	if _, body = scrapeMetrics(pHandler, http.MethodGet); strings.Contains(body, "orders") {
+		t.Fatalf("removed cache is still exported")
+	}
+
+	if code, _ = scrapeMetrics(pHandler, http.MethodPost); code != http.StatusMethodNotAllowed {
+		t.Fatalf("POST status %d, want 405", code)
+	}
+}
+
+
+// ResetStats() restarts Stats() but doesn't take exported counters backwards.
+func TestMetricsMonotonicAcrossResetStats(t *testing.T) {
+	pDataCache := Create(nil, nil)
+	defer pDataCache.Close()
+	pHandler := NewMetricsHandler(map[string]*DataCache {"c": pDataCache})
+
+	pDataCache.AddRec([]Key{"a"}, "a", true)
+	pDataCache.GetDataRec("a")
+	pDataCache.GetDataRec("a")
+	pDataCache.ResetStats()
+	pDataCache.GetDataRec("a")
+
+	if stats := pDataCache.Stats(); (stats.Hits != 1) || (stats.Inserts != 0) {
+		t.Fatalf("Stats() = %d hits, %d inserts after reset; want 1, 0", stats.Hits, stats.Inserts)
+	}
+
+	_, body := scrapeMetrics(pHandler, http.MethodGet)
+	for _, line := range []string {
+		`datacache_hits_total{cache="c"} 3`,
+		`datacache_inserts_total{cache="c"} 1`,
+	} {
+		if !strings.Contains(body, line + "\n") {
+			t.Errorf("metrics lack %q", line)
+		}
+	}
+}
+
+
+// A scrape stalled on the store-lock of a cache doesn't hold the registry lock.
+func TestMetricsScrapeStalledCache(t *testing.T) {
+	pStalled := Create(nil, nil)
+	defer pStalled.Close()
+	if err := Register("metrics-stalled", pStalled); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	defer Unregister("metrics-stalled")
+
+	pStalled.WriteLock()
+	scrapeChan := make(chan struct{})
+	go func() {
+		scrapeMetrics(NewRegistryMetricsHandler(), http.MethodGet)
+		close(scrapeChan)
+	}()
+	time.Sleep(10 * time.Millisecond)  // scrape waits for the store-lock by now.
+
+	pOther := Create(nil, nil)
+	defer pOther.Close()
+	registerChan := make(chan error, 1)
+	go func() {
+		registerChan <- Register("metrics-other", pOther)
+	}()
+
+	select {
+	case err := <-registerChan:
+		if err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+		Unregister("metrics-other")
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Register blocked by a scrape stalled on a cache")
+	}
+
+	pStalled.WriteUnlock()
+	<-scrapeChan
+}
@@ -0,0 +1,105 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache
+Filename    : github.com/sameeroak1110/datacache/options.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Optional datacache configuration passed to Create().
+- Each option is a function which sets the corresponding field of the datacache
+instance whilst it's being created. Hence, no store-lock is needed.
+**************************************************************************** */
+package datacache
+
+import (
+	"time"
+)
+
+
+type Option func(*DataCache)
+
+const defaultJanitorInterval = time.Minute
+
+
+// Cache-wide default TTL applied to each record which is added without its own TTL.
+// ttl <= 0 means records never expire unless added with their own TTL.
+func WithDefaultTTL(ttl time.Duration) Option {
+	return func(pDataCache *DataCache) {
+		if ttl > 0 {
+			pDataCache.defaultTTL = ttl
+		}
+	}
+}
+
+
+// Interval at which the janitor go-routine reclaims expired records. Default is a minute.
+func WithJanitorInterval(interval time.Duration) Option {
+	return func(pDataCache *DataCache) {
+		if interval > 0 {
+			pDataCache.janitorInterval = interval
+		}
+	}
+}
+
+
+// Maximum number of records in the cache. A record, as is chosen by the eviction policy, is evicted
+// once the cache grows beyond maxRecs. 0 means unbounded.
+func WithMaxRecs(maxRecs int) Option {
+	return func(pDataCache *DataCache) {
+		if maxRecs > 0 {
+			pDataCache.maxRecs = maxRecs
+		}
+	}
+}
+
+
+// Maximum sum of payload sizes, in bytes, as is reported by sizeFn for each record whilst it's added.
+// A record, as is chosen by the eviction policy, is evicted once the cache grows beyond maxBytes.
+// 0 means unbounded.
+// sizeFn isn't invoked again if the payload is updated in place.
+func WithMaxBytes(maxBytes int64, sizeFn SizeFunc) Option {
+	return func(pDataCache *DataCache) {
+		if (maxBytes > 0) && (sizeFn != nil) {
+			pDataCache.maxBytes = maxBytes
+			pDataCache.sizefn = sizeFn
+		}
+	}
+}
+
+
+// Eviction policy of a bounded cache, for instance, NewLFUPolicy(). Default is NewLRUPolicy().
+// Policy is consulted only if the cache is bounded through WithMaxRecs() or WithMaxBytes().
+func WithEvictionPolicy(policy EvictionPolicy) Option {
+	return func(pDataCache *DataCache) {
+		if policy != nil {
+			pDataCache.policy = policy
+		}
+	}
+}
+
+
+// Cache-wide lease of handles acquired through AcquireRec(). Record lock of a handle which isn't
+// released within the lease is forcibly reclaimed. lease <= 0 means handles don't expire.
+func WithDefaultLease(lease time.Duration) Option {
+	return func(pDataCache *DataCache) {
+		if lease > 0 {
+			pDataCache.defaultLease = lease
+		}
+	}
+}
+
+
+// Handler invoked once the lease of a handle expires. Default is to log the expiry.
+func WithLeaseExpiryHandler(leaseExpiryFunc LeaseExpiryFunc) Option {
+	return func(pDataCache *DataCache) {
+		pDataCache.leaseExpiryfn = leaseExpiryFunc
+	}
+}
@@ -0,0 +1,260 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache
+Filename    : github.com/sameeroak1110/datacache/reclock.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Record lock primitives and reader/writer record handles.
+- Record lock is a reader/writer lock. Exclusive mode is for updates of the record,
+shared mode is for the readers, so that readers of a hot record don't serialize.
+- Every record lock of the package is taken and released through the primitives herein,
+so that lock debug mode (see WithLockDebug()) sees each of them.
+**************************************************************************** */
+package datacache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+
+// Locks the record in exclusive mode.
+func (pRec *Rec) lock() {
+	pRec.pRecLock.Lock()
+	atomic.StoreInt32(&pRec.isWRHeld, 1)
+	pRec.pLockTracker.locked(pRec, lockKindRec)
+}
+
+
+// Locks the record in exclusive mode only if it isn't held by some go-routine.
+func (pRec *Rec) tryLock() bool {
+	if !pRec.pRecLock.TryLock() {
+		return false
+	}
+
+	atomic.StoreInt32(&pRec.isWRHeld, 1)
+	pRec.pLockTracker.locked(pRec, lockKindRec)
+	return true
+}
+
+
+// Unlocks the record locked in exclusive mode. Version of the record is bumped, as the record may have
+// been updated in the lock, and the record is published in copy-on-write mode.
+func (pRec *Rec) unlock() {
+	pRec.bumpVersion()
+	pRec.unlockUnmodified()
+}
+
+
+// Unlocks the record locked in exclusive mode without bumping its version. Used when the holder
+// hasn't updated the record, for instance, on a version conflict or a txn rollback.
+func (pRec *Rec) unlockUnmodified() {
+	pRec.publishCOW()
+	pRec.pLockTracker.unlocked(pRec, lockKindRec)
+	atomic.StoreInt32(&pRec.isWRHeld, 0)
+	pRec.pRecLock.Unlock()
+}
+
+
+// Same as unlock() but only if the record is held in exclusive mode. Returns false otherwise, i.e., if the
+// record is unlocked or is held in shared mode, which RWMutex.Unlock() can't cope with.
+func (pRec *Rec) unlockIfHeld() bool {
+	if !atomic.CompareAndSwapInt32(&pRec.isWRHeld, 1, 0) {
+		return false
+	}
+
+	pRec.bumpVersion()
+	pRec.publishCOW()
+	pRec.pLockTracker.unlocked(pRec, lockKindRec)
+	pRec.pRecLock.Unlock()
+	return true
+}
+
+
+// Assigns the next version to the record and returns it. Must be invoked in the exclusive record lock.
+func (pRec *Rec) bumpVersion() uint64 {
+	pRec.version = nextRecVersion()
+	return pRec.version
+}
+
+
+// Locks the record in shared mode.
+func (pRec *Rec) rlock() {
+	pRec.pRecLock.RLock()
+	pRec.pLockTracker.locked(pRec, lockKindRecRead)
+}
+
+
+// Locks the record in shared mode only if it isn't held in exclusive mode by some go-routine.
+func (pRec *Rec) tryRLock() bool {
+	if !pRec.pRecLock.TryRLock() {
+		return false
+	}
+
+	pRec.pLockTracker.locked(pRec, lockKindRecRead)
+	return true
+}
+
+
+// Unlocks the record locked in shared mode.
+func (pRec *Rec) runlock() {
+	pRec.pLockTracker.unlocked(pRec, lockKindRecRead)
+	pRec.pRecLock.RUnlock()
+}
+
+
+/* ****************************************************************************
+Description :
+Takes RD store-lock of key, looks the record up and locks it in the given mode. In case the
+record lock is held by some other go-routine, the store-lock is released whilst waiting for
+the record and the lookup is retried once the record is available.
+
+Receiver    :
+pDataCache *DataCache: Instance of datacache.
+
+Implements  : NA
+
+Arguments   :
+1> key Key: Key to the cache record.
+2> isShared bool: true for shared mode, false for exclusive mode.
+
+Return value:
+1> *Rec: Locked record. nil if key isn't found or the record has expired.
+
+Additional note:
+- Method returns in RD store-lock of key irrespective of the result. Caller releases it
+through runlockKey().
+- Store-lock isn't held whilst waiting for the record, so that a go-routine waiting for a
+record locked by a txn doesn't hold up the store-locks the txn needs to commit (see
+Txn.Commit()). Caller go-routine shouldn't invoke this method in any store-lock.
+**************************************************************************** */
+func (pDataCache *DataCache) rlockKeyAndRec(key Key, isShared bool) *Rec {
+	var startTime time.Time
+	for {
+		pDataCache.rlockKey(key)
+		pRec, isOK := pDataCache.lookupWOLock(key)
+		if !isOK || pRec.isExpired(time.Now()) {
+			return nil
+		}
+
+		if (isShared && pRec.tryRLock()) || (!isShared && pRec.tryLock()) {
+			if !startTime.IsZero() {
+				pDataCache.pCounters.lockWait(time.Since(startTime))
+			}
+			return pRec
+		}
+
+		pDataCache.runlockKey(key)
+		if startTime.IsZero() {
+			startTime = time.Now()
+		}
+		if isShared {  // waits for the record without holding any store-lock.
+			pRec.rlock()
+			pRec.runlock()
+		} else {
+			pRec.lock()
+			pRec.unlockUnmodified()
+		}
+	}
+}
+
+
+// Fetches the record referred to by key and locks it in the given mode. Caller holds no store-lock.
+func (pDataCache *DataCache) getLockedRec(key Key, isShared bool) (bool, *Rec) {
+	pRec := pDataCache.rlockKeyAndRec(key, isShared)
+	defer pDataCache.runlockKey(key)
+
+	if pRec == nil {
+		pDataCache.pCounters.miss()
+		return false, nil
+	}
+
+	pDataCache.pCounters.hit()
+	if pDataCache.policy != nil {
+		pDataCache.policy.OnHit(pRec)
+	}
+
+	return true, pRec
+}
+
+
+/* ****************************************************************************
+Description :
+Acquires the record referred to by key in shared mode. Other readers of the record
+aren't blocked, whereas writers are blocked until the handle is released.
+
+Receiver    :
+pDataCache *DataCache: Instance of datacache.
+
+Implements  : NA
+
+Arguments   :
+1> key Key: Key to the cache record.
+
+Return value:
+1> bool: true if successful. false if cache record isn't found or has expired.
+2> *Handle: Handle of the record locked in shared mode. nil in case of error.
+
+Additional note:
+- Method takes RD store-lock. Caller go-routine shouldn't invoke this method in any store-lock.
+- Payload mustn't be modified through the handle. Lease, if any, is the cache-wide default lease
+(see WithDefaultLease()).
+- A go-routine holding a shared handle of a record mustn't acquire the same record again, in
+any mode. It's a deadlock otherwise, as a pending writer blocks new readers.
+**************************************************************************** */
+func (pDataCache *DataCache) GetRecForRead(key Key) (bool, *Handle) {
+	if pDataCache == nil {
+		return false, nil
+	}
+
+	isOK, pRec := pDataCache.getLockedRec(key, true)
+	if !isOK {
+		return false, nil
+	}
+
+	return true, pDataCache.newHandle(key, pRec, pDataCache.defaultLease, callerSite(1), true)
+}
+
+
+/* ****************************************************************************
+Description :
+Acquires the record referred to by key in exclusive mode. All other readers and writers of
+the record are blocked until the handle is released.
+
+Receiver    :
+pDataCache *DataCache: Instance of datacache.
+
+Implements  : NA
+
+Arguments   :
+1> key Key: Key to the cache record.
+
+Return value:
+1> bool: true if successful. false if cache record isn't found or has expired.
+2> *Handle: Handle of the record locked in exclusive mode. nil in case of error.
+
+Additional note:
+- Same as AcquireRec(). Method takes RD store-lock. Caller go-routine shouldn't invoke this
+method in any store-lock.
+**************************************************************************** */
+func (pDataCache *DataCache) GetRecForWrite(key Key) (bool, *Handle) {
+	if pDataCache == nil {
+		return false, nil
+	}
+
+	isOK, pRec := pDataCache.getLockedRec(key, false)
+	if !isOK {
+		return false, nil
+	}
+
+	return true, pDataCache.newHandle(key, pRec, pDataCache.defaultLease, callerSite(1), false)
+}
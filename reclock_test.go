@@ -0,0 +1,69 @@
+package datacache
+
+import (
+	"sync"
+	"testing"
+)
+
+
+// Readers of a single hot record. GetDataRec() shares the record lock amongst readers, whereas
+// GetRec()/DataCacheRecUnlock() takes it in exclusive mode, which is how every read locked the record
+// before the record lock became a reader/writer lock.
+func BenchmarkHotKeyReaders(b *testing.B) {
+	pDataCache := Create(nil, nil)
+	defer pDataCache.Close()
+	pDataCache.AddRec([]Key{"hot"}, "v", true)
+
+	b.Run("RWMutex/GetDataRec", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				pDataCache.GetDataRec("hot")
+			}
+		})
+	})
+
+	b.Run("Mutex/GetRec", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, pRec := pDataCache.GetRec("hot"); pRec != nil {
+					pRec.DataCacheRecUnlock()
+				}
+			}
+		})
+	})
+}
+
+
+// Same comparison on the bare lock primitives, with a short critical section standing in for the read.
+func BenchmarkHotKeyLock(b *testing.B) {
+	payload := make([]int, 64)
+	read := func() int {
+		sum := 0
+		for _, n := range payload {
+			sum = sum + n
+		}
+		return sum
+	}
+
+	b.Run("RWMutex", func(b *testing.B) {
+		var lock sync.RWMutex
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				lock.RLock()
+				read()
+				lock.RUnlock()
+			}
+		})
+	})
+
+	b.Run("Mutex", func(b *testing.B) {
+		var lock sync.Mutex
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				lock.Lock()
+				read()
+				lock.Unlock()
+			}
+		})
+	})
+}
@@ -0,0 +1,157 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache
+Filename    : github.com/sameeroak1110/datacache/registry.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Process-wide registry of named caches.
+- Tooling, metrics, admin endpoints and log messages address a cache by the name
+it's registered with. A name refers to a single cache and a cache is registered
+under a single name.
+**************************************************************************** */
+package datacache
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+
+var registry = struct {
+	lock sync.RWMutex
+	cacheMap map[string]*DataCache
+} {
+	cacheMap: make(map[string]*DataCache),
+}
+
+
+/* ****************************************************************************
+Description :
+Registers a cache under the given name.
+
+Receiver    : NA
+
+Implements  : NA
+
+Arguments   :
+1> name string: Name of the cache. Must be non-empty and unique in the process.
+2> pDataCache *DataCache: Cache to be registered.
+
+Return value:
+1> error: Error in case name is already taken or the cache is already registered under
+some other name. nil otherwise.
+
+Additional note: NA
+**************************************************************************** */
+func Register(name string, pDataCache *DataCache) error {
+	if pDataCache == nil {
+		return errors.New("Nil datacache.")
+	}
+
+	if name == "" {
+		return errors.New("Empty cache name.")
+	}
+
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	if _, isOK := registry.cacheMap[name]; isOK {
+		return errors.New(fmt.Sprintf("Cache \"%s\" is already registered.", name))
+	}
+
+	if pDataCache.name != "" {
+		return errors.New(fmt.Sprintf("Cache is already registered as \"%s\".", pDataCache.name))
+	}
+
+	registry.cacheMap[name] = pDataCache
+	pDataCache.name = name
+
+	return nil
+}
+
+
+// Returns the cache registered under name.
+func Lookup(name string) (*DataCache, bool) {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+
+	pDataCache, isOK := registry.cacheMap[name]
+	return pDataCache, isOK
+}
+
+
+// Returns names of all registered caches in sorted order.
+func List() []string {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+
+	nameList := make([]string, 0, len(registry.cacheMap))
+	for name := range registry.cacheMap {
+		nameList = append(nameList, name)
+	}
+	sort.Strings(nameList)
+
+	return nameList
+}
+
+
+// Unregisters the cache registered under name. Returns false if there's no such cache.
+// The cache by itself isn't affected.
+func Unregister(name string) bool {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	pDataCache, isOK := registry.cacheMap[name]
+	if !isOK {
+		return false
+	}
+
+	delete(registry.cacheMap, name)
+	pDataCache.name = ""
+
+	return true
+}
+
+
+// Returns the name the cache is registered under. Empty if it isn't registered.
+func (pDataCache *DataCache) Name() string {
+	if pDataCache == nil {
+		return ""
+	}
+
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+
+	return pDataCache.name
+}
+
+
+// Returns a tag identifying the cache in log messages.
+func (pDataCache *DataCache) logTag() string {
+	if name := pDataCache.Name(); name != "" {
+		return fmt.Sprintf("[datacache %s]", name)
+	}
+
+	return "[datacache]"
+}
+
+
+// Returns cacheName if provided, else the name the cache is registered under.
+func (pDataCache *DataCache) cacheName(cacheName string) string {
+	if cacheName != "" {
+		return cacheName
+	}
+
+	return pDataCache.Name()
+}
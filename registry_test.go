@@ -0,0 +1,65 @@
+package datacache
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+
+func TestRegister(t *testing.T) {
+	pUsers := Create(nil, nil)
+	defer pUsers.Close()
+	pOrders := Create(nil, nil)
+	defer pOrders.Close()
+	defer Unregister("reg-users")
+	defer Unregister("reg-orders")
+
+	testList := []struct {
+		name string
+		cacheName string
+		pDataCache *DataCache
+		isErr bool
+	}{
+		{"registered", "reg-users", pUsers, false},
+		{"nil cache", "reg-nil", nil, true},
+		{"empty name", "", pOrders, true},
+		{"name taken", "reg-users", pOrders, true},
+		{"cache already registered", "reg-users2", pUsers, true},
+		{"second cache", "reg-orders", pOrders, false},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			if err := Register(test.cacheName, test.pDataCache); (err != nil) != test.isErr {
+				t.Fatalf("Register(%q) = %v, want error %v", test.cacheName, err, test.isErr)
+			}
+		})
+	}
+
+	if pDataCache, isOK := Lookup("reg-users"); !isOK || (pDataCache != pUsers) {
+		t.Fatalf("Lookup(reg-users) = %p, %v", pDataCache, isOK)
+	}
+	if nameList := List(); !reflect.DeepEqual(nameList, []string{"reg-orders", "reg-users"}) {
+		t.Fatalf("List() = %v", nameList)
+	}
+	if pUsers.Name() != "reg-users" {
+		t.Fatalf("Name() = %q", pUsers.Name())
+	}
+
+	// cache name falls back to the registered one in error messages.
+	if _, err := pUsers.Iterate("", true); (err == nil) || !strings.Contains(err.Error(), "\"reg-users\"") {
+		t.Fatalf("Iterate() error %v lacks the registered name", err)
+	}
+
+	if !Unregister("reg-users") || Unregister("reg-users") {
+		t.Fatalf("Unregister(reg-users) isn't true exactly once")
+	}
+	if _, isOK := Lookup("reg-users"); isOK || (pUsers.Name() != "") {
+		t.Fatalf("cache is still registered")
+	}
+	if err := Register("reg-users2", pUsers); err != nil {
+		t.Fatalf("re-Register after Unregister: %v", err)
+	}
+	Unregister("reg-users2")
+}
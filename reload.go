@@ -0,0 +1,291 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache
+Filename    : github.com/sameeroak1110/datacache/reload.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Full reload of the cache, on demand and periodically.
+- Unlike Load(), Reload() can be invoked any number of times. loadfn() loads a fresh
+cache store without any store-lock. The fresh store then replaces the current one
+in WR store-lock. Hence, readers see either the previous generation or the new one,
+never a half-loaded cache.
+**************************************************************************** */
+package datacache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+
+// Outcome of a reload relative to the previous generation of the cache.
+type ReloadStats struct {
+	Generation uint64        // generation of the cache store. incremented by each successful reload.
+	Added int                // records none of whose keys existed in the previous generation, or whose previous
+	                         // record is matched to some other record, for instance, when a record is split.
+	Changed int              // records whose payload or key list differs from that of the previous generation.
+	Unchanged int
+	Removed int              // records of previous generation none of whose keys exist in the new one.
+	Duration time.Duration   // time taken by loadfn() and the swap.
+	LoadedAt time.Time
+}
+
+
+// Interval at which the cache is reloaded through Reload() in a background go-routine.
+// Requires load function. Errors are logged and the current generation is retained.
+func WithRefreshInterval(interval time.Duration) Option {
+	return func(pDataCache *DataCache) {
+		if interval > 0 {
+			pDataCache.refreshInterval = interval
+		}
+	}
+}
+
+
+// Returns true if both key lists hold the same set of keys.
+func isSameKeyList(keyList1 []Key, keyList2 []Key) bool {
+	if len(keyList1) != len(keyList2) {
+		return false
+	}
+
+	keyMap := make(map[Key]bool, len(keyList1))
+	for _, key := range keyList1 {
+		keyMap[key] = true
+	}
+	for _, key := range keyList2 {
+		if !keyMap[key] {
+			return false
+		}
+	}
+
+	return true
+}
+
+
+// Invokes loadfn(). Returns early with ctx.Err() if ctx is done before loadfn() returns. In that case
+// result of loadfn() is discarded whenever it returns.
+func (pDataCache *DataCache) loadWithContext(ctx context.Context) ([]Payload, error) {
+	type loadResult struct {
+		recList []Payload
+		err error
+	}
+
+	resultChan := make(chan loadResult, 1)
+	go func() {
+		recList, err := pDataCache.loadfn()
+		resultChan <- loadResult{recList, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+
+	case result := <-resultChan:
+		return result.recList, result.err
+	}
+}
+
+
+/* ****************************************************************************
+Description :
+Reloads the cache. Invokes loadfn() to load a fresh cache store, invokes reciteratefn(),
+if any, on payload of each loaded record and replaces the current cache store with the
+fresh one atomically.
+
+Receiver    :
+pDataCache *DataCache: Instance of datacache.
+
+Implements  : NA
+
+Arguments   :
+1> ctx context.Context: Reload is abandoned if ctx is done before the fresh store is swapped in.
+
+Return value:
+1> ReloadStats: What's been added, changed and removed relative to the previous generation.
+2> error: Returns cause of error. Current generation is retained in case of error.
+
+Additional note:
+- Caller go-routine shouldn't invoke this method in any store-lock. It's a deadlock otherwise.
+- loadfn() and reciteratefn() are invoked without any store-lock. The method takes WR store-lock
+only to compare and swap the cache store.
+- Records of the previous generation which are held locked by some go-routine are just
+detached from the cache. Changes made to them are lost.
+- Concurrent reloads are serialized.
+**************************************************************************** */
+func (pDataCache *DataCache) Reload(ctx context.Context) (ReloadStats, error) {
+	var stats ReloadStats
+
+	if pDataCache == nil {
+		return stats, errors.New("Nil datacache.")
+	}
+
+	if pDataCache.loadfn == nil {
+		return stats, errors.New("Nil datacache loader.")
+	}
+
+	pDataCache.reloadLock.Lock()
+	defer pDataCache.reloadLock.Unlock()
+
+	startTime := time.Now()
+	recList, err := pDataCache.loadWithContext(ctx)
+	if err != nil {
+		return stats, errors.New(fmt.Sprintf("Load function failed: %s", err.Error()))
+	}
+
+	// fresh store is built in a private datacache instance. it's unbounded and has no eviction policy.
+	pFresh := &DataCache {
+		shards: newShards(len(pDataCache.shards)),
+		sizefn: pDataCache.sizefn,
+	}
+	for i := range recList {
+		pFresh.storeRecWOLock(pDataCache.newRec(recList[i].KeyList, recList[i].PDataRec, recList[i].TTL))
+	}
+
+	if pDataCache.reciteratefn != nil {
+		for _, pRec := range pFresh.uniqueRecs() {
+			pDataCache.reciteratefn(pRec.PDataRec)
+		}
+	}
+
+	if err = ctx.Err(); err != nil {
+		return stats, err
+	}
+
+	pDataCache.cacheLock.Lock()
+	defer pDataCache.unlockStore()
+
+	matchedMap := make(map[*Rec]bool)
+	freshRecList := pFresh.uniqueRecs()
+	for _, pRec := range freshRecList {
+		var pOldRec *Rec
+		for _, key := range pRec.KeyList {
+			if pTmpRec, isOK := pDataCache.lookupWOLock(key); isOK {
+				pOldRec = pTmpRec
+				break
+			}
+		}
+
+		if (pOldRec == nil) || matchedMap[pOldRec] {  // each old record is matched at most once.
+			stats.Added = stats.Added + 1
+			continue
+		}
+		matchedMap[pOldRec] = true
+
+		isChanged := true
+		if pOldRec.tryRLock() {  // record held for update by some go-routine is assumed to have changed.
+			isChanged = !isSameKeyList(pOldRec.KeyList, pRec.KeyList) || !reflect.DeepEqual(pOldRec.PDataRec, pRec.PDataRec)
+			pOldRec.runlock()
+		}
+		if isChanged {
+			stats.Changed = stats.Changed + 1
+		} else {
+			stats.Unchanged = stats.Unchanged + 1
+		}
+	}
+	stats.Removed = pDataCache.recCnt() - len(matchedMap)
+
+	if pDataCache.isHooked(hookDelete) {  // every record is replaced, changed or not.
+		pDataCache.raiseRecHooksWOLock(hookDelete, EventReload, pDataCache.uniqueRecs())
+	}
+	pDataCache.shards = pFresh.shards
+	atomic.StoreInt64(&pDataCache.cnt, pFresh.cnt)
+	atomic.StoreInt64(&pDataCache.bytes, pFresh.bytes)
+	pDataCache.resetCOWWOLock()
+	pDataCache.raiseEvent(EventReload, nil, nil, nil, false)
+	pDataCache.raiseRecHooksWOLock(hookInsert, EventReload, freshRecList)
+	if pDataCache.policy != nil {
+		pDataCache.policy.Reset()
+		for _, pRec := range freshRecList {
+			pDataCache.policy.OnInsert(pRec)
+		}
+		pDataCache.evictWOLock(nil)
+	}
+
+	// log restarts with the new generation. records of the new generation can't be locked by anyone yet.
+	if err = pDataCache.compactWALWOLock(false); err != nil {
+		fmt.Println(pDataCache.logTag(), "WAL compaction after reload failed:", err.Error())
+	}
+
+	pDataCache.generation = pDataCache.generation + 1
+	stats.Generation = pDataCache.generation
+	stats.LoadedAt = time.Now()
+	stats.Duration = stats.LoadedAt.Sub(startTime)
+	pDataCache.lastReloadStats = stats
+	pDataCache.pCounters.load(stats.Duration)
+
+	return stats, nil
+}
+
+
+// Returns stats of the last successful reload. Generation is 0 if the cache has never been reloaded.
+func (pDataCache *DataCache) LastReloadStats() ReloadStats {
+	if pDataCache == nil {
+		return ReloadStats{}
+	}
+
+	pDataCache.cacheLock.RLock()
+	defer pDataCache.cacheLock.RUnlock()
+
+	return pDataCache.lastReloadStats
+}
+
+
+// Returns each record of the cache once, irrespective of the number of its keys, which may
+// fall in different shards.
+// Must be invoked in WR store-lock or in RD store-lock along with RD shard-lock of every shard.
+func (pDataCache *DataCache) uniqueRecs() []*Rec {
+	recList := make([]*Rec, 0, pDataCache.recCnt())
+	recMap := make(map[*Rec]bool, pDataCache.recCnt())
+	for _, pShard := range pDataCache.shards {
+		for _, pRec := range pShard.cache {
+			if !recMap[pRec] {
+				recMap[pRec] = true
+				recList = append(recList, pRec)
+			}
+		}
+	}
+
+	return recList
+}
+
+
+// Refresher go-routine. Periodically reloads the cache until Close() is invoked.
+func (pDataCache *DataCache) refresher() {
+	ticker := time.NewTicker(pDataCache.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-pDataCache.pStopChan:
+			return
+
+		case <-ticker.C:
+			ctx, cancel := context.WithCancel(context.Background())
+			go func() {
+				select {
+				case <-pDataCache.pStopChan:
+					cancel()
+				case <-ctx.Done():
+				}
+			}()
+
+			if _, err := pDataCache.Reload(ctx); err != nil {
+				fmt.Println(pDataCache.logTag(), "Periodic reload failed:", err.Error())
+			}
+			cancel()
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package datacache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+
+func TestReload(t *testing.T) {
+	var lock sync.Mutex
+	var payloadList []Payload
+	var loadErr error
+	loadFunc := func() ([]Payload, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		return payloadList, loadErr
+	}
+	setLoad := func(tmpPayloadList []Payload, err error) {
+		lock.Lock()
+		payloadList, loadErr = tmpPayloadList, err
+		lock.Unlock()
+	}
+
+	pDataCache := Create(loadFunc, nil)
+	defer pDataCache.Close()
+
+	testList := []struct {
+		name string
+		payloadList []Payload
+		loadErr error
+		wantStats ReloadStats
+		isErr bool
+		wantMap map[Key]interface{}  // nil payload means the key mustn't exist.
+	}{
+		{"first generation", []Payload {
+			{KeyList: []Key{"a"}, PDataRec: "a1"},
+			{KeyList: []Key{"b", "b2"}, PDataRec: "b1"},
+			{KeyList: []Key{"c"}, PDataRec: "c1"},
+		}, nil, ReloadStats { Generation: 1, Added: 3 }, false,
+		map[Key]interface{} {"a": "a1", "b2": "b1", "c": "c1"}},
+
+		{"add, change, remove", []Payload {
+			{KeyList: []Key{"a"}, PDataRec: "a1"},
+			{KeyList: []Key{"b"}, PDataRec: "b1"},     // key list changed.
+			{KeyList: []Key{"d"}, PDataRec: "d1"},
+		}, nil, ReloadStats { Generation: 2, Added: 1, Changed: 1, Unchanged: 1, Removed: 1 }, false,
+		map[Key]interface{} {"a": "a1", "b": "b1", "b2": nil, "c": nil, "d": "d1"}},
+
+		{"loader error keeps generation", nil, errors.New("db down"), ReloadStats{}, true,
+		map[Key]interface{} {"a": "a1", "d": "d1"}},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			setLoad(test.payloadList, test.loadErr)
+			stats, err := pDataCache.Reload(context.Background())
+			if (err != nil) != test.isErr {
+				t.Fatalf("Reload() error = %v, want error %v", err, test.isErr)
+			}
+			if !test.isErr {
+				stats.Duration, stats.LoadedAt = 0, time.Time{}
+				if stats != test.wantStats {
+					t.Fatalf("Reload() = %+v, want %+v", stats, test.wantStats)
+				}
+			}
+
+			for key, pWantDataRec := range test.wantMap {
+				isOK, pDataRec := pDataCache.GetDataRec(key)
+				if (pWantDataRec == nil) == isOK || (isOK && (pDataRec != pWantDataRec)) {
+					t.Fatalf("GetDataRec(%v) = %v, %v; want %v", key, isOK, pDataRec, pWantDataRec)
+				}
+			}
+		})
+	}
+
+	if stats := pDataCache.LastReloadStats(); stats.Generation != 2 {
+		t.Fatalf("LastReloadStats().Generation = %d, want 2", stats.Generation)
+	}
+}
+
+
+func TestReloadCancelled(t *testing.T) {
+	releaseChan := make(chan struct{})
+	defer close(releaseChan)
+	pDataCache := Create(func() ([]Payload, error) {
+		<-releaseChan
+		return []Payload{{KeyList: []Key{"a"}, PDataRec: "a"}}, nil
+	}, nil)
+	defer pDataCache.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20 * time.Millisecond)
+	defer cancel()
+	if _, err := pDataCache.Reload(ctx); (err == nil) || !strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
+		t.Fatalf("Reload() = %v, want deadline exceeded", err)
+	}
+	if pDataCache.DoesKeyExist("a") {
+		t.Fatalf("abandoned reload has been applied")
+	}
+}
+
+
+func TestRefreshInterval(t *testing.T) {
+	var lock sync.Mutex
+	loadCnt := 0
+	pDataCache := Create(func() ([]Payload, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		loadCnt++
+		return []Payload{{KeyList: []Key{"a"}, PDataRec: loadCnt}}, nil
+	}, nil, WithRefreshInterval(10 * time.Millisecond))
+	defer pDataCache.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for pDataCache.LastReloadStats().Generation < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("cache hasn't been refreshed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+
+// A record split into two matches its previous record once; the other part is counted as added.
+func TestReloadSplit(t *testing.T) {
+	payloadList := []Payload {
+		{KeyList: []Key{"a", "a2"}, PDataRec: "a1"},
+	}
+	pDataCache := Create(func() ([]Payload, error) {
+		return payloadList, nil
+	}, nil)
+	defer pDataCache.Close()
+	if _, err := pDataCache.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	payloadList = []Payload {
+		{KeyList: []Key{"a"}, PDataRec: "a1"},
+		{KeyList: []Key{"a2"}, PDataRec: "a1"},
+	}
+	stats, err := pDataCache.Reload(context.Background())
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if (stats.Added != 1) || (stats.Changed != 1) || (stats.Unchanged != 0) || (stats.Removed != 0) {
+		t.Fatalf("Reload() = %+v, want 1 added, 1 changed", stats)
+	}
+}
@@ -0,0 +1,425 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache/resp
+Filename    : github.com/sameeroak1110/datacache/resp/commands.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- RESP commands mapped onto DataCache operations.
+- A redis key maps to a datacache key. DEL disassociates the key the way DeleteKey() does,
+whereas EXPIRE and TTL apply to the record and thus to all its keys.
+**************************************************************************** */
+package resp
+
+import (
+	"bufio"
+	"encoding/json"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sameeroak1110/datacache"
+)
+
+
+const defaultScanCount = 10
+
+type commandFunc func(pDataCache *datacache.DataCache, pWriter *bufio.Writer, argList [][]byte)
+
+type command struct {
+	fn commandFunc
+	minArgs int
+	maxArgs int    // -1 means no upper limit.
+}
+
+var commandMap map[string]command
+
+func init() {
+	commandMap = map[string]command {
+		"ping": { cmdPing, 0, 1 },
+		"get": { cmdGet, 1, 1 },
+		"set": { cmdSet, 2, -1 },
+		"del": { cmdDel, 1, -1 },
+		"exists": { cmdExists, 1, -1 },
+		"dbsize": { cmdDBSize, 0, 0 },
+		"scan": { cmdScan, 1, -1 },
+		"expire": { cmdExpire, 2, 2 },
+		"ttl": { cmdTTL, 1, 1 },
+		"keys": { cmdKeys, 1, 1 },
+	}
+}
+
+
+// Returns payload as a byte-slice. false if the payload isn't of any of the byte-slice like types.
+func payloadBytes(pDataRec interface{}) ([]byte, bool) {
+	switch payload := pDataRec.(type) {
+		case []byte:
+			return payload, true
+		case *[]byte:
+			return *payload, true
+		case json.RawMessage:
+			return payload, true
+		case *json.RawMessage:
+			return *payload, true
+		case string:
+			return []byte(payload), true
+		case *string:
+			return []byte(*payload), true
+	}
+
+	return nil, false
+}
+
+
+// Returns all string keys of unexpired records, optionally filtered through glob pattern.
+func stringKeys(pDataCache *datacache.DataCache, pattern string) []string {
+	keyList := make([]string, 0)
+
+	pDataCache.Scan(func(pRecIntf interface{}) bool {
+		for _, key := range pRecIntf.(*datacache.Rec).KeyList {
+			if strKey, isOK := key.(string); isOK && ((pattern == "") || matchGlob(pattern, strKey)) {
+				keyList = append(keyList, strKey)
+			}
+		}
+		return true
+	})
+
+	return keyList
+}
+
+
+// Returns n units as time.Duration. false if n isn't positive or n units overflow time.Duration.
+func toDuration(n int64, unit time.Duration) (time.Duration, bool) {
+	if (n <= 0) || (n > int64(math.MaxInt64 / unit)) {
+		return 0, false
+	}
+
+	return time.Duration(n) * unit, true
+}
+
+
+func keyHash(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+
+func cmdPing(pDataCache *datacache.DataCache, pWriter *bufio.Writer, argList [][]byte) {
+	if len(argList) == 1 {
+		writeBulk(pWriter, argList[0])
+		return
+	}
+
+	writeSimpleString(pWriter, "PONG")
+}
+
+
+func cmdGet(pDataCache *datacache.DataCache, pWriter *bufio.Writer, argList [][]byte) {
+	isOK, pDataRec := pDataCache.GetDataRec(string(argList[0]))
+	if !isOK {
+		writeNil(pWriter)
+		return
+	}
+
+	buf, isOK := payloadBytes(pDataRec)
+	if !isOK {
+		writeError(pWriter, "WRONGTYPE Operation against a key holding the wrong kind of value")
+		return
+	}
+
+	writeBulk(pWriter, buf)
+}
+
+
+/* ****************************************************************************
+Description :
+SET key value [EX seconds|PX milliseconds] [NX|XX]
+
+Receiver    : NA
+
+Implements  : NA
+
+Arguments   :
+1> pDataCache *datacache.DataCache: Datacache instance.
+2> pWriter *bufio.Writer: Reply is written to it.
+3> argList [][]byte: Command arguments.
+
+Return value: NA
+
+Additional note:
+- Without EX or PX, the record gets the cache-wide default TTL.
+- NX maps to AddRecWithTTL() with recExistsErrFlag set. XX checks the key and adds the
+record in the same WR store-lock. Hence, XX is atomic against a concurrent DEL.
+- EX or PX which doesn't fit in time.Duration is rejected rather than wrapped to a negative TTL.
+**************************************************************************** */
+func cmdSet(pDataCache *datacache.DataCache, pWriter *bufio.Writer, argList [][]byte) {
+	var ttl time.Duration
+	isNX, isXX, isOK := false, false, false
+
+	for i := 2; i < len(argList); i++ {
+		switch opt := strings.ToLower(string(argList[i])); opt {
+			case "nx":
+				isNX = true
+
+			case "xx":
+				isXX = true
+
+			case "ex", "px":
+				if (i + 1 >= len(argList)) || (ttl != 0) {
+					writeError(pWriter, "ERR syntax error")
+					return
+				}
+				i++
+				unit := time.Millisecond
+				if opt == "ex" {
+					unit = time.Second
+				}
+				n, err := strconv.ParseInt(string(argList[i]), 10, 64)
+				if ttl, isOK = toDuration(n, unit); (err != nil) || !isOK {
+					writeError(pWriter, "ERR invalid expire time in 'set' command")
+					return
+				}
+
+			default:
+				writeError(pWriter, "ERR syntax error")
+				return
+		}
+	}
+
+	if isNX && isXX {
+		writeError(pWriter, "ERR syntax error")
+		return
+	}
+
+	key := string(argList[0])
+	keyList := []datacache.Key { key }
+	value := append([]byte(nil), argList[1]...)
+
+	if isNX {
+		if _, err := pDataCache.AddRecWithTTL(keyList, value, true, ttl); err != nil {
+			writeNil(pWriter)
+			return
+		}
+		writeSimpleString(pWriter, "OK")
+		return
+	}
+
+	var err error
+	if isXX {
+		pDataCache.WriteLock()  // reply is written once it's released, so that a slow client doesn't hold it.
+		isFound := pDataCache.DoesKeyExistWOLock(key)
+		if isFound {
+			_, err = pDataCache.ForceAddRecWithTTLWOLock(keyList, value, ttl)
+		}
+		pDataCache.WriteUnlock()
+
+		if !isFound {
+			writeNil(pWriter)
+			return
+		}
+	} else {
+		_, err = pDataCache.ForceAddRecWithTTL(keyList, value, ttl)
+	}
+
+	if err != nil {
+		writeError(pWriter, "ERR " + err.Error())
+		return
+	}
+
+	writeSimpleString(pWriter, "OK")
+}
+
+
+func cmdDel(pDataCache *datacache.DataCache, pWriter *bufio.Writer, argList [][]byte) {
+	var cnt int64
+
+	for _, arg := range argList {
+		key := string(arg)
+		if pDataCache.DoesKeyExist(key) && (pDataCache.DeleteKey(key) == nil) {
+			cnt++
+		}
+	}
+
+	writeInt(pWriter, cnt)
+}
+
+
+// Keys are counted as many times as they're mentioned, the way redis does.
+func cmdExists(pDataCache *datacache.DataCache, pWriter *bufio.Writer, argList [][]byte) {
+	var cnt int64
+
+	for _, arg := range argList {
+		if pDataCache.DoesKeyExist(string(arg)) {
+			cnt++
+		}
+	}
+
+	writeInt(pWriter, cnt)
+}
+
+
+// Number of records, rather than keys, in the cache.
+func cmdDBSize(pDataCache *datacache.DataCache, pWriter *bufio.Writer, argList [][]byte) {
+	_, cnt := pDataCache.GetCnt()
+	writeInt(pWriter, int64(cnt))
+}
+
+
+/* ****************************************************************************
+Description :
+SCAN cursor [MATCH pattern] [COUNT count]
+
+Receiver    : NA
+
+Implements  : NA
+
+Arguments   :
+1> pDataCache *datacache.DataCache: Datacache instance.
+2> pWriter *bufio.Writer: Reply is written to it.
+3> argList [][]byte: Command arguments.
+
+Return value: NA
+
+Additional note:
+- Keys are ordered by their 64-bit FNV-1a hash and the cursor is the hash of the key the
+next call starts with. Hence, a key present throughout the scan is returned at least once,
+irrespective of keys added or removed in between.
+- Each call lists the keys of the cache afresh. Thus, SCAN isn't cheaper than KEYS. It's
+there for the clients which don't use KEYS.
+**************************************************************************** */
+func cmdScan(pDataCache *datacache.DataCache, pWriter *bufio.Writer, argList [][]byte) {
+	cursor, err := strconv.ParseUint(string(argList[0]), 10, 64)
+	if err != nil {
+		writeError(pWriter, "ERR invalid cursor")
+		return
+	}
+
+	pattern := ""
+	cnt := defaultScanCount
+	for i := 1; i < len(argList); i += 2 {
+		if i + 1 >= len(argList) {
+			writeError(pWriter, "ERR syntax error")
+			return
+		}
+
+		switch strings.ToLower(string(argList[i])) {
+			case "match":
+				pattern = string(argList[i + 1])
+
+			case "count":
+				cnt, err = strconv.Atoi(string(argList[i + 1]))
+				if (err != nil) || (cnt <= 0) {
+					writeError(pWriter, "ERR syntax error")
+					return
+				}
+
+			default:
+				writeError(pWriter, "ERR syntax error")
+				return
+		}
+	}
+
+	type hashedKey struct {
+		hash uint64
+		key string
+	}
+
+	hashedKeyList := make([]hashedKey, 0)
+	for _, key := range stringKeys(pDataCache, pattern) {
+		if hash := keyHash(key); hash >= cursor {
+			hashedKeyList = append(hashedKeyList, hashedKey { hash, key })
+		}
+	}
+	sort.Slice(hashedKeyList, func(i, j int) bool {
+		if hashedKeyList[i].hash != hashedKeyList[j].hash {
+			return hashedKeyList[i].hash < hashedKeyList[j].hash
+		}
+		return hashedKeyList[i].key < hashedKeyList[j].key
+	})
+
+	// keys of the same hash aren't split across calls.
+	end := cnt
+	for (end < len(hashedKeyList)) && (hashedKeyList[end].hash == hashedKeyList[end - 1].hash) {
+		end++
+	}
+	if end > len(hashedKeyList) {
+		end = len(hashedKeyList)
+	}
+
+	nextCursor := uint64(0)
+	if end < len(hashedKeyList) {
+		nextCursor = hashedKeyList[end].hash
+	}
+
+	keyList := make([]string, end)
+	for i := range keyList {
+		keyList[i] = hashedKeyList[i].key
+	}
+
+	writeArrayHeader(pWriter, 2)
+	writeBulk(pWriter, []byte(strconv.FormatUint(nextCursor, 10)))
+	writeStringArray(pWriter, keyList)
+}
+
+
+// Non-positive seconds remove the key, the way redis does.
+func cmdExpire(pDataCache *datacache.DataCache, pWriter *bufio.Writer, argList [][]byte) {
+	seconds, err := strconv.ParseInt(string(argList[1]), 10, 64)
+	if err != nil {
+		writeError(pWriter, "ERR value is not an integer or out of range")
+		return
+	}
+
+	key := string(argList[0])
+	if seconds <= 0 {
+		cmdDel(pDataCache, pWriter, argList[:1])
+		return
+	}
+
+	ttl, isOK := toDuration(seconds, time.Second)
+	if !isOK {
+		writeError(pWriter, "ERR invalid expire time in 'expire' command")
+		return
+	}
+
+	if pDataCache.SetTTL(key, ttl) {
+		writeInt(pWriter, 1)
+	} else {
+		writeInt(pWriter, 0)
+	}
+}
+
+
+// -2 if key doesn't exist, -1 if it doesn't expire, remaining seconds otherwise.
+func cmdTTL(pDataCache *datacache.DataCache, pWriter *bufio.Writer, argList [][]byte) {
+	isOK, ttl := pDataCache.GetTTL(string(argList[0]))
+	switch {
+		case !isOK:
+			writeInt(pWriter, -2)
+
+		case ttl == datacache.NoTTL:
+			writeInt(pWriter, -1)
+
+		default:
+			writeInt(pWriter, int64((ttl + (time.Second / 2)) / time.Second))
+	}
+}
+
+
+func cmdKeys(pDataCache *datacache.DataCache, pWriter *bufio.Writer, argList [][]byte) {
+	keyList := stringKeys(pDataCache, string(argList[0]))
+	sort.Strings(keyList)
+	writeStringArray(pWriter, keyList)
+}
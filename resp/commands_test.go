@@ -0,0 +1,229 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sameeroak1110/datacache"
+)
+
+
+// Runs the command and returns its reply.
+func execTestCommand(pServer *Server, argList ...string) string {
+	var buf bytes.Buffer
+	pWriter := bufio.NewWriter(&buf)
+
+	byteArgList := make([][]byte, len(argList))
+	for i := range argList {
+		byteArgList[i] = []byte(argList[i])
+	}
+	pServer.execCommand(pWriter, byteArgList)
+	pWriter.Flush()
+
+	return buf.String()
+}
+
+
+// Commands are run in order against the same cache; each one relies on the state left by the previous ones.
+func TestCommands(t *testing.T) {
+	pDataCache := datacache.Create(nil, nil)
+	defer pDataCache.Close()
+	pServer := New(pDataCache)
+
+	testList := []struct {
+		argList []string
+		reply string
+	}{
+		{[]string{"PING"}, "+PONG\r\n"},
+		{[]string{"ping", "hi"}, "$2\r\nhi\r\n"},
+		{[]string{"NOPE"}, "-ERR unknown command 'NOPE'\r\n"},
+		{[]string{"GET"}, "-ERR wrong number of arguments for 'get' command\r\n"},
+		{[]string{"GET", "a"}, "$-1\r\n"},
+		{[]string{"SET", "a", "1"}, "+OK\r\n"},
+		{[]string{"GET", "a"}, "$1\r\n1\r\n"},
+		{[]string{"SET", "a", "2", "NX"}, "$-1\r\n"},
+		{[]string{"SET", "b", "2", "XX"}, "$-1\r\n"},
+		{[]string{"EXISTS", "b"}, ":0\r\n"},
+		{[]string{"SET", "a", "3", "XX"}, "+OK\r\n"},
+		{[]string{"GET", "a"}, "$1\r\n3\r\n"},
+		{[]string{"SET", "a", "4", "NX", "XX"}, "-ERR syntax error\r\n"},
+		{[]string{"SET", "a", "4", "EX"}, "-ERR syntax error\r\n"},
+		{[]string{"SET", "a", "4", "EX", "1", "PX", "1"}, "-ERR syntax error\r\n"},
+		{[]string{"SET", "a", "4", "EX", "0"}, "-ERR invalid expire time in 'set' command\r\n"},
+		{[]string{"SET", "a", "4", "BOGUS"}, "-ERR syntax error\r\n"},
+		{[]string{"SET", "a", "4", "EX", "9223372037"}, "-ERR invalid expire time in 'set' command\r\n"},
+		{[]string{"SET", "a", "4", "PX", "9223372036855"}, "-ERR invalid expire time in 'set' command\r\n"},
+		{[]string{"SET", "a", "4", "EX", "9223372036"}, "+OK\r\n"},
+		{[]string{"SET", "a", "3"}, "+OK\r\n"},
+		{[]string{"SET", "b", "5", "EX", "100"}, "+OK\r\n"},
+		{[]string{"TTL", "b"}, ":100\r\n"},
+		{[]string{"TTL", "a"}, ":-1\r\n"},
+		{[]string{"TTL", "c"}, ":-2\r\n"},
+		{[]string{"EXPIRE", "a", "50"}, ":1\r\n"},
+		{[]string{"TTL", "a"}, ":50\r\n"},
+		{[]string{"EXPIRE", "c", "50"}, ":0\r\n"},
+		{[]string{"EXPIRE", "a", "x"}, "-ERR value is not an integer or out of range\r\n"},
+		{[]string{"EXPIRE", "a", "9223372037"}, "-ERR invalid expire time in 'expire' command\r\n"},
+		{[]string{"TTL", "a"}, ":50\r\n"},
+		{[]string{"SET", "user:1", "u"}, "+OK\r\n"},
+		{[]string{"KEYS", "*"}, "*3\r\n$1\r\na\r\n$1\r\nb\r\n$6\r\nuser:1\r\n"},
+		{[]string{"KEYS", "user:*"}, "*1\r\n$6\r\nuser:1\r\n"},
+		{[]string{"SCAN", "0", "MATCH", "user:?", "COUNT", "10"}, "*2\r\n$1\r\n0\r\n*1\r\n$6\r\nuser:1\r\n"},
+		{[]string{"SCAN", "x"}, "-ERR invalid cursor\r\n"},
+		{[]string{"SCAN", "0", "COUNT"}, "-ERR syntax error\r\n"},
+		{[]string{"DBSIZE"}, ":3\r\n"},
+		{[]string{"EXISTS", "a", "a", "c"}, ":2\r\n"},
+		{[]string{"DEL", "a", "c"}, ":1\r\n"},
+		{[]string{"EXPIRE", "b", "0"}, ":1\r\n"},
+		{[]string{"DBSIZE"}, ":1\r\n"},
+	}
+
+	for _, test := range testList {
+		if reply := execTestCommand(pServer, test.argList...); reply != test.reply {
+			t.Fatalf("%q = %q, want %q", test.argList, reply, test.reply)
+		}
+	}
+}
+
+
+// SET XX racing DEL of an existing key never brings the deleted key back.
+func TestSetXXAgainstDel(t *testing.T) {
+	pDataCache := datacache.Create(nil, nil, datacache.WithShards(8))
+	defer pDataCache.Close()
+	pServer := New(pDataCache)
+
+	for i := 0; i < 2000; i++ {
+		pDataCache.AddRec([]datacache.Key{"k"}, []byte("v"), true)
+
+		doneChan := make(chan string)
+		go func() {
+			doneChan <- execTestCommand(pServer, "DEL", "k")
+		}()
+		setReply := execTestCommand(pServer, "SET", "k", "x", "XX")
+		delReply := <-doneChan
+
+		// DEL always finds k; SET XX either precedes it or finds nothing.
+		if (delReply != ":1\r\n") || pDataCache.DoesKeyExist("k") {
+			t.Fatalf("DEL = %q, SET XX = %q, k exists %v", delReply, setReply, pDataCache.DoesKeyExist("k"))
+		}
+	}
+}
+
+
+// SCAN returns each key exactly once across the calls when the cache isn't changed meanwhile.
+func TestScanCursor(t *testing.T) {
+	pDataCache := datacache.Create(nil, nil)
+	defer pDataCache.Close()
+	pServer := New(pDataCache)
+
+	const keyCnt = 50
+	for i := 0; i < keyCnt; i++ {
+		pDataCache.AddRec([]datacache.Key{string(rune('A' + i))}, "v", true)
+	}
+
+	isSeenMap := make(map[string]bool)
+	cursor := "0"
+	for i := 0; ; i++ {
+		reply := execTestCommand(pServer, "SCAN", cursor, "COUNT", "7")
+
+		// *2, $<len>, cursor and the array of keys.
+		pReader := bufio.NewReader(strings.NewReader(reply))
+		readLine(pReader)
+		readLine(pReader)
+		cursor, _ = readLine(pReader)
+		keyList, err := readCommand(pReader)
+		if err != nil {
+			t.Fatalf("malformed reply %q: %v", reply, err)
+		}
+		for _, key := range keyList {
+			if isSeenMap[string(key)] {
+				t.Fatalf("key %s returned twice", key)
+			}
+			isSeenMap[string(key)] = true
+		}
+
+		if cursor == "0" {
+			break
+		}
+		if i > keyCnt {
+			t.Fatalf("SCAN doesn't terminate")
+		}
+	}
+
+	if len(isSeenMap) != keyCnt {
+		t.Fatalf("SCAN returned %d keys, want %d", len(isSeenMap), keyCnt)
+	}
+}
+
+
+func TestServe(t *testing.T) {
+	pDataCache := datacache.Create(nil, nil)
+	defer pDataCache.Close()
+	pServer := New(pDataCache)
+
+	pListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- pServer.Serve(pListener)
+	}()
+
+	conn, err := net.Dial("tcp", pListener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	conn.Write([]byte("*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nv\r\nGET k\r\nQUIT\r\n"))
+	pReader := bufio.NewReader(conn)
+	for _, want := range []string{"+OK", "$1", "v", "+OK"} {
+		if line, err := readLine(pReader); (err != nil) || (line != want) {
+			t.Fatalf("reply line %q, %v; want %q", line, err, want)
+		}
+	}
+
+	pServer.Close()
+	if err = <-errChan; err != ErrServerClosed {
+		t.Fatalf("Serve() = %v, want ErrServerClosed", err)
+	}
+}
+
+
+// A line longer than the limit is rejected and the connection is closed, without waiting for its LF.
+func TestServeInlineTooBig(t *testing.T) {
+	pDataCache := datacache.Create(nil, nil)
+	defer pDataCache.Close()
+	pServer := New(pDataCache)
+	defer pServer.Close()
+
+	pListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	go pServer.Serve(pListener)
+
+	conn, err := net.Dial("tcp", pListener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	go conn.Write([]byte(strings.Repeat("a", 2 * maxInlineSize)))
+	pReader := bufio.NewReader(conn)
+	if line, err := readLine(pReader); (err != nil) || (line != "-ERR Protocol error: too big inline request") {
+		t.Fatalf("reply %q, %v; want too big inline request", line, err)
+	}
+	// connection is closed with unread data. hence, it may be reset rather than closed cleanly.
+	_, err = pReader.ReadByte()
+	if pNetErr, isOK := err.(net.Error); (err == nil) || (isOK && pNetErr.Timeout()) {
+		t.Fatalf("connection isn't closed: %v", err)
+	}
+}
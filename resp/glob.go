@@ -0,0 +1,115 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache/resp
+Filename    : github.com/sameeroak1110/datacache/resp/glob.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Redis style glob matching used by KEYS and SCAN MATCH.
+- Supported: "*" any sequence, "?" any single character, "[abc]", "[^abc]", "[a-z]" and
+"\" to escape the special characters. Unlike path.Match(), "/" isn't special.
+**************************************************************************** */
+package resp
+
+
+// Returns true if s matches glob pattern.
+func matchGlob(pattern string, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+			case '*':
+				for (len(pattern) > 0) && (pattern[0] == '*') {
+					pattern = pattern[1:]
+				}
+				if len(pattern) == 0 {
+					return true
+				}
+				for i := 0; i <= len(s); i++ {
+					if matchGlob(pattern, s[i:]) {
+						return true
+					}
+				}
+				return false
+
+			case '?':
+				if len(s) == 0 {
+					return false
+				}
+				pattern = pattern[1:]
+				s = s[1:]
+
+			case '[':
+				if len(s) == 0 {
+					return false
+				}
+				isMatch, rest := matchClass(pattern[1:], s[0])
+				if !isMatch {
+					return false
+				}
+				pattern = rest
+				s = s[1:]
+
+			case '\\':
+				if len(pattern) > 1 {
+					pattern = pattern[1:]
+				}
+				fallthrough
+
+			default:
+				if (len(s) == 0) || (pattern[0] != s[0]) {
+					return false
+				}
+				pattern = pattern[1:]
+				s = s[1:]
+		}
+	}
+
+	return len(s) == 0
+}
+
+
+// Matches c against character class. pattern follows the opening "[". Returns the pattern
+// following the closing "]". An unterminated class extends till the end of the pattern.
+func matchClass(pattern string, c byte) (bool, string) {
+	isNegated := false
+	if (len(pattern) > 0) && (pattern[0] == '^') {
+		isNegated = true
+		pattern = pattern[1:]
+	}
+
+	isMatch := false
+	for (len(pattern) > 0) && (pattern[0] != ']') {
+		if (pattern[0] == '\\') && (len(pattern) > 1) {
+			pattern = pattern[1:]
+			isMatch = isMatch || (pattern[0] == c)
+			pattern = pattern[1:]
+			continue
+		}
+
+		if (len(pattern) > 2) && (pattern[1] == '-') && (pattern[2] != ']') {
+			lo, hi := pattern[0], pattern[2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			isMatch = isMatch || ((c >= lo) && (c <= hi))
+			pattern = pattern[3:]
+			continue
+		}
+
+		isMatch = isMatch || (pattern[0] == c)
+		pattern = pattern[1:]
+	}
+
+	if len(pattern) > 0 {
+		pattern = pattern[1:]  // closing "]"
+	}
+
+	return isMatch != isNegated, pattern
+}
@@ -0,0 +1,43 @@
+package resp
+
+import (
+	"testing"
+)
+
+
+func TestMatchGlob(t *testing.T) {
+	testList := []struct {
+		pattern string
+		s string
+		isMatch bool
+	}{
+		{"*", "", true},
+		{"*", "any/thing", true},
+		{"user:*", "user:1", true},
+		{"user:*", "order:1", false},
+		{"*:1", "user:1", true},
+		{"a*b*c", "axxbyyc", true},
+		{"a*b*c", "axxbyy", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{"h[c-a]llo", "hbllo", true},
+		{"h[a-c]llo", "hdllo", false},
+		{"h[\\]]llo", "h]llo", true},
+		{"h\\*llo", "h*llo", true},
+		{"h\\*llo", "hello", false},
+		{"h[ab", "ha", true},
+		{"abc", "ab", false},
+		{"ab", "abc", false},
+	}
+
+	for _, test := range testList {
+		if isMatch := matchGlob(test.pattern, test.s); isMatch != test.isMatch {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", test.pattern, test.s, isMatch, test.isMatch)
+		}
+	}
+}
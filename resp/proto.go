@@ -0,0 +1,204 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache/resp
+Filename    : github.com/sameeroak1110/datacache/resp/proto.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- RESP2 wire protocol. Commands are read either as arrays of bulk strings, the way
+clients send them, or as inline commands, the way telnet-like tools send them.
+**************************************************************************** */
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+
+const (
+	maxArgs = 1024 * 1024     // upper limit of command arguments.
+	maxBulkSize = 512 << 20   // upper limit of a bulk string, same as that of redis.
+	maxPreallocArgs = 16      // argument list grows beyond it as arguments arrive.
+	maxInlineSize = 64 << 10  // upper limit of a line, i.e., an inline command or a header, same as that of redis.
+)
+
+var errInlineTooBig = errors.New("Protocol error: too big inline request")
+
+
+// Reads a line terminated by CRLF. LF alone is tolerated for inline commands. Returns errInlineTooBig
+// once the line crosses maxInlineSize, without waiting for the rest of it.
+func readLine(pReader *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		buf, err := pReader.ReadSlice('\n')
+		line = append(line, buf...)
+		if err == nil {
+			break
+		}
+		if err != bufio.ErrBufferFull {
+			return "", err
+		}
+		if len(line) > maxInlineSize {
+			return "", errInlineTooBig
+		}
+	}
+
+	tmpLine := strings.TrimSuffix(strings.TrimSuffix(string(line), "\n"), "\r")
+	if len(tmpLine) > maxInlineSize {
+		return "", errInlineTooBig
+	}
+
+	return tmpLine, nil
+}
+
+
+/* ****************************************************************************
+Description :
+Reads a single command from the connection.
+
+Receiver    : NA
+
+Implements  : NA
+
+Arguments   :
+1> pReader *bufio.Reader: Buffered connection reader.
+
+Return value:
+1> [][]byte: Command name followed by its arguments. Empty for an empty inline command and
+for an empty or null array.
+2> error: io.EOF once the client has closed the connection. Protocol error otherwise.
+
+Additional note:
+- Lengths in the headers come from the client. Hence, nothing is allocated upfront against them;
+argument list and bulk strings grow as the data actually arrives.
+- An inline command or a header longer than maxInlineSize is a protocol error.
+**************************************************************************** */
+func readCommand(pReader *bufio.Reader) ([][]byte, error) {
+	line, err := readLine(pReader)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(line, "*") {
+		fieldList := strings.Fields(line)
+		argList := make([][]byte, len(fieldList))
+		for i := range fieldList {
+			argList[i] = []byte(fieldList[i])
+		}
+		return argList, nil
+	}
+
+	argCnt, err := strconv.Atoi(line[1:])
+	if (err != nil) || (argCnt < -1) || (argCnt > maxArgs) {
+		return nil, errors.New("Protocol error: invalid multibulk length.")
+	}
+	if argCnt <= 0 {  // empty or null array.
+		return [][]byte{}, nil
+	}
+
+	prealloc := argCnt
+	if prealloc > maxPreallocArgs {
+		prealloc = maxPreallocArgs
+	}
+	argList := make([][]byte, 0, prealloc)
+	for i := 0; i < argCnt; i++ {
+		line, err = readLine(pReader)
+		if err != nil {
+			return nil, err
+		}
+
+		if !strings.HasPrefix(line, "$") {
+			return nil, errors.New(fmt.Sprintf("Protocol error: expected '$', got '%.1s'.", line))
+		}
+
+		size, err := strconv.Atoi(line[1:])
+		if (err != nil) || (size < 0) || (size > maxBulkSize) {
+			return nil, errors.New("Protocol error: invalid bulk length.")
+		}
+
+		buf, err := readBulk(pReader, size)
+		if err != nil {
+			return nil, err
+		}
+
+		argList = append(argList, buf)
+	}
+
+	return argList, nil
+}
+
+
+// Reads a bulk string of size bytes followed by CRLF. Buffer grows as the data arrives.
+func readBulk(pReader *bufio.Reader, size int) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, pReader, int64(size)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	var crlf [2]byte
+	if _, err := io.ReadFull(pReader, crlf[:]); err != nil {
+		return nil, err
+	}
+	if (crlf[0] != '\r') || (crlf[1] != '\n') {
+		return nil, errors.New("Protocol error: bulk string isn't terminated by CRLF.")
+	}
+
+	return buf.Bytes(), nil
+}
+
+
+func writeSimpleString(pWriter *bufio.Writer, s string) {
+	pWriter.WriteString("+" + s + "\r\n")
+}
+
+
+func writeError(pWriter *bufio.Writer, msg string) {
+	pWriter.WriteString("-" + strings.NewReplacer("\r", " ", "\n", " ").Replace(msg) + "\r\n")
+}
+
+
+func writeInt(pWriter *bufio.Writer, n int64) {
+	pWriter.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+
+func writeBulk(pWriter *bufio.Writer, buf []byte) {
+	pWriter.WriteString("$" + strconv.Itoa(len(buf)) + "\r\n")
+	pWriter.Write(buf)
+	pWriter.WriteString("\r\n")
+}
+
+
+func writeNil(pWriter *bufio.Writer) {
+	pWriter.WriteString("$-1\r\n")
+}
+
+
+func writeArrayHeader(pWriter *bufio.Writer, n int) {
+	pWriter.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
+
+
+func writeStringArray(pWriter *bufio.Writer, strList []string) {
+	writeArrayHeader(pWriter, len(strList))
+	for _, s := range strList {
+		writeBulk(pWriter, []byte(s))
+	}
+}
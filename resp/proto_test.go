@@ -0,0 +1,85 @@
+package resp
+
+import (
+	"bufio"
+	"io"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+
+func TestReadCommand(t *testing.T) {
+	testList := []struct {
+		name string
+		input string
+		argList []string
+		isErr bool
+	}{
+		{"array", "*2\r\n$3\r\nGET\r\n$1\r\nk\r\n", []string{"GET", "k"}, false},
+		{"inline", "PING  hello\r\n", []string{"PING", "hello"}, false},
+		{"inline LF", "PING\n", []string{"PING"}, false},
+		{"empty bulk", "*2\r\n$3\r\nGET\r\n$0\r\n\r\n", []string{"GET", ""}, false},
+		{"null array", "*-1\r\n", []string{}, false},
+		{"empty array", "*0\r\n", []string{}, false},
+		{"negative count", "*-2\r\n", nil, true},
+		{"count too large", "*1048577\r\n", nil, true},
+		{"count not a number", "*x\r\n", nil, true},
+		{"negative bulk", "*1\r\n$-1\r\n", nil, true},
+		{"bulk too large", "*1\r\n$536870913\r\n", nil, true},
+		{"missing $", "*1\r\n+GET\r\n", nil, true},
+		{"bad terminator", "*1\r\n$3\r\nGETxx", nil, true},
+		{"huge count, no data", "*1048576\r\n", nil, true},
+		{"huge bulk, no data", "*1\r\n$536870912\r\nab", nil, true},
+		{"inline at limit", strings.Repeat("a", maxInlineSize) + "\r\n", []string{strings.Repeat("a", maxInlineSize)}, false},
+		{"inline too big", strings.Repeat("a", maxInlineSize + 1) + "\r\n", nil, true},
+		{"inline too big, no LF", strings.Repeat("a", 2 * maxInlineSize), nil, true},
+		{"header too big", "*1\r\n$" + strings.Repeat("1", maxInlineSize), nil, true},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			argList, err := readCommand(bufio.NewReader(strings.NewReader(test.input)))
+			if test.isErr {
+				if err == nil {
+					t.Fatalf("readCommand(%q) = %q, want error", test.input, argList)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("readCommand(%q): %v", test.input, err)
+			}
+			strList := make([]string, len(argList))
+			for i := range argList {
+				strList[i] = string(argList[i])
+			}
+			if !reflect.DeepEqual(strList, test.argList) {
+				t.Fatalf("readCommand(%q) = %q, want %q", test.input, strList, test.argList)
+			}
+		})
+	}
+}
+
+
+func TestReadCommandEOF(t *testing.T) {
+	if _, err := readCommand(bufio.NewReader(strings.NewReader(""))); err != io.EOF {
+		t.Fatalf("err = %v, want io.EOF", err)
+	}
+}
+
+
+// A header announcing a huge bulk string mustn't allocate before the data arrives.
+func TestReadCommandNoPrealloc(t *testing.T) {
+	input := "*1048576\r\n$536870912\r\nab"
+
+	var memBefore, memAfter runtime.MemStats
+	runtime.ReadMemStats(&memBefore)
+	readCommand(bufio.NewReaderSize(strings.NewReader(input), 64))
+	runtime.ReadMemStats(&memAfter)
+
+	if delta := memAfter.TotalAlloc - memBefore.TotalAlloc; delta > 1 << 20 {
+		t.Fatalf("allocated %d bytes for a 2 byte bulk string", delta)
+	}
+}
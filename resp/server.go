@@ -0,0 +1,229 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache/resp
+Filename    : github.com/sameeroak1110/datacache/resp/server.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Optional TCP listener speaking RESP2, the redis protocol, so that redis-cli and redis
+clients can inspect and drive a datacache.
+- Meant for string-keyed caches with byte-slice payloads. Keys which aren't strings are
+invisible to KEYS and SCAN. Payloads are written through SET as []byte. GET also serves
+string and json.RawMessage payloads, for instance, those added over HTTP by datacached.
+- Supported commands: GET, SET, DEL, EXISTS, DBSIZE, SCAN, EXPIRE, TTL, KEYS, PING and QUIT.
+**************************************************************************** */
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"github.com/sameeroak1110/datacache"
+)
+
+
+// Returned by Serve() and ListenAndServe() once Close() has been invoked.
+var ErrServerClosed = errors.New("RESP server closed.")
+
+type Server struct {
+	pDataCache *datacache.DataCache
+	lock sync.Mutex                  // guards the fields below.
+	listenerList []net.Listener
+	connMap map[net.Conn]struct{}
+	isClosed bool
+	wg sync.WaitGroup                // connection go-routines.
+}
+
+
+// Creates RESP server front-end of pDataCache. Nothing is listened to until Serve() or ListenAndServe().
+func New(pDataCache *datacache.DataCache) *Server {
+	return &Server {
+		pDataCache: pDataCache,
+		connMap: make(map[net.Conn]struct{}),
+	}
+}
+
+
+// Listens on TCP address addr and serves connections. Blocks until Close() is invoked.
+func (pServer *Server) ListenAndServe(addr string) error {
+	pListener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return pServer.Serve(pListener)
+}
+
+
+/* ****************************************************************************
+Description :
+Accepts connections on pListener and serves each in its own go-routine.
+
+Receiver    :
+pServer *Server: RESP server instance.
+
+Implements  : NA
+
+Arguments   :
+1> pListener net.Listener: Listener. It's closed when Serve() returns.
+
+Return value:
+1> error: ErrServerClosed once Close() has been invoked. Accept error otherwise.
+
+Additional note: NA
+**************************************************************************** */
+func (pServer *Server) Serve(pListener net.Listener) error {
+	if pServer.pDataCache == nil {
+		pListener.Close()
+		return errors.New("Nil datacache.")
+	}
+
+	pServer.lock.Lock()
+	if pServer.isClosed {
+		pServer.lock.Unlock()
+		pListener.Close()
+		return ErrServerClosed
+	}
+	pServer.listenerList = append(pServer.listenerList, pListener)
+	pServer.lock.Unlock()
+
+	defer pListener.Close()
+
+	for {
+		conn, err := pListener.Accept()
+		if err != nil {
+			pServer.lock.Lock()
+			isClosed := pServer.isClosed
+			pServer.lock.Unlock()
+
+			if isClosed {
+				return ErrServerClosed
+			}
+			return err
+		}
+
+		pServer.lock.Lock()
+		if pServer.isClosed {
+			pServer.lock.Unlock()
+			conn.Close()
+			return ErrServerClosed
+		}
+		pServer.connMap[conn] = struct{}{}
+		pServer.wg.Add(1)
+		pServer.lock.Unlock()
+
+		go pServer.serveConn(conn)
+	}
+}
+
+
+// Closes listeners and connections, and waits for the connection go-routines to exit.
+func (pServer *Server) Close() error {
+	pServer.lock.Lock()
+	if pServer.isClosed {
+		pServer.lock.Unlock()
+		return nil
+	}
+	pServer.isClosed = true
+
+	for _, pListener := range pServer.listenerList {
+		pListener.Close()
+	}
+	for conn := range pServer.connMap {
+		conn.Close()
+	}
+	pServer.lock.Unlock()
+
+	pServer.wg.Wait()
+
+	return nil
+}
+
+
+// Serves a single connection. Commands of a connection are executed in the order they're received.
+// A panic whilst serving the connection closes just the connection.
+func (pServer *Server) serveConn(conn net.Conn) {
+	defer func() {
+		if err1 := recover(); err1 != nil {
+			fmt.Println("RESP connection", conn.RemoteAddr(), "recovered from panic:", err1)
+			debug.PrintStack()
+		}
+		conn.Close()
+		pServer.lock.Lock()
+		delete(pServer.connMap, conn)
+		pServer.lock.Unlock()
+		pServer.wg.Done()
+	}()
+
+	pReader := bufio.NewReader(conn)
+	pWriter := bufio.NewWriter(conn)
+
+	for {
+		argList, err := readCommand(pReader)
+		if err != nil {
+			if (err != io.EOF) && !errors.Is(err, net.ErrClosed) {
+				writeError(pWriter, "ERR " + err.Error())
+				pWriter.Flush()
+			}
+			return
+		}
+
+		if len(argList) == 0 {
+			continue
+		}
+
+		isQuit := pServer.execCommand(pWriter, argList)
+
+		// replies of pipelined commands are flushed together.
+		if (pReader.Buffered() == 0) || isQuit {
+			if err = pWriter.Flush(); err != nil {
+				return
+			}
+		}
+
+		if isQuit {
+			return
+		}
+	}
+}
+
+
+// Executes a command and writes its reply. Returns true if the connection is to be closed.
+func (pServer *Server) execCommand(pWriter *bufio.Writer, argList [][]byte) bool {
+	name := strings.ToLower(string(argList[0]))
+
+	if name == "quit" {
+		writeSimpleString(pWriter, "OK")
+		return true
+	}
+
+	cmd, isOK := commandMap[name]
+	if !isOK {
+		writeError(pWriter, fmt.Sprintf("ERR unknown command '%s'", argList[0]))
+		return false
+	}
+
+	argCnt := len(argList) - 1
+	if (argCnt < cmd.minArgs) || ((cmd.maxArgs >= 0) && (argCnt > cmd.maxArgs)) {
+		writeError(pWriter, fmt.Sprintf("ERR wrong number of arguments for '%s' command", name))
+		return false
+	}
+
+	cmd.fn(pServer.pDataCache, pWriter, argList[1:])
+
+	return false
+}
@@ -0,0 +1,310 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache/server
+Filename    : github.com/sameeroak1110/datacache/server/handlers.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Request handlers of the HTTP front-end. Each handler maps to a single DataCache method.
+**************************************************************************** */
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/sameeroak1110/datacache"
+)
+
+
+const flushEvery = 64  // records streamed between consecutive flushes.
+
+
+// GET /caches
+func (pServer *Server) handleCacheList(w http.ResponseWriter, r *http.Request) {
+	if allowMethod(w, r, http.MethodGet) {
+		writeJSON(w, http.StatusOK, datacache.List())
+	}
+}
+
+
+// GET /caches/{name}/count
+func handleCount(w http.ResponseWriter, pDataCache *datacache.DataCache) {
+	_, cnt := pDataCache.GetCnt()
+	writeJSON(w, http.StatusOK, countJSON { Count: cnt })
+}
+
+
+/* ****************************************************************************
+Description :
+GET /caches/{name}/records
+Streams all records of the cache as newline delimited JSON, one record per line.
+
+Receiver    : NA
+
+Implements  : NA
+
+Arguments   :
+1> w http.ResponseWriter: Response writer.
+2> pDataCache *datacache.DataCache: Cache to be iterated.
+
+Return value: NA
+
+Additional note:
+- A key of each record is collected through Scan(). Records are then fetched through their keys,
+encoded and written in batches of flushEvery records, each batch followed by a flush. Hence, the
+store-lock isn't held whilst the response is written and a slow client doesn't block writers of
+the cache. Memory taken is that of a key per record plus a batch of encoded records.
+- A record deleted whilst it's being streamed is skipped, and one added meanwhile isn't streamed.
+A record changed meanwhile is streamed as it's when its batch is encoded.
+- Status is already sent once streaming begins. Thus, a failure mid-way just truncates the stream.
+**************************************************************************** */
+func handleRecords(w http.ResponseWriter, pDataCache *datacache.DataCache) {
+	keyList := make([]datacache.Key, 0)
+	pDataCache.Scan(func(pRecIntf interface{}) bool {
+		if pRec := pRecIntf.(*datacache.Rec); len(pRec.KeyList) > 0 {
+			keyList = append(keyList, pRec.KeyList[0])
+		}
+		return true
+	})
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	pFlusher, _ := w.(http.Flusher)
+	isSeenMap := make(map[*datacache.Rec]bool, len(keyList))  // a key may've moved to a record streamed already.
+	var buf bytes.Buffer
+	pEncoder := json.NewEncoder(&buf)
+	for len(keyList) > 0 {
+		cnt := flushEvery
+		if cnt > len(keyList) {
+			cnt = len(keyList)
+		}
+
+		for _, key := range keyList[:cnt] {
+			isOK, pHandle := pDataCache.GetRecForRead(key)
+			if !isOK {  // deleted meanwhile.
+				continue
+			}
+
+			var err error
+			pHandle.Do(func(pRec *datacache.Rec) {
+				if !isSeenMap[pRec] {
+					isSeenMap[pRec] = true
+					err = pEncoder.Encode(recJSON {
+						Keys: pRec.KeyList,
+						Active: pRec.IsActive(),
+						Payload: pRec.PDataRec,
+					})
+				}
+			})
+			pHandle.Release()
+
+			if err != nil {
+				return
+			}
+		}
+		keyList = keyList[cnt:]
+
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return
+		}
+		buf.Reset()
+		if pFlusher != nil {
+			pFlusher.Flush()
+		}
+	}
+}
+
+
+// GET, HEAD, PUT and DELETE /caches/{name}/keys/{key}
+func handleKey(w http.ResponseWriter, r *http.Request, pDataCache *datacache.DataCache, key string) {
+	switch r.Method {
+		case http.MethodGet:
+			handleGet(w, pDataCache, key)
+
+		case http.MethodHead:
+			if pDataCache.DoesKeyExist(key) {
+				w.WriteHeader(http.StatusOK)
+			} else {
+				w.WriteHeader(http.StatusNotFound)
+			}
+
+		case http.MethodPut:
+			handlePut(w, r, pDataCache, key)
+
+		case http.MethodDelete:
+			cnt, err := pDataCache.DeleteRec(key)
+			if err != nil {
+				writeError(w, http.StatusNotFound, err.Error())
+				return
+			}
+			writeJSON(w, http.StatusOK, countJSON { Count: cnt })
+
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PUT, DELETE")
+			writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+	}
+}
+
+
+// Record is encoded whilst it's guarded in its record lock in shared mode.
+func handleGet(w http.ResponseWriter, pDataCache *datacache.DataCache, key string) {
+	isOK, pHandle := pDataCache.GetRecForRead(key)
+	if !isOK {
+		writeError(w, http.StatusNotFound, "Key \"" + key + "\" doesn't exist.")
+		return
+	}
+
+	var buf []byte
+	var err error
+	isOK = pHandle.Do(func(pRec *datacache.Rec) {
+		buf, err = json.Marshal(recJSON {
+			Keys: pRec.KeyList,
+			Active: pRec.IsActive(),
+			Payload: pRec.PDataRec,
+		})
+	})
+	pHandle.Release()
+
+	if !isOK {
+		writeError(w, http.StatusInternalServerError, "Lease of the record has expired.")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to encode record: " + err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(append(buf, '\n'))
+}
+
+
+/* ****************************************************************************
+Description :
+PUT /caches/{name}/keys/{key}
+Request body is decoded into a new payload of the cache and the payload is added against
+key and the keys listed in "alias" query parameters.
+
+Receiver    : NA
+
+Implements  : NA
+
+Arguments   :
+1> w http.ResponseWriter: Response writer.
+2> r *http.Request: Request.
+3> pDataCache *datacache.DataCache: Cache the record is added to.
+4> key string: Key of the record.
+
+Return value: NA
+
+Additional note:
+- Responds 409 if any of the keys exists, unless "force=true" is given in which case the
+record is added the way ForceAddRec() does.
+- "ttl" query parameter, if any, is parsed through time.ParseDuration().
+**************************************************************************** */
+func handlePut(w http.ResponseWriter, r *http.Request, pDataCache *datacache.DataCache, key string) {
+	query := r.URL.Query()
+
+	var ttl time.Duration
+	if ttlStr := query.Get("ttl"); ttlStr != "" {
+		var err error
+		if ttl, err = time.ParseDuration(ttlStr); (err != nil) || (ttl <= 0) {
+			writeError(w, http.StatusBadRequest, "Invalid ttl \"" + ttlStr + "\".")
+			return
+		}
+	}
+
+	keyList := []datacache.Key { key }
+	for _, alias := range query["alias"] {
+		if alias != "" {
+			keyList = append(keyList, alias)
+		}
+	}
+
+	pPayload := pDataCache.NewPayload()
+	if pPayload == nil {
+		var payload interface{}
+		if !decodeBody(w, r, &payload) {
+			return
+		}
+		pPayload = payload
+	} else if !decodeBody(w, r, pPayload) {
+		return
+	}
+
+	if pPayload == nil {
+		writeError(w, http.StatusBadRequest, "Null payload.")
+		return
+	}
+
+	var cnt int
+	var err error
+	if query.Get("force") == "true" {
+		cnt, err = pDataCache.ForceAddRecWithTTL(keyList, pPayload, ttl)
+	} else {
+		cnt, err = pDataCache.AddRecWithTTL(keyList, pPayload, true, ttl)
+	}
+
+	if err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, countJSON { Count: cnt })
+}
+
+
+// POST /caches/{name}/keys/{key}/aliases
+func handleAlias(w http.ResponseWriter, r *http.Request, pDataCache *datacache.DataCache, key string) {
+	var alias aliasJSON
+	if !decodeBody(w, r, &alias) {
+		return
+	}
+
+	if alias.Alias == "" {
+		writeError(w, http.StatusBadRequest, "Empty alias.")
+		return
+	}
+
+	cnt, err := pDataCache.ReAddRec(key, alias.Alias)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, countJSON { Count: cnt })
+}
+
+
+// POST /caches/{name}/keys/{key}/state
+func handleState(w http.ResponseWriter, r *http.Request, pDataCache *datacache.DataCache, key string) {
+	var state stateJSON
+	if !decodeBody(w, r, &state) {
+		return
+	}
+
+	if state.Active == nil {
+		writeError(w, http.StatusBadRequest, "Missing \"active\".")
+		return
+	}
+
+	if !pDataCache.UpdateRecState(key, *state.Active) {
+		writeError(w, http.StatusNotFound, "Key \"" + key + "\" doesn't exist.")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, state)
+}
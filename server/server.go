@@ -0,0 +1,215 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache/server
+Filename    : github.com/sameeroak1110/datacache/server/server.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- HTTP/JSON front-end of datacache. Caches are served by the name they're registered
+with in the datacache registry (see datacache.Register()).
+- Routes:
+GET    /caches                                  names of the served caches.
+GET    /caches/{name}/count                     number of records in the cache.
+GET    /caches/{name}/records                   all records, streamed as newline delimited JSON.
+GET    /caches/{name}/keys/{key}                record referred to by key.
+HEAD   /caches/{name}/keys/{key}                whether key exists.
+PUT    /caches/{name}/keys/{key}                adds the record. request body is the payload.
+                                                query: alias (repeatable) additional keys of the record.
+                                                force=true overrides an existing record. ttl=<duration>.
+DELETE /caches/{name}/keys/{key}                deletes the record referred to by key.
+POST   /caches/{name}/keys/{key}/aliases        maps the record to an additional key. body: {"alias": "..."}.
+POST   /caches/{name}/keys/{key}/state          activates/deactivates the record. body: {"active": true}.
+- 404 of an unknown cache carries "X-Datacache-Error: unknown-cache" header, so that it can
+be told apart from 404 of a missing key, even in the response to HEAD.
+- Keys travel in the URL path and therefore are strings. Payloads are decoded into the
+payload type registered with the cache through datacache.WithPayloadFactory(), or into
+generic JSON values if the cache has no payload factory.
+**************************************************************************** */
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sameeroak1110/datacache"
+)
+
+
+const maxBodySize = 8 << 20  // upper limit of request body size.
+
+// Error responses which need telling apart from others of the same status carry an error code in this header.
+// For instance, 404 of an unknown cache from that of a missing key, as responses to HEAD have no body.
+const (
+	errorCodeHeader = "X-Datacache-Error"
+	errorCodeUnknownCache = "unknown-cache"
+)
+
+// Server serves the caches of the datacache registry over HTTP.
+type Server struct {
+	pMux *http.ServeMux
+}
+
+// JSON representation of a cache record.
+type recJSON struct {
+	Keys []datacache.Key    `json:"keys"`
+	Active bool             `json:"active"`
+	Payload interface{}     `json:"payload"`
+}
+
+type errorJSON struct {
+	Error string    `json:"error"`
+}
+
+type countJSON struct {
+	Count int    `json:"count"`
+}
+
+type aliasJSON struct {
+	Alias string    `json:"alias"`
+}
+
+type stateJSON struct {
+	Active *bool    `json:"active"`
+}
+
+
+// Creates HTTP server front-end. Returned *Server is an http.Handler.
+func New() *Server {
+	pServer := &Server {
+		pMux: http.NewServeMux(),
+	}
+
+	pServer.pMux.HandleFunc("/caches", pServer.handleCacheList)
+	pServer.pMux.HandleFunc("/caches/", pServer.handleCache)
+
+	return pServer
+}
+
+
+// Implements http.Handler.
+func (pServer *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	pServer.pMux.ServeHTTP(w, r)
+}
+
+
+// Splits escaped URL path into unescaped segments. Escaped "/" within a key is retained.
+func splitPath(escapedPath string) ([]string, bool) {
+	segList := strings.Split(strings.Trim(escapedPath, "/"), "/")
+	for i := range segList {
+		seg, err := url.PathUnescape(segList[i])
+		if (err != nil) || (seg == "") {
+			return nil, false
+		}
+		segList[i] = seg
+	}
+
+	return segList, true
+}
+
+
+/* ****************************************************************************
+Description :
+Routes /caches/{name}/... requests to the respective handler.
+
+Receiver    :
+pServer *Server: Server instance.
+
+Implements  : NA
+
+Arguments   :
+1> w http.ResponseWriter: Response writer.
+2> r *http.Request: Request.
+
+Return value: NA
+
+Additional note:
+- Path is parsed by hand as net/http of go1.18 doesn't support wildcards in patterns.
+**************************************************************************** */
+func (pServer *Server) handleCache(w http.ResponseWriter, r *http.Request) {
+	segList, isOK := splitPath(r.URL.EscapedPath())
+	if !isOK || (len(segList) < 3) || (segList[0] != "caches") {
+		writeError(w, http.StatusNotFound, "Unknown resource.")
+		return
+	}
+
+	pDataCache, isOK := datacache.Lookup(segList[1])
+	if !isOK {
+		w.Header().Set(errorCodeHeader, errorCodeUnknownCache)
+		writeError(w, http.StatusNotFound, "Cache \"" + segList[1] + "\" doesn't exist.")
+		return
+	}
+
+	switch {
+		case (len(segList) == 3) && (segList[2] == "count"):
+			if allowMethod(w, r, http.MethodGet) {
+				handleCount(w, pDataCache)
+			}
+
+		case (len(segList) == 3) && (segList[2] == "records"):
+			if allowMethod(w, r, http.MethodGet) {
+				handleRecords(w, pDataCache)
+			}
+
+		case (len(segList) == 4) && (segList[2] == "keys"):
+			handleKey(w, r, pDataCache, segList[3])
+
+		case (len(segList) == 5) && (segList[2] == "keys") && (segList[4] == "aliases"):
+			if allowMethod(w, r, http.MethodPost) {
+				handleAlias(w, r, pDataCache, segList[3])
+			}
+
+		case (len(segList) == 5) && (segList[2] == "keys") && (segList[4] == "state"):
+			if allowMethod(w, r, http.MethodPost) {
+				handleState(w, r, pDataCache, segList[3])
+			}
+
+		default:
+			writeError(w, http.StatusNotFound, "Unknown resource.")
+	}
+}
+
+
+// Writes 405 and returns false unless the request method is method.
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+	return false
+}
+
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, errorJSON { Error: msg })
+}
+
+
+// Decodes JSON request body into v.
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	pDecoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err := pDecoder.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "Malformed request body: " + err.Error())
+		return false
+	}
+
+	return true
+}
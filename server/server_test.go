@@ -0,0 +1,188 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sameeroak1110/datacache"
+)
+
+
+// Requests are run in order against the same cache; each one relies on the state left by the previous ones.
+func TestServer(t *testing.T) {
+	pDataCache := datacache.Create(nil, nil)
+	defer pDataCache.Close()
+	if err := datacache.Register("srv-test", pDataCache); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	defer datacache.Unregister("srv-test")
+
+	pTestServer := httptest.NewServer(New())
+	defer pTestServer.Close()
+
+	testList := []struct {
+		name string
+		method string
+		path string
+		body string
+		status int
+		respBody string    // expected substring of the response body.
+	}{
+		{"cache list", http.MethodGet, "/caches", "", http.StatusOK, `"srv-test"`},
+		{"unknown cache", http.MethodGet, "/caches/nope/count", "", http.StatusNotFound, "doesn't exist"},
+		{"unknown resource", http.MethodGet, "/caches/srv-test/nope", "", http.StatusNotFound, "Unknown resource"},
+		{"get missing", http.MethodGet, "/caches/srv-test/keys/k", "", http.StatusNotFound, "doesn't exist"},
+		{"put", http.MethodPut, "/caches/srv-test/keys/k?alias=k2", `{"n":1}`, http.StatusCreated, `"count":1`},
+		{"put existing", http.MethodPut, "/caches/srv-test/keys/k", `{"n":2}`, http.StatusConflict, ""},
+		{"put forced", http.MethodPut, "/caches/srv-test/keys/k?force=true", `{"n":2}`, http.StatusCreated, `"count":2`},
+		{"put malformed", http.MethodPut, "/caches/srv-test/keys/m", `{`, http.StatusBadRequest, "Malformed"},
+		{"put null", http.MethodPut, "/caches/srv-test/keys/m", `null`, http.StatusBadRequest, "Null payload"},
+		{"put bad ttl", http.MethodPut, "/caches/srv-test/keys/m?ttl=-1s", `1`, http.StatusBadRequest, "Invalid ttl"},
+		{"get", http.MethodGet, "/caches/srv-test/keys/k", "", http.StatusOK, `"payload":{"n":2}`},
+		{"head", http.MethodHead, "/caches/srv-test/keys/k", "", http.StatusOK, ""},
+		{"escaped slash in key", http.MethodPut, "/caches/srv-test/keys/a%2Fb", `"s"`, http.StatusCreated, ""},
+		{"get escaped slash", http.MethodGet, "/caches/srv-test/keys/a%2Fb", "", http.StatusOK, `"keys":["a/b"]`},
+		{"alias", http.MethodPost, "/caches/srv-test/keys/k/aliases", `{"alias":"k3"}`, http.StatusOK, ""},
+		{"empty alias", http.MethodPost, "/caches/srv-test/keys/k/aliases", `{"alias":""}`, http.StatusBadRequest, ""},
+		{"deactivate", http.MethodPost, "/caches/srv-test/keys/k3/state", `{"active":false}`, http.StatusOK, `"active":false`},
+		{"state missing", http.MethodPost, "/caches/srv-test/keys/k/state", `{}`, http.StatusBadRequest, ""},
+		{"get inactive", http.MethodGet, "/caches/srv-test/keys/k", "", http.StatusOK, `"active":false`},
+		{"count", http.MethodGet, "/caches/srv-test/count", "", http.StatusOK, `"count":3`},
+		{"records", http.MethodGet, "/caches/srv-test/records", "", http.StatusOK, `"payload":"s"`},
+		{"method not allowed", http.MethodPost, "/caches/srv-test/count", "", http.StatusMethodNotAllowed, ""},
+		{"delete", http.MethodDelete, "/caches/srv-test/keys/k2", "", http.StatusOK, `"count":2`},
+		{"delete missing", http.MethodDelete, "/caches/srv-test/keys/k2", "", http.StatusNotFound, ""},
+		{"head missing", http.MethodHead, "/caches/srv-test/keys/k2", "", http.StatusNotFound, ""},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			pReq, err := http.NewRequest(test.method, pTestServer.URL + test.path, strings.NewReader(test.body))
+			if err != nil {
+				t.Fatalf("NewRequest: %v", err)
+			}
+			pResp, err := http.DefaultClient.Do(pReq)
+			if err != nil {
+				t.Fatalf("%s %s: %v", test.method, test.path, err)
+			}
+			defer pResp.Body.Close()
+			body, _ := io.ReadAll(pResp.Body)
+
+			if pResp.StatusCode != test.status {
+				t.Fatalf("%s %s = %d %s, want %d", test.method, test.path, pResp.StatusCode, body, test.status)
+			}
+			if !strings.Contains(string(body), test.respBody) {
+				t.Fatalf("%s %s body %s lacks %s", test.method, test.path, body, test.respBody)
+			}
+		})
+	}
+}
+
+
+// GET takes the record lock in shared mode, hence, it doesn't bump the version of the record.
+func TestServerGetKeepsVersion(t *testing.T) {
+	pDataCache := datacache.Create(nil, nil)
+	defer pDataCache.Close()
+	pDataCache.AddRec([]datacache.Key{"k"}, 1, true)
+	_, _, version := pDataCache.GetDataRecVersion("k")
+
+	w := httptest.NewRecorder()
+	handleGet(w, pDataCache, "k")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET = %d %s, want %d", w.Code, w.Body.String(), http.StatusOK)
+	}
+
+	if _, _, tmpVersion := pDataCache.GetDataRecVersion("k"); tmpVersion != version {
+		t.Fatalf("version %d after GET, want %d", tmpVersion, version)
+	}
+}
+
+
+// Writes to the response block until unblockChan is closed.
+type stalledWriter struct {
+	*httptest.ResponseRecorder
+	unblockChan chan struct{}
+}
+
+func (w stalledWriter) Write(buf []byte) (int, error) {
+	<-w.unblockChan
+	return w.ResponseRecorder.Write(buf)
+}
+
+
+// A client which doesn't read the records stream doesn't block writers of the cache.
+func TestServerRecordsStalledClient(t *testing.T) {
+	pDataCache := datacache.Create(nil, nil)
+	defer pDataCache.Close()
+	pDataCache.AddRec([]datacache.Key{"a"}, 1, true)
+
+	w := stalledWriter { httptest.NewRecorder(), make(chan struct{}) }
+	doneChan := make(chan struct{})
+	go func() {
+		handleRecords(w, pDataCache)
+		close(doneChan)
+	}()
+
+	addChan := make(chan struct{})
+	go func() {
+		time.Sleep(10 * time.Millisecond)  // stream is stalled by now.
+		pDataCache.AddRec([]datacache.Key{"b"}, 2, true)
+		close(addChan)
+	}()
+
+	select {
+	case <-addChan:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("AddRec blocked by a stalled records stream")
+	}
+
+	close(w.unblockChan)
+	<-doneChan
+	if !strings.Contains(w.Body.String(), `"keys":["a"]`) {
+		t.Fatalf("records stream %s lacks record a", w.Body.String())
+	}
+}
+
+
+// Records writes made to the response.
+type batchRecorder struct {
+	*httptest.ResponseRecorder
+	writeList []string
+}
+
+func (w *batchRecorder) Write(buf []byte) (int, error) {
+	w.writeList = append(w.writeList, string(buf))
+	return w.ResponseRecorder.Write(buf)
+}
+
+
+// Records are streamed in batches of at most flushEvery records, each record once.
+func TestServerRecordsBatches(t *testing.T) {
+	pDataCache := datacache.Create(nil, nil)
+	defer pDataCache.Close()
+
+	const recCnt = 3 * flushEvery + 1
+	for i := 0; i < recCnt; i++ {
+		pDataCache.AddRec([]datacache.Key{fmt.Sprintf("k%d", i), fmt.Sprintf("alias%d", i)}, i, true)
+	}
+
+	w := &batchRecorder { ResponseRecorder: httptest.NewRecorder() }
+	handleRecords(w, pDataCache)
+
+	if len(w.writeList) != 4 {
+		t.Fatalf("%d writes, want 4", len(w.writeList))
+	}
+	for _, batch := range w.writeList {
+		if lineCnt := strings.Count(batch, "\n"); lineCnt > flushEvery {
+			t.Fatalf("batch of %d records, want at most %d", lineCnt, flushEvery)
+		}
+	}
+	if lineCnt := strings.Count(w.Body.String(), "\n"); lineCnt != recCnt {
+		t.Fatalf("%d records streamed, want %d", lineCnt, recCnt)
+	}
+}
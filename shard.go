@@ -0,0 +1,321 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache
+Filename    : github.com/sameeroak1110/datacache/shard.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Sharded cache store. Keys are spread across N maps, each guarded in its own shard-lock,
+by the hash of the key. Shard count is set through WithShards(). Default is a single shard.
+- Store-lock remains the cache-wide lock above the shard-locks:
+	- single key operations, such as GetRec() and GetDataRec(), take RD store-lock and RD
+	shard-lock of the key.
+	- single record mutations, such as AddRec() and DeleteRec(), take RD store-lock and WR
+	shard-locks of every key involved. It's not just the given keys but all keys of each record
+	the given keys are associated to, since aliases of a record may fall in different shards.
+	A record and its key list thus change only whilst all its shards are held.
+	- whole-cache operations, such as Iterate(), AuxIterate(), DeleteCache() and Reload(),
+	and WriteLock() take WR store-lock, which excludes everything else. ReadLock() takes RD
+	store-lock and RD shard-lock of every shard.
+- Bounded cache evicts records of any shard whilst a record is added. Hence, mutations of
+a bounded cache take WR store-lock just like it's been before sharding.
+- Lock order is store-lock, shard-locks in ascending order of shard index, record lock.
+**************************************************************************** */
+package datacache
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+
+type cacheShard struct {
+	lock sync.RWMutex  // shard-lock.
+	cache CacheStore
+}
+
+
+func newShards(shardCnt int) []*cacheShard {
+	if shardCnt < 1 {
+		shardCnt = 1
+	}
+
+	shardList := make([]*cacheShard, shardCnt)
+	for i := range shardList {
+		shardList[i] = &cacheShard {
+			cache: make(CacheStore),
+		}
+	}
+
+	return shardList
+}
+
+
+// Number of shards of the cache store. Keys are spread across shards by their hash, so that
+// operations on keys of different shards don't contend for the same lock. shardCnt <= 1 means
+// a single shard, which is the default.
+func WithShards(shardCnt int) Option {
+	return func(pDataCache *DataCache) {
+		if shardCnt > 1 {
+			pDataCache.shards = newShards(shardCnt)
+		}
+	}
+}
+
+
+// Returns index of the shard key falls in.
+func (pDataCache *DataCache) shardIdx(key Key) int {
+	if len(pDataCache.shards) == 1 {
+		return 0
+	}
+
+	return int(hashKey(key) % uint64(len(pDataCache.shards)))
+}
+
+
+// Returns the record key is associated to.
+// Must be invoked in WR store-lock or in the shard-lock of key.
+func (pDataCache *DataCache) lookupWOLock(key Key) (*Rec, bool) {
+	pRec, isOK := pDataCache.shards[pDataCache.shardIdx(key)].cache[key]
+	return pRec, isOK
+}
+
+
+// Associates key to pRec.
+// Must be invoked in WR store-lock or in WR shard-lock of key.
+func (pDataCache *DataCache) mapKeyWOLock(key Key, pRec *Rec) {
+	pDataCache.shards[pDataCache.shardIdx(key)].cache[key] = pRec
+	pDataCache.pCOW.stage(key, pRec)
+}
+
+
+// Disassociates key.
+// Must be invoked in WR store-lock or in WR shard-lock of key.
+func (pDataCache *DataCache) unmapKeyWOLock(key Key) {
+	delete(pDataCache.shards[pDataCache.shardIdx(key)].cache, key)
+	pDataCache.pCOW.stage(key, nil)
+}
+
+
+// Returns number of keys in the cache.
+// Must be invoked in WR store-lock or in RD shard-lock of every shard.
+func (pDataCache *DataCache) keyCntWOLock() int {
+	cnt := 0
+	for _, pShard := range pDataCache.shards {
+		cnt = cnt + len(pShard.cache)
+	}
+
+	return cnt
+}
+
+
+// Returns number of records in the cache. Count is maintained atomically, hence, no store-lock is needed.
+func (pDataCache *DataCache) recCnt() int {
+	return int(atomic.LoadInt64(&pDataCache.cnt))
+}
+
+
+func (pDataCache *DataCache) addRecCnt(delta int64) {
+	atomic.AddInt64(&pDataCache.cnt, delta)
+}
+
+
+func (pDataCache *DataCache) addBytes(delta int64) {
+	atomic.AddInt64(&pDataCache.bytes, delta)
+}
+
+
+// Releases WR store-lock. Staged changes of a copy-on-write cache are published first, unless they're
+// published in batches by the interval. Change events raised in the lock are delivered once it's released.
+func (pDataCache *DataCache) unlockStore() {
+	if (pDataCache.pCOW != nil) && (pDataCache.pCOW.batchInterval == 0) {
+		pDataCache.pCOW.publish()
+	}
+	pDataCache.cacheLock.Unlock()
+	pDataCache.dispatchEvents()
+}
+
+
+// Takes RD store-lock and RD shard-lock of every shard. WOLock methods may be invoked in it.
+func (pDataCache *DataCache) rlockStore() {
+	pDataCache.cacheLock.RLock()
+	for _, pShard := range pDataCache.shards {
+		pShard.lock.RLock()
+	}
+}
+
+
+func (pDataCache *DataCache) runlockStore() {
+	for i := len(pDataCache.shards) - 1; i >= 0; i-- {
+		pDataCache.shards[i].lock.RUnlock()
+	}
+	pDataCache.cacheLock.RUnlock()
+}
+
+
+// Same as rlockStore() but doesn't block. Returns false, holding nothing, if any of the locks is held
+// in WR mode.
+func (pDataCache *DataCache) tryRLockStore() bool {
+	if !pDataCache.cacheLock.TryRLock() {
+		return false
+	}
+
+	for i, pShard := range pDataCache.shards {
+		if !pShard.lock.TryRLock() {
+			for j := i - 1; j >= 0; j-- {
+				pDataCache.shards[j].lock.RUnlock()
+			}
+			pDataCache.cacheLock.RUnlock()
+			return false
+		}
+	}
+
+	return true
+}
+
+
+// Takes RD store-lock and RD shard-lock of key. Single key reads are done in it.
+func (pDataCache *DataCache) rlockKey(key Key) {
+	pDataCache.cacheLock.RLock()
+	pDataCache.shards[pDataCache.shardIdx(key)].lock.RLock()
+}
+
+
+func (pDataCache *DataCache) runlockKey(key Key) {
+	pDataCache.shards[pDataCache.shardIdx(key)].lock.RUnlock()
+	pDataCache.cacheLock.RUnlock()
+}
+
+
+// Returns sorted indexes of the shards keys fall in.
+func (pDataCache *DataCache) shardIdxList(keyList []Key) []int {
+	idxMap := make(map[int]bool, len(keyList))
+	idxList := make([]int, 0, len(keyList))
+	for _, key := range keyList {
+		idx := pDataCache.shardIdx(key)
+		if !idxMap[idx] {
+			idxMap[idx] = true
+			idxList = append(idxList, idx)
+		}
+	}
+	sort.Ints(idxList)
+
+	return idxList
+}
+
+
+/* ****************************************************************************
+Description :
+Takes locks needed to add, alias or remove records keyList refers to. Store-lock is taken
+in RD mode and shard-locks in WR mode. Shards are those of keyList and of all keys of each
+record keyList is currently associated to.
+
+Receiver    :
+pDataCache *DataCache: Instance of datacache.
+
+Implements  : NA
+
+Arguments   :
+1> keyList []Key: Keys being mutated.
+
+Return value:
+1> func(): Releases the locks and delivers change events raised in them.
+
+Additional note:
+- Key lists of the records are known only once the shard-locks of keyList are held. Hence,
+in case the records span more shards, shard-locks are released and taken again, in order,
+for the superset. That's repeated until every shard involved is held.
+- Single shard, bounded and copy-on-write caches take WR store-lock instead. Eviction of a bounded
+cache may remove records of any shard. Writes of a copy-on-write cache are published as a whole.
+**************************************************************************** */
+func (pDataCache *DataCache) lockKeys(keyList []Key) func() {
+	unlock, _ := pDataCache.acquireKeys(keyList, false)
+	return unlock
+}
+
+
+// Same as lockKeys() but doesn't block. Returns false, holding nothing, in case any of the locks
+// is held by some other go-routine.
+func (pDataCache *DataCache) tryLockKeys(keyList []Key) (func(), bool) {
+	return pDataCache.acquireKeys(keyList, true)
+}
+
+
+func (pDataCache *DataCache) acquireKeys(keyList []Key, isTry bool) (func(), bool) {
+	if (len(pDataCache.shards) == 1) || (pDataCache.policy != nil) || (pDataCache.pCOW != nil) {
+		if !isTry {
+			pDataCache.cacheLock.Lock()
+		} else if !pDataCache.cacheLock.TryLock() {
+			return nil, false
+		}
+		return pDataCache.unlockStore, true
+	}
+
+	if !isTry {
+		pDataCache.cacheLock.RLock()
+	} else if !pDataCache.cacheLock.TryRLock() {
+		return nil, false
+	}
+
+	unlockShards := func(idxList []int) {
+		for i := len(idxList) - 1; i >= 0; i-- {
+			pDataCache.shards[idxList[i]].lock.Unlock()
+		}
+	}
+
+	idxList := pDataCache.shardIdxList(keyList)
+	for {
+		for i, idx := range idxList {
+			if !isTry {
+				pDataCache.shards[idx].lock.Lock()
+			} else if !pDataCache.shards[idx].lock.TryLock() {
+				unlockShards(idxList[:i])
+				pDataCache.cacheLock.RUnlock()
+				return nil, false
+			}
+		}
+
+		isLockedMap := make(map[int]bool, len(idxList))
+		for _, idx := range idxList {
+			isLockedMap[idx] = true
+		}
+
+		isAllLocked := true
+		for _, key := range keyList {
+			if pRec, isOK := pDataCache.lookupWOLock(key); isOK {
+				for _, tmpKey := range pRec.KeyList {
+					if idx := pDataCache.shardIdx(tmpKey); !isLockedMap[idx] {
+						isLockedMap[idx] = true
+						isAllLocked = false
+					}
+				}
+			}
+		}
+
+		if isAllLocked {
+			break
+		}
+
+		unlockShards(idxList)
+		idxList = make([]int, 0, len(isLockedMap))
+		for idx := range isLockedMap {
+			idxList = append(idxList, idx)
+		}
+		sort.Ints(idxList)
+	}
+
+	return func() {
+		unlockShards(idxList)
+		pDataCache.cacheLock.RUnlock()
+		pDataCache.dispatchEvents()
+	}, true
+}
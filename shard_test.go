@@ -0,0 +1,160 @@
+package datacache
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+
+// Checks that every key refers to a record listing that key, that every key of a record refers to
+// that record and that the record count matches. Must be invoked whilst nothing else runs on the cache.
+func checkStoreConsistency(t *testing.T, pDataCache *DataCache) {
+	t.Helper()
+
+	recMap := make(map[*Rec]bool)
+	for _, pShard := range pDataCache.shards {
+		for key, pRec := range pShard.cache {
+			recMap[pRec] = true
+			isListed := false
+			for _, tmpKey := range pRec.KeyList {
+				isListed = isListed || (tmpKey == key)
+				if pTmpRec, isOK := pDataCache.lookupWOLock(tmpKey); !isOK || (pTmpRec != pRec) {
+					t.Fatalf("key %v of record %v doesn't refer to it", tmpKey, pRec.KeyList)
+				}
+			}
+			if !isListed {
+				t.Fatalf("key %v refers to record %v which doesn't list it", key, pRec.KeyList)
+			}
+		}
+	}
+
+	if pDataCache.recCnt() != len(recMap) {
+		t.Fatalf("record count %d, want %d", pDataCache.recCnt(), len(recMap))
+	}
+}
+
+
+// Concurrent adds, aliases and deletes of records whose aliases span shards leave the store consistent.
+func TestShardsConsistency(t *testing.T) {
+	testList := []struct {
+		name string
+		shardCnt int
+	}{
+		{"single shard", 1},
+		{"8 shards", 8},
+		{"64 shards", 64},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			pDataCache := Create(nil, nil, WithShards(test.shardCnt))
+			defer pDataCache.Close()
+
+			const goroutineCnt = 8
+			var wg sync.WaitGroup
+			for i := 0; i < goroutineCnt; i++ {
+				wg.Add(1)
+				go func(seed int64) {
+					defer wg.Done()
+					pRand := rand.New(rand.NewSource(seed))
+					key := func() Key {
+						return fmt.Sprintf("k%d", pRand.Intn(64))
+					}
+
+					for j := 0; j < 2000; j++ {
+						switch pRand.Intn(5) {
+							case 0:
+								pDataCache.AddRec([]Key{key(), key()}, j, true)
+							case 1:
+								pDataCache.ForceAddRec([]Key{key(), key()}, j)
+							case 2:
+								pDataCache.ReAddRec(key(), key())
+							case 3:
+								pDataCache.DeleteRec(key())
+							case 4:
+								if isOK, pRec := pDataCache.GetRec(key()); isOK {
+									pRec.DataCacheRecUnlock()
+								}
+						}
+					}
+				}(int64(i))
+			}
+			runWithTimeout(t, 30 * time.Second, wg.Wait)
+
+			checkStoreConsistency(t, pDataCache)
+		})
+	}
+}
+
+
+// lockKeys() holds the shards of every alias of the record, not just the shard of the given key.
+func TestLockKeysAliasShards(t *testing.T) {
+	pDataCache := Create(nil, nil, WithShards(8))
+	defer pDataCache.Close()
+
+	key, alias := Key("a"), Key(nil)
+	for i := 0; alias == nil; i++ {
+		if tmpKey := fmt.Sprintf("b%d", i); pDataCache.shardIdx(tmpKey) != pDataCache.shardIdx(key) {
+			alias = tmpKey
+		}
+	}
+	pDataCache.AddRec([]Key{key, alias}, "v", true)
+
+	unlock := pDataCache.lockKeys([]Key{key})
+	pShard := pDataCache.shards[pDataCache.shardIdx(alias)]
+	isLocked := !pShard.lock.TryRLock()
+	if !isLocked {
+		pShard.lock.RUnlock()
+	}
+	unlock()
+
+	if !isLocked {
+		t.Fatalf("shard of alias %v isn't held", alias)
+	}
+}
+
+
+// Equal keys map to the same shard: a pointer key by its address, whatever it points to, and -0 as 0.
+func TestShardsKeyHash(t *testing.T) {
+	type point struct {
+		x, y int
+	}
+	type ptrKey struct {
+		pPoint *point
+	}
+
+	pPoint := &point { 1, 2 }
+	testList := []struct {
+		name string
+		key Key
+		mutate func()
+		lookupKey Key
+	}{
+		{"pointer", pPoint, func() { pPoint.x = 100 }, pPoint},
+		{"struct with pointer", ptrKey { pPoint }, func() { pPoint.y = 200 }, ptrKey { pPoint }},
+		{"float64 zero", math.Copysign(0, -1), func() {}, 0.0},
+		{"float32 zero", float32(math.Copysign(0, -1)), func() {}, float32(0)},
+		{"array of floats", [2]float64 { math.Copysign(0, -1), 1 }, func() {}, [2]float64 { 0, 1 }},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			pDataCache := Create(nil, nil, WithShards(64))
+			defer pDataCache.Close()
+
+			pDataCache.AddRec([]Key{test.key}, "v", true)
+			test.mutate()
+			if isOK, pDataRec := pDataCache.GetDataRec(test.lookupKey); !isOK || (pDataRec != "v") {
+				t.Fatalf("GetDataRec(%v) = %v, %v; want v", test.lookupKey, isOK, pDataRec)
+			}
+			if _, err := pDataCache.DeleteRec(test.lookupKey); err != nil {
+				t.Fatalf("DeleteRec(%v): %v", test.lookupKey, err)
+			}
+			checkStoreConsistency(t, pDataCache)
+		})
+	}
+}
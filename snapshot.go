@@ -0,0 +1,212 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache
+Filename    : github.com/sameeroak1110/datacache/snapshot.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Snapshot of the cache to a writer and warm start from a snapshot.
+- A snapshot is a header followed by each record of the cache once, with its full
+key list, payload, state and expiry. Hence, records shared by multiple keys are
+serialized just once.
+- Typical warm start: LoadSnapshot() from a local file during boot-up followed by
+Reload() in a background go-routine (or WithRefreshInterval()).
+**************************************************************************** */
+package datacache
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+
+const (
+	snapshotVersion = 1
+	maxSnapshotPrealloc = 1024  // snapshot record list grows beyond it as records are decoded.
+)
+
+type snapshotHeader struct {
+	Version int
+	RecCnt int
+	CreatedAt time.Time
+}
+
+type snapshotRec struct {
+	KeyList []Key
+	PDataRec interface{}
+	IsActive bool
+	ExpiresAt time.Time   // zero if the record never expires.
+}
+
+
+/* ****************************************************************************
+Description :
+Writes snapshot of the cache to w through the codec of the cache.
+
+Receiver    :
+pDataCache *DataCache: Instance of datacache.
+
+Implements  : NA
+
+Arguments   :
+1> w io.Writer: Snapshot is written to w.
+
+Return value:
+1> error: Returns cause of error.
+
+Additional note:
+- Method takes RD store-lock and releases the same once done. Caller go-routine shouldn't invoke
+this method in any store-lock. It's a deadlock otherwise.
+- Each record is encoded whilst it's guarded in its record lock in shared mode. Therefore, writers
+of the cache are blocked until the snapshot is written. Expired records aren't written.
+- With JSONCodec, every key must be a string. Error is returned otherwise, before anything is
+written, as JSON would turn the key into float64 or string and it wouldn't match once loaded.
+**************************************************************************** */
+func (pDataCache *DataCache) SaveSnapshot(w io.Writer) error {
+	if pDataCache == nil {
+		return errors.New("Nil datacache.")
+	}
+
+	pDataCache.rlockStore()
+	defer pDataCache.runlockStore()
+
+	now := time.Now()
+	recList := make([]*Rec, 0, pDataCache.recCnt())
+	for _, pRec := range pDataCache.uniqueRecs() {
+		if !pRec.isExpired(now) {
+			if err := checkCodecKeys(pDataCache.codec, pRec.KeyList); err != nil {
+				return err
+			}
+			recList = append(recList, pRec)
+		}
+	}
+
+	pEncoder := pDataCache.codec.NewEncoder(w)
+	header := snapshotHeader {
+		Version: snapshotVersion,
+		RecCnt: len(recList),
+		CreatedAt: now,
+	}
+	if err := pEncoder.Encode(&header); err != nil {
+		return errors.New(fmt.Sprintf("Failed to write snapshot header: %s", err.Error()))
+	}
+
+	for _, pRec := range recList {
+		pRec.rlock()
+		snapRec := snapshotRec {
+			KeyList: pRec.KeyList,
+			PDataRec: pRec.PDataRec,
+			IsActive: pRec.isActive,
+			ExpiresAt: pRec.expiresAt,
+		}
+		err := pEncoder.Encode(&snapRec)
+		pRec.runlock()
+
+		if err != nil {
+			return errors.New(fmt.Sprintf("Failed to write snapshot record %#v: %s", snapRec.KeyList, err.Error()))
+		}
+	}
+
+	return nil
+}
+
+
+/* ****************************************************************************
+Description :
+Reads snapshot from r through the codec of the cache and adds each snapshot record
+in the cache.
+
+Receiver    :
+pDataCache *DataCache: Instance of datacache.
+
+Implements  : NA
+
+Arguments   :
+1> r io.Reader: Snapshot is read from r.
+
+Return value:
+1> int: Number of records added.
+2> error: Returns cause of error. Nothing is added in case of error.
+
+Additional note:
+- Snapshot is decoded without any store-lock. Method takes WR store-lock only to add the
+decoded records. Caller go-routine shouldn't invoke this method in any store-lock.
+- A snapshot record overrides the existing record for the same key, the way ForceAddRec() does.
+Records which have expired since the snapshot was written are skipped.
+- Record count of the header isn't trusted for allocation. Snapshot with negative count, or with
+fewer records than the count, is rejected.
+**************************************************************************** */
+func (pDataCache *DataCache) LoadSnapshot(r io.Reader) (int, error) {
+	var header snapshotHeader
+
+	if pDataCache == nil {
+		return -1, errors.New("Nil datacache.")
+	}
+
+	pDecoder := pDataCache.codec.NewDecoder(r)
+	if err := pDecoder.Decode(&header); err != nil {
+		return -1, errors.New(fmt.Sprintf("Failed to read snapshot header: %s", err.Error()))
+	}
+
+	if header.Version != snapshotVersion {
+		return -1, errors.New(fmt.Sprintf("Unsupported snapshot version %d.", header.Version))
+	}
+
+	if header.RecCnt < 0 {
+		return -1, errors.New(fmt.Sprintf("Invalid snapshot record count %d.", header.RecCnt))
+	}
+
+	// record count comes from the snapshot. hence, the list grows as records are actually decoded.
+	prealloc := header.RecCnt
+	if prealloc > maxSnapshotPrealloc {
+		prealloc = maxSnapshotPrealloc
+	}
+	snapRecList := make([]snapshotRec, 0, prealloc)
+	for i := 0; i < header.RecCnt; i++ {
+		snapRec := snapshotRec { PDataRec: pDataCache.NewPayload() }
+		if err := pDecoder.Decode(&snapRec); err != nil {
+			if err == io.EOF {
+				return -1, errors.New(fmt.Sprintf("Snapshot is truncated: header says %d records, found %d.",
+					header.RecCnt, i))
+			}
+			return -1, errors.New(fmt.Sprintf("Failed to read snapshot record %d: %s", i, err.Error()))
+		}
+		snapRecList = append(snapRecList, snapRec)
+	}
+
+	pDataCache.cacheLock.Lock()
+	defer pDataCache.unlockStore()
+
+	now := time.Now()
+	cnt := 0
+	for i := range snapRecList {
+		if (len(snapRecList[i].KeyList) == 0) || (snapRecList[i].PDataRec == nil) {
+			continue
+		}
+
+		ttl := NoTTL
+		if !snapRecList[i].ExpiresAt.IsZero() {
+			ttl = snapRecList[i].ExpiresAt.Sub(now)
+			if ttl <= 0 {
+				continue
+			}
+		}
+
+		pRec := pDataCache.newRec(snapRecList[i].KeyList, snapRecList[i].PDataRec, ttl)
+		pRec.isActive = snapRecList[i].IsActive
+		pDataCache.storeRecWOLock(pRec)
+		cnt = cnt + 1
+	}
+
+	return cnt, nil
+}
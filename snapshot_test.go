@@ -0,0 +1,105 @@
+package datacache
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	testList := []struct {
+		name string
+		codec Codec
+		keyList []Key
+	}{
+		{"gob, string keys", GobCodec, []Key{"a", "b"}},
+		{"gob, int keys", GobCodec, []Key{1, 2}},
+		{"JSON, string keys", JSONCodec, []Key{"a", "b"}},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			pSrcCache := Create(nil, nil, WithCodec(test.codec))
+			defer pSrcCache.Close()
+			pSrcCache.AddRec(test.keyList, "v", true)
+			pSrcCache.AddRecWithTTL([]Key{"ttl"}, "t", true, time.Hour)
+			pSrcCache.AddRecWithTTL([]Key{"gone"}, "g", true, time.Nanosecond)
+			pSrcCache.UpdateRecState("ttl", false)
+			time.Sleep(time.Millisecond)
+
+			var buf bytes.Buffer
+			if err := pSrcCache.SaveSnapshot(&buf); err != nil {
+				t.Fatalf("SaveSnapshot: %v", err)
+			}
+
+			pDstCache := Create(nil, nil, WithCodec(test.codec))
+			defer pDstCache.Close()
+			cnt, err := pDstCache.LoadSnapshot(&buf)
+			if (err != nil) || (cnt != 2) {
+				t.Fatalf("LoadSnapshot() = %d, %v; want 2 records", cnt, err)
+			}
+
+			for _, key := range test.keyList {
+				if _, pDataRec := pDstCache.GetDataRec(key); pDataRec != "v" {
+					t.Fatalf("GetDataRec(%v) = %v, want v", key, pDataRec)
+				}
+			}
+			if isOK, pRec := pDstCache.GetRec("ttl"); !isOK || pRec.IsActive() {
+				t.Fatalf("ttl record missing or active")
+			} else {
+				pRec.DataCacheRecUnlock()
+			}
+			if isOK, ttl := pDstCache.GetTTL("ttl"); !isOK || (ttl <= 0) || (ttl > time.Hour) {
+				t.Fatalf("GetTTL(ttl) = %v, %v", ttl, isOK)
+			}
+			if pDstCache.DoesKeyExist("gone") {
+				t.Fatalf("expired record has been saved")
+			}
+		})
+	}
+}
+
+
+func TestSaveSnapshotJSONKeys(t *testing.T) {
+	pDataCache := Create(nil, nil, WithCodec(JSONCodec))
+	defer pDataCache.Close()
+	pDataCache.AddRec([]Key{"a", 1}, "v", true)
+
+	var buf bytes.Buffer
+	if err := pDataCache.SaveSnapshot(&buf); err == nil {
+		t.Fatalf("SaveSnapshot() accepted an int key with JSON codec")
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("SaveSnapshot() wrote %d bytes before failing", buf.Len())
+	}
+}
+
+
+// Record count of a corrupt or hostile header mustn't be trusted.
+func TestLoadSnapshotBadCount(t *testing.T) {
+	testList := []struct {
+		name string
+		recCnt int
+		errStr string
+	}{
+		{"negative", -1, "Invalid snapshot record count"},
+		{"huge", 1 << 40, "truncated"},
+		{"more than written", 1, "truncated"},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			pDataCache := Create(nil, nil)
+			defer pDataCache.Close()
+
+			var buf bytes.Buffer
+			GobCodec.NewEncoder(&buf).Encode(&snapshotHeader { Version: snapshotVersion, RecCnt: test.recCnt })
+
+			if _, err := pDataCache.LoadSnapshot(&buf); (err == nil) || !strings.Contains(err.Error(), test.errStr) {
+				t.Fatalf("LoadSnapshot() = %v, want error containing %q", err, test.errStr)
+			}
+		})
+	}
+}
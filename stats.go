@@ -0,0 +1,294 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache
+Filename    : github.com/sameeroak1110/datacache/stats.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Cache statistics: hit/miss and mutation counters, record and key counts, load
+duration and record lock-wait time.
+- Counters are maintained through atomic operations. Hence, they don't need any
+store-lock and are cheap enough to be always on. Time spent waiting for a record
+lock is measured only when the record lock can't be taken right away.
+- Counters only ever grow. ResetStats() just moves the base Stats() reports them
+against. Hence, it doesn't disturb the metrics exporter, which reads the counters
+since the cache was created.
+**************************************************************************** */
+package datacache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+
+// Point-in-time statistics of a cache. Counters are cumulative since Since.
+type CacheStats struct {
+	Hits uint64                  // GetRec() and GetDataRec() (and WOLock counterparts) which found a live record.
+	Misses uint64                // the ones which didn't.
+	Inserts uint64               // records added.
+	Overwrites uint64            // records added over existing keys, for instance, through ForceAddRec().
+	Updates uint64               // payloads replaced in place through Update(), Upsert() and CompareAndSwap().
+	Deletes uint64               // records removed, for instance, through DeleteRec(), DeleteCache() and DeleteKey() of their last key.
+	Evictions uint64             // records evicted by the eviction policy.
+	Expirations uint64           // expired records reclaimed.
+	Records int                  // number of records in the cache.
+	Keys int                     // number of keys in the cache. Keys - Records is the number of key aliases.
+	LoadDuration time.Duration   // time taken by the last Load(), LoadAndIterate() or Reload().
+	LockWaits uint64             // number of times a record lock couldn't be taken right away.
+	LockWaitTime time.Duration   // total time spent waiting for record locks.
+	LockTimeouts uint64          // timed and Try variants (see timedlock.go) which gave up on the record lock.
+	Since time.Time              // when counters were created or last reset.
+}
+
+// counters are 64-bit words accessed atomically. they're allocated on their own to keep them aligned.
+type cacheCounters struct {
+	hits uint64
+	misses uint64
+	inserts uint64
+	overwrites uint64
+	updates uint64
+	deletes uint64
+	evictions uint64
+	expirations uint64
+	lockWaits uint64
+	lockWaitNanos int64
+	lockTimeouts uint64
+	loadNanos int64
+	createdNanos int64
+	base atomic.Value    // CacheStats holding the counters as of the last ResetStats(). counters themselves are never reset.
+}
+
+
+func newCacheCounters() *cacheCounters {
+	now := time.Now()
+	pCounters := &cacheCounters {
+		createdNanos: now.UnixNano(),
+	}
+	pCounters.base.Store(CacheStats { Since: now })
+
+	return pCounters
+}
+
+
+// Increments a counter. Counters of a private datacache instance, for instance, the one Reload() builds
+// the fresh store in, are nil and aren't maintained. Hence, each caller checks pCounters for nil.
+func (pCounters *cacheCounters) inc(pCounter *uint64, delta uint64) {
+	atomic.AddUint64(pCounter, delta)
+}
+
+
+func (pCounters *cacheCounters) hit() {
+	if pCounters != nil {
+		pCounters.inc(&pCounters.hits, 1)
+	}
+}
+
+
+func (pCounters *cacheCounters) miss() {
+	if pCounters != nil {
+		pCounters.inc(&pCounters.misses, 1)
+	}
+}
+
+
+func (pCounters *cacheCounters) insert(isOverwrite bool) {
+	if pCounters != nil {
+		pCounters.inc(&pCounters.inserts, 1)
+		if isOverwrite {
+			pCounters.inc(&pCounters.overwrites, 1)
+		}
+	}
+}
+
+
+func (pCounters *cacheCounters) update() {
+	if pCounters != nil {
+		pCounters.inc(&pCounters.updates, 1)
+	}
+}
+
+
+func (pCounters *cacheCounters) delete(cnt int) {
+	if (pCounters != nil) && (cnt > 0) {
+		pCounters.inc(&pCounters.deletes, uint64(cnt))
+	}
+}
+
+
+func (pCounters *cacheCounters) evict() {
+	if pCounters != nil {
+		pCounters.inc(&pCounters.evictions, 1)
+	}
+}
+
+
+func (pCounters *cacheCounters) expire(cnt int) {
+	if (pCounters != nil) && (cnt > 0) {
+		pCounters.inc(&pCounters.expirations, uint64(cnt))
+	}
+}
+
+
+func (pCounters *cacheCounters) load(duration time.Duration) {
+	if pCounters != nil {
+		atomic.StoreInt64(&pCounters.loadNanos, int64(duration))
+	}
+}
+
+
+func (pCounters *cacheCounters) lockWait(duration time.Duration) {
+	if pCounters != nil {
+		pCounters.inc(&pCounters.lockWaits, 1)
+		atomic.AddInt64(&pCounters.lockWaitNanos, int64(duration))
+	}
+}
+
+
+func (pCounters *cacheCounters) lockTimeout() {
+	if pCounters != nil {
+		pCounters.inc(&pCounters.lockTimeouts, 1)
+	}
+}
+
+
+// Locks the record. Time spent waiting for the record lock, if any, is accounted in stats.
+func (pDataCache *DataCache) lockRec(pRec *Rec) {
+	if pRec.tryLock() {
+		return
+	}
+
+	startTime := time.Now()
+	pRec.lock()
+	pDataCache.pCounters.lockWait(time.Since(startTime))
+}
+
+
+// Same as lockRec() but takes the record lock in shared mode.
+func (pDataCache *DataCache) rlockRec(pRec *Rec) {
+	if pRec.tryRLock() {
+		return
+	}
+
+	startTime := time.Now()
+	pRec.rlock()
+	pDataCache.pCounters.lockWait(time.Since(startTime))
+}
+
+
+// Returns counters since the cache was created. Records, Keys and LoadDuration aren't filled in.
+func (pCounters *cacheCounters) totals() CacheStats {
+	return CacheStats {
+		Hits: atomic.LoadUint64(&pCounters.hits),
+		Misses: atomic.LoadUint64(&pCounters.misses),
+		Inserts: atomic.LoadUint64(&pCounters.inserts),
+		Overwrites: atomic.LoadUint64(&pCounters.overwrites),
+		Updates: atomic.LoadUint64(&pCounters.updates),
+		Deletes: atomic.LoadUint64(&pCounters.deletes),
+		Evictions: atomic.LoadUint64(&pCounters.evictions),
+		Expirations: atomic.LoadUint64(&pCounters.expirations),
+		LockWaits: atomic.LoadUint64(&pCounters.lockWaits),
+		LockWaitTime: time.Duration(atomic.LoadInt64(&pCounters.lockWaitNanos)),
+		LockTimeouts: atomic.LoadUint64(&pCounters.lockTimeouts),
+		Since: time.Unix(0, pCounters.createdNanos),
+	}
+}
+
+
+// Returns counters of stats less those of base. Since is taken from base.
+func (stats CacheStats) sub(base CacheStats) CacheStats {
+	stats.Hits -= base.Hits
+	stats.Misses -= base.Misses
+	stats.Inserts -= base.Inserts
+	stats.Overwrites -= base.Overwrites
+	stats.Updates -= base.Updates
+	stats.Deletes -= base.Deletes
+	stats.Evictions -= base.Evictions
+	stats.Expirations -= base.Expirations
+	stats.LockWaits -= base.LockWaits
+	stats.LockWaitTime -= base.LockWaitTime
+	stats.LockTimeouts -= base.LockTimeouts
+	stats.Since = base.Since
+
+	return stats
+}
+
+
+/* ****************************************************************************
+Description :
+Returns statistics of the cache.
+
+Receiver    :
+pDataCache *DataCache: Instance of datacache.
+
+Implements  : NA
+
+Arguments   : NA
+
+Return value:
+1> CacheStats: Statistics of the cache.
+
+Additional note:
+- Method takes RD store-lock just to count records and keys. Caller go-routine shouldn't
+invoke this method in WR store-lock. It's a deadlock otherwise.
+- Counters are read one after the other. Hence, they're not a consistent snapshot of a
+single instant under concurrent load.
+**************************************************************************** */
+func (pDataCache *DataCache) Stats() CacheStats {
+	return pDataCache.stats(true)
+}
+
+
+// Returns stats with counters since the last ResetStats() if isSinceReset is true, since the cache was created otherwise.
+func (pDataCache *DataCache) stats(isSinceReset bool) CacheStats {
+	if (pDataCache == nil) || (pDataCache.pCounters == nil) {
+		return CacheStats{}
+	}
+
+	pCounters := pDataCache.pCounters
+	base := pCounters.base.Load().(CacheStats)  // loaded before the totals, so that no total is behind its base.
+	stats := pCounters.totals()
+	if isSinceReset {
+		stats = stats.sub(base)
+	}
+	stats.LoadDuration = time.Duration(atomic.LoadInt64(&pCounters.loadNanos))
+
+	pDataCache.rlockStore()
+	stats.Records = pDataCache.recCnt()
+	stats.Keys = pDataCache.keyCntWOLock()
+	pDataCache.runlockStore()
+
+	return stats
+}
+
+
+// Resets counters reported by Stats() to zero. Record and key counts and load duration aren't affected.
+// Counters exported by MetricsHandler aren't affected either, so that they never go backwards.
+func (pDataCache *DataCache) ResetStats() {
+	if (pDataCache == nil) || (pDataCache.pCounters == nil) {
+		return
+	}
+
+	base := pDataCache.pCounters.totals()
+	base.Since = time.Now()
+	pDataCache.pCounters.base.Store(base)
+}
+
+
+// Returns hits / (hits + misses). 0 if there's been no fetch.
+func (stats CacheStats) HitRatio() float64 {
+	total := stats.Hits + stats.Misses
+	if total == 0 {
+		return 0
+	}
+
+	return float64(stats.Hits) / float64(total)
+}
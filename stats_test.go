@@ -0,0 +1,96 @@
+package datacache
+
+import (
+	"testing"
+	"time"
+)
+
+
+func TestStats(t *testing.T) {
+	pDataCache := Create(nil, nil, WithMaxRecs(2))
+	defer pDataCache.Close()
+
+	pDataCache.AddRec([]Key{"a", "a2"}, "a", true)
+	pDataCache.ForceAddRec([]Key{"a"}, "a'")  // overwrite. a2 still refers to the old record.
+	pDataCache.AddRec([]Key{"b", "b2"}, "b", true)  // evicts the old record.
+	pDataCache.AddRec([]Key{"c"}, "c", true)  // evicts a'.
+	pDataCache.GetDataRec("c")
+	pDataCache.GetDataRec("c")
+	pDataCache.GetDataRec("missing")
+	pDataCache.Update("c", func(interface{}) (interface{}, error) { return "c'", nil })
+	pDataCache.DeleteRec("c")
+	pDataCache.AddRecWithTTL([]Key{"d"}, "d", true, time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	pDataCache.PurgeExpired()
+
+	stats := pDataCache.Stats()
+	testList := []struct {
+		name string
+		got uint64
+		want uint64
+	}{
+		{"Hits", stats.Hits, 2},
+		{"Misses", stats.Misses, 1},
+		{"Inserts", stats.Inserts, 5},
+		{"Overwrites", stats.Overwrites, 1},
+		{"Updates", stats.Updates, 1},
+		{"Deletes", stats.Deletes, 1},
+		{"Evictions", stats.Evictions, 2},
+		{"Expirations", stats.Expirations, 1},
+		{"Records", uint64(stats.Records), 1},
+		{"Keys", uint64(stats.Keys), 2},
+	}
+	for _, test := range testList {
+		if test.got != test.want {
+			t.Errorf("%s = %d, want %d", test.name, test.got, test.want)
+		}
+	}
+	if ratio := stats.HitRatio(); ratio < 0.66 || ratio > 0.67 {
+		t.Errorf("HitRatio() = %f, want 2/3", ratio)
+	}
+
+	pDataCache.ResetStats()
+	stats = pDataCache.Stats()
+	if (stats.Hits != 0) || (stats.Inserts != 0) || (stats.Records != 1) {
+		t.Fatalf("Stats() after ResetStats() = %+v", stats)
+	}
+}
+
+
+// DeleteKey() counts a delete once it removes the last key of a record.
+func TestStatsDeleteKey(t *testing.T) {
+	pDataCache := Create(nil, nil)
+	defer pDataCache.Close()
+	pDataCache.AddRec([]Key{"a", "a2"}, "a", true)
+
+	pDataCache.DeleteKey("a2")
+	if stats := pDataCache.Stats(); stats.Deletes != 0 {
+		t.Fatalf("Deletes = %d after deleting an alias, want 0", stats.Deletes)
+	}
+
+	pDataCache.DeleteKey("a")
+	if stats := pDataCache.Stats(); (stats.Deletes != 1) || (stats.Records != 0) {
+		t.Fatalf("Deletes = %d, Records = %d after deleting the last key; want 1, 0", stats.Deletes, stats.Records)
+	}
+}
+
+
+func TestStatsLockWait(t *testing.T) {
+	pDataCache := Create(nil, nil)
+	defer pDataCache.Close()
+	pDataCache.AddRec([]Key{"a"}, "a", true)
+
+	_, pRec := pDataCache.GetRec("a")
+	doneChan := make(chan struct{})
+	go func() {
+		defer close(doneChan)
+		pDataCache.GetDataRec("a")
+	}()
+	time.Sleep(20 * time.Millisecond)
+	pRec.DataCacheRecUnlock()
+	<-doneChan
+
+	if stats := pDataCache.Stats(); (stats.LockWaits != 1) || (stats.LockWaitTime < 10 * time.Millisecond) {
+		t.Fatalf("LockWaits = %d, LockWaitTime = %v; want 1, >= 10ms", stats.LockWaits, stats.LockWaitTime)
+	}
+}
@@ -0,0 +1,398 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache
+Filename    : github.com/sameeroak1110/datacache/timedlock.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Context-aware and timed variants of the methods which take the record lock, i.e., GetRec(),
+DeleteRec() and UpdateRecState().
+- Plain variants wait on the record lock for as long as it takes. GetRec() waits without
+holding the store-lock, whereas DeleteRec() and UpdateRecState() wait whilst holding the
+store-lock of the key. Either way, a record lock which is never released (a missing
+RecUnlock()) hangs the caller.
+- Variants herein bound the wait: they poll the record lock through TryLock(), release the
+store-lock between the attempts and give up with *LockTimeoutError once the context is done.
+**************************************************************************** */
+package datacache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+
+const (
+	minLockPollInterval = 50 * time.Microsecond
+	maxLockPollInterval = 5 * time.Millisecond
+)
+
+// Matches every *LockTimeoutError through errors.Is().
+var ErrLockTimeout = errors.New("Record lock couldn't be acquired in time.")
+
+// LockTimeoutError is returned when the record lock couldn't be acquired before the context was done.
+type LockTimeoutError struct {
+	Key Key                  // key of the record.
+	Waited time.Duration     // time spent waiting for the record lock.
+	Cause error              // ctx.Err(). nil for Try variants which don't wait.
+}
+
+
+func (pErr *LockTimeoutError) Error() string {
+	if pErr.Cause == nil {
+		return fmt.Sprintf("Record lock of key %#v is held by some other go-routine.", pErr.Key)
+	}
+
+	return fmt.Sprintf("Record lock of key %#v couldn't be acquired in %s: %s", pErr.Key, pErr.Waited, pErr.Cause.Error())
+}
+
+
+func (pErr *LockTimeoutError) Is(target error) bool {
+	return target == ErrLockTimeout
+}
+
+
+func (pErr *LockTimeoutError) Unwrap() error {
+	return pErr.Cause
+}
+
+
+/* ****************************************************************************
+Description :
+Polls the record lock of the record referred to by key until it's acquired or ctx is done.
+fn is invoked on the record once its record lock is acquired.
+
+Receiver    :
+pDataCache *DataCache: Instance of datacache.
+
+Implements  : NA
+
+Arguments   :
+1> ctx context.Context: Gives up once ctx is done. nil means a single attempt.
+2> key Key: Key to the cache record.
+3> isWR bool: true if WR store-lock is to be taken, RD store-lock otherwise.
+4> fn func(*Rec): Invoked whilst the store-lock and the record lock are held. It's fn's
+responsibility to unlock the record, if need be.
+
+Return value:
+1> bool: true if fn is invoked. false if record isn't found, has expired or in case of error.
+2> error: *LockTimeoutError in case the record lock couldn't be acquired.
+
+Additional note:
+- Store-lock is released between the attempts. Caller go-routine shouldn't invoke this method
+in any store-lock. It's a deadlock otherwise.
+**************************************************************************** */
+func (pDataCache *DataCache) pollRecLock(ctx context.Context, key Key, isWR bool, fn func(*Rec)) (bool, error) {
+	var pTimer *time.Timer
+
+	var storeUnlock func()
+	storeLock := func() {
+		pDataCache.rlockKey(key)
+		storeUnlock = func() {
+			pDataCache.runlockKey(key)
+		}
+	}
+	if isWR {
+		storeLock = func() {
+			storeUnlock = pDataCache.lockKeys([]Key{key})
+		}
+	}
+
+	startTime := time.Now()
+	interval := minLockPollInterval
+	for attempt := 0; ; attempt++ {
+		storeLock()
+		pRec, isOK := pDataCache.lookupWOLock(key)
+		if !isOK || pRec.isExpired(time.Now()) {
+			storeUnlock()
+			return false, nil
+		}
+
+		if pRec.tryLock() {
+			fn(pRec)
+			storeUnlock()
+
+			if attempt > 0 {
+				pDataCache.pCounters.lockWait(time.Since(startTime))
+			}
+			return true, nil
+		}
+		storeUnlock()
+
+		if ctx == nil {
+			pDataCache.pCounters.lockTimeout()
+			return false, &LockTimeoutError { Key: key }
+		}
+
+		if pTimer == nil {
+			pTimer = time.NewTimer(interval)
+			defer pTimer.Stop()
+		} else {
+			pTimer.Reset(interval)
+		}
+
+		select {
+			case <-ctx.Done():
+				waited := time.Since(startTime)
+				pDataCache.pCounters.lockWait(waited)
+				pDataCache.pCounters.lockTimeout()
+				return false, &LockTimeoutError { Key: key, Waited: waited, Cause: ctx.Err() }
+
+			case <-pTimer.C:
+		}
+
+		if interval = interval * 2; interval > maxLockPollInterval {
+			interval = maxLockPollInterval
+		}
+	}
+}
+
+
+/* ****************************************************************************
+Description :
+Context-aware equivalent of GetRec(). Returns a locked datacache record. It's caller's
+responsibility to unlock the same once done with it.
+
+Receiver    :
+pDataCache *DataCache: Instance of datacache.
+
+Implements  : NA
+
+Arguments   :
+1> ctx context.Context: Method gives up waiting for the record lock once ctx is done.
+2> key Key: Key to the cache record.
+
+Return value:
+1> bool: true if successful. false if failed.
+2> *Rec: Found datacache record in the locked state. nil if cache record isn't found, has
+expired or the record lock couldn't be acquired.
+3> error: *LockTimeoutError (matches ErrLockTimeout) in case ctx is done before the record lock
+is acquired. nil otherwise, even if the record isn't found.
+
+Additional note:
+- Method takes RD store-lock. Caller go-routine shouldn't invoke this method in any store-lock.
+It's a deadlock otherwise. Unlike GetRec(), store-lock isn't held whilst waiting for the record lock.
+- Giving up on the record lock is counted in LockTimeouts of Stats() rather than as a miss.
+**************************************************************************** */
+func (pDataCache *DataCache) GetRecContext(ctx context.Context, key Key) (bool, *Rec, error) {
+	if pDataCache == nil {
+		return false, nil, errors.New("Nil datacache.")
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return pDataCache.getRec(ctx, key)
+}
+
+
+// Same as GetRecContext(). Gives up once d has elapsed.
+func (pDataCache *DataCache) GetRecTimeout(key Key, d time.Duration) (bool, *Rec, error) {
+	if pDataCache == nil {
+		return false, nil, errors.New("Nil datacache.")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+
+	return pDataCache.getRec(ctx, key)
+}
+
+
+// Same as GetRecContext(). Doesn't wait at all in case the record lock is held by some other go-routine.
+func (pDataCache *DataCache) TryGetRec(key Key) (bool, *Rec, error) {
+	if pDataCache == nil {
+		return false, nil, errors.New("Nil datacache.")
+	}
+
+	return pDataCache.getRec(nil, key)
+}
+
+
+func (pDataCache *DataCache) getRec(ctx context.Context, key Key) (bool, *Rec, error) {
+	var pLockedRec *Rec
+
+	isOK, err := pDataCache.pollRecLock(ctx, key, false, func(pRec *Rec) {
+		pLockedRec = pRec  // record remains locked.
+		if pDataCache.policy != nil {
+			pDataCache.policy.OnHit(pRec)
+		}
+	})
+
+	if err != nil {  // counted as lock timeout, not as a miss. the record may well be there.
+		return false, nil, err
+	}
+
+	if !isOK {
+		pDataCache.pCounters.miss()
+		return false, nil, nil
+	}
+
+	pDataCache.pCounters.hit()
+	return true, pLockedRec, nil
+}
+
+
+/* ****************************************************************************
+Description :
+Context-aware equivalent of DeleteRec(). Deletes the record referred to by key. All its
+keys are disassociated.
+
+Receiver    :
+pDataCache *DataCache: Datacache instance.
+
+Implements  : NA
+
+Arguments   :
+1> ctx context.Context: Method gives up waiting for the record lock once ctx is done.
+2> key Key: key of cache record to be removed from cache.
+
+Return value:
+1> int: Number of records remained in the cache. -1 in case of error.
+2> error: *LockTimeoutError (matches ErrLockTimeout) in case ctx is done before the record lock
+is acquired. Error in case key doesn't exist.
+
+Additional note:
+- Method takes WR store-lock. Caller go-routine shouldn't invoke this method in any store-lock.
+Unlike DeleteRec(), store-lock isn't held whilst waiting for the record lock.
+**************************************************************************** */
+func (pDataCache *DataCache) DeleteRecContext(ctx context.Context, key Key) (int, error) {
+	if pDataCache == nil {
+		return -1, errors.New("Nil datacache")
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return pDataCache.deleteRec(ctx, key)
+}
+
+
+// Same as DeleteRecContext(). Gives up once d has elapsed.
+func (pDataCache *DataCache) DeleteRecTimeout(key Key, d time.Duration) (int, error) {
+	if pDataCache == nil {
+		return -1, errors.New("Nil datacache")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+
+	return pDataCache.deleteRec(ctx, key)
+}
+
+
+// Same as DeleteRecContext(). Doesn't wait at all in case the record lock is held by some other go-routine.
+func (pDataCache *DataCache) TryDeleteRec(key Key) (int, error) {
+	if pDataCache == nil {
+		return -1, errors.New("Nil datacache")
+	}
+
+	return pDataCache.deleteRec(nil, key)
+}
+
+
+func (pDataCache *DataCache) deleteRec(ctx context.Context, key Key) (int, error) {
+	cnt := -1
+
+	isOK, err := pDataCache.pollRecLock(ctx, key, true, func(pRec *Rec) {
+		pDataCache.removeRecWOLock(pRec, EventDelete)  // all keys disassociated.
+		pDataCache.pCounters.delete(1)
+		pRec.unlock()
+		cnt = pDataCache.recCnt()
+	})
+
+	if err != nil {
+		return -1, err
+	}
+
+	if !isOK {
+		return -1, errors.New("Key doesn't exist.")
+	}
+
+	return cnt, nil
+}
+
+
+/* ****************************************************************************
+Description :
+Context-aware equivalent of UpdateRecState(). Updates state of the datacache record to
+active or inactive.
+
+Receiver    :
+pDataCache *DataCache: DataCache instance.
+
+Implements  : NA
+
+Arguments   :
+1> ctx context.Context: Method gives up waiting for the record lock once ctx is done.
+2> key Key: Key to the cache record.
+3> recState bool: true to mark state active, false to make it inactive.
+
+Return value:
+1> bool: true if state is updated. false if record isn't found or in case of error.
+2> error: *LockTimeoutError (matches ErrLockTimeout) in case ctx is done before the record lock
+is acquired. nil otherwise.
+
+Additional note:
+- Method takes RD store-lock. Caller go-routine shouldn't invoke this method in any store-lock.
+Unlike UpdateRecState(), store-lock isn't held whilst waiting for the record lock.
+**************************************************************************** */
+func (pDataCache *DataCache) UpdateRecStateContext(ctx context.Context, key Key, recState bool) (bool, error) {
+	if pDataCache == nil {
+		return false, errors.New("Nil datacache.")
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return pDataCache.updateRecState(ctx, key, recState)
+}
+
+
+// Same as UpdateRecStateContext(). Gives up once d has elapsed.
+func (pDataCache *DataCache) UpdateRecStateTimeout(key Key, recState bool, d time.Duration) (bool, error) {
+	if pDataCache == nil {
+		return false, errors.New("Nil datacache.")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+
+	return pDataCache.updateRecState(ctx, key, recState)
+}
+
+
+// Same as UpdateRecStateContext(). Doesn't wait at all in case the record lock is held by some other go-routine.
+func (pDataCache *DataCache) TryUpdateRecState(key Key, recState bool) (bool, error) {
+	if pDataCache == nil {
+		return false, errors.New("Nil datacache.")
+	}
+
+	return pDataCache.updateRecState(nil, key, recState)
+}
+
+
+func (pDataCache *DataCache) updateRecState(ctx context.Context, key Key, recState bool) (bool, error) {
+	defer pDataCache.dispatchEvents()  // RD store-lock is released by then.
+
+	return pDataCache.pollRecLock(ctx, key, false, func(pRec *Rec) {
+		pRec.isActive = recState
+		pDataCache.pWAL.logState(key, recState)
+		pDataCache.raiseEvent(EventStateChange, pRec.KeyList, pRec.PDataRec, pRec.PDataRec, recState)
+		pDataCache.raiseHook(hookStateChange, EventStateChange, pRec.KeyList, pRec.PDataRec, pRec.PDataRec, recState)
+		pRec.unlock()
+	})
+}
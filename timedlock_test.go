@@ -0,0 +1,113 @@
+package datacache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+
+func TestTimedLock(t *testing.T) {
+	testList := []struct {
+		name string
+		call func(*DataCache, Key) (bool, error)
+		isMissingErr bool    // error in case key doesn't exist.
+	}{
+		{"TryGetRec", func(pDataCache *DataCache, key Key) (bool, error) {
+			isOK, pRec, err := pDataCache.TryGetRec(key)
+			if isOK {
+				pRec.DataCacheRecUnlock()
+			}
+			return isOK, err
+		}, false},
+		{"GetRecTimeout", func(pDataCache *DataCache, key Key) (bool, error) {
+			isOK, pRec, err := pDataCache.GetRecTimeout(key, 20 * time.Millisecond)
+			if isOK {
+				pRec.DataCacheRecUnlock()
+			}
+			return isOK, err
+		}, false},
+		{"UpdateRecStateTimeout", func(pDataCache *DataCache, key Key) (bool, error) {
+			return pDataCache.UpdateRecStateTimeout(key, false, 20 * time.Millisecond)
+		}, false},
+		{"TryUpdateRecState", func(pDataCache *DataCache, key Key) (bool, error) {
+			return pDataCache.TryUpdateRecState(key, false)
+		}, false},
+		{"DeleteRecTimeout", func(pDataCache *DataCache, key Key) (bool, error) {
+			_, err := pDataCache.DeleteRecTimeout(key, 20 * time.Millisecond)
+			return err == nil, err
+		}, true},
+		{"TryDeleteRec", func(pDataCache *DataCache, key Key) (bool, error) {
+			_, err := pDataCache.TryDeleteRec(key)
+			return err == nil, err
+		}, true},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			pDataCache := Create(nil, nil, WithShards(8))
+			defer pDataCache.Close()
+			pDataCache.AddRec([]Key{"k"}, "v", true)
+
+			// missing key.
+			if isOK, err := test.call(pDataCache, "nope"); isOK || ((err != nil) != test.isMissingErr) || errors.Is(err, ErrLockTimeout) {
+				t.Fatalf("missing key: %v, %v", isOK, err)
+			}
+
+			// record locked by some other holder.
+			_, pRec := pDataCache.GetRec("k")
+			stats := pDataCache.Stats()
+			runWithTimeout(t, 5 * time.Second, func() {
+				isOK, err := test.call(pDataCache, "k")
+				var pErr *LockTimeoutError
+				if isOK || !errors.Is(err, ErrLockTimeout) || !errors.As(err, &pErr) || (pErr.Key != "k") {
+					t.Errorf("locked record: %v, %v; want ErrLockTimeout", isOK, err)
+				}
+			})
+			pRec.DataCacheRecUnlock()
+
+			// timeout isn't a miss.
+			tmpStats := pDataCache.Stats()
+			if (tmpStats.LockTimeouts != stats.LockTimeouts + 1) || (tmpStats.Misses != stats.Misses) {
+				t.Fatalf("lock timeouts %d, misses %d; want %d, %d", tmpStats.LockTimeouts, tmpStats.Misses,
+					stats.LockTimeouts + 1, stats.Misses)
+			}
+
+			// unlocked record.
+			if isOK, err := test.call(pDataCache, "k"); !isOK || (err != nil) {
+				t.Fatalf("unlocked record: %v, %v", isOK, err)
+			}
+		})
+	}
+}
+
+
+// Record lock released whilst GetRecContext() waits is acquired; store-lock isn't held meanwhile.
+func TestGetRecContextWaits(t *testing.T) {
+	pDataCache := Create(nil, nil)
+	defer pDataCache.Close()
+	pDataCache.AddRec([]Key{"k"}, "v", true)
+
+	_, pRec := pDataCache.GetRec("k")
+	resultChan := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
+		defer cancel()
+		isOK, pRec, err := pDataCache.GetRecContext(ctx, "k")
+		if isOK {
+			pRec.DataCacheRecUnlock()
+		}
+		resultChan <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	runWithTimeout(t, time.Second, func() {
+		pDataCache.AddRec([]Key{"other"}, "v", true)  // needs the WR store-lock.
+	})
+	pRec.DataCacheRecUnlock()
+
+	if err := <-resultChan; err != nil {
+		t.Fatalf("GetRecContext: %v", err)
+	}
+}
@@ -0,0 +1,283 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache
+Filename    : github.com/sameeroak1110/datacache/tinylfu.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Window TinyLFU (W-TinyLFU) eviction policy.
+- Newly added records enter a small LRU window (1% of capacity). Main region is a
+segmented LRU: probation and protected (80% of main region). A record hit whilst
+in probation is promoted to protected.
+- Records overflowing the window move into probation as long as the main region has room.
+Once the main region is full, least-recently-used record of the window (the candidate) competes
+against the probation victim. Access frequency of both is estimated through a
+count-min sketch and the less frequent one is evicted. The sketch is halved
+periodically so that old popularity fades away.
+- Frequency is tracked against the first key of a record at the time it's added.
+**************************************************************************** */
+package datacache
+
+import (
+	"container/list"
+	"sync"
+)
+
+
+const (
+	tlfuWindow = iota
+	tlfuProbation
+	tlfuProtected
+)
+
+const cmSketchDepth = 4
+
+var cmSketchSeeds = [cmSketchDepth]uint64{0x9e3779b97f4a7c15, 0xbf58476d1ce4e5b9, 0x94d049bb133111eb, 0xd6e8feb86659fd93}
+
+
+// count-min sketch with 4-bit-like saturating counters.
+type cmSketch struct {
+	rows [cmSketchDepth][]uint8
+	mask uint64
+	additions int
+	resetAt int      // counters are halved once these many additions have been made.
+}
+
+
+func newCMSketch(capacity int) *cmSketch {
+	width := 16
+	for width < capacity {
+		width = width << 1
+	}
+
+	pSketch := &cmSketch {
+		mask: uint64(width - 1),
+		resetAt: 10 * width,
+	}
+	for i := range pSketch.rows {
+		pSketch.rows[i] = make([]uint8, width)
+	}
+
+	return pSketch
+}
+
+
+func (pSketch *cmSketch) index(hash uint64, i int) uint64 {
+	tmpHash := (hash ^ cmSketchSeeds[i]) * 0x2545f4914f6cdd1d
+	return (tmpHash ^ (tmpHash >> 32)) & pSketch.mask
+}
+
+
+func (pSketch *cmSketch) increment(hash uint64) {
+	for i := range pSketch.rows {
+		idx := pSketch.index(hash, i)
+		if pSketch.rows[i][idx] < 15 {
+			pSketch.rows[i][idx]++
+		}
+	}
+
+	pSketch.additions++
+	if pSketch.additions >= pSketch.resetAt {
+		for i := range pSketch.rows {
+			for j := range pSketch.rows[i] {
+				pSketch.rows[i][j] = pSketch.rows[i][j] >> 1
+			}
+		}
+		pSketch.additions = pSketch.additions / 2
+	}
+}
+
+
+func (pSketch *cmSketch) estimate(hash uint64) uint8 {
+	var freq uint8 = 15
+
+	for i := range pSketch.rows {
+		if tmpFreq := pSketch.rows[i][pSketch.index(hash, i)]; tmpFreq < freq {
+			freq = tmpFreq
+		}
+	}
+
+	return freq
+}
+
+
+type tlfuEntry struct {
+	pElem *list.Element
+	segment int
+	hash uint64
+}
+
+type tinyLFUPolicy struct {
+	lock sync.Mutex
+	capacity int
+	windowCap int
+	protectedCap int
+	pSketch *cmSketch
+	lists [3]*list.List        // window, probation and protected. front is the most recent one.
+	entryMap map[*Rec]*tlfuEntry
+}
+
+
+// Returns W-TinyLFU eviction policy. capacity is the number of records the cache holds,
+// typically the same as WithMaxRecs().
+func NewTinyLFUPolicy(capacity int) EvictionPolicy {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	windowCap := capacity / 100
+	if windowCap < 1 {
+		windowCap = 1
+	}
+	protectedCap := (capacity - windowCap) * 80 / 100
+	if protectedCap < 1 {
+		protectedCap = 1
+	}
+
+	pPolicy := &tinyLFUPolicy {
+		capacity: capacity,
+		windowCap: windowCap,
+		protectedCap: protectedCap,
+		pSketch: newCMSketch(capacity),
+		entryMap: make(map[*Rec]*tlfuEntry),
+	}
+	for i := range pPolicy.lists {
+		pPolicy.lists[i] = list.New()
+	}
+
+	return pPolicy
+}
+
+
+// Moves the record at the front of the given segment.
+func (pPolicy *tinyLFUPolicy) moveTo(pRec *Rec, pEntry *tlfuEntry, segment int) {
+	pPolicy.lists[pEntry.segment].Remove(pEntry.pElem)
+	pEntry.pElem = pPolicy.lists[segment].PushFront(pRec)
+	pEntry.segment = segment
+}
+
+
+func (pPolicy *tinyLFUPolicy) OnInsert(pRec *Rec) {
+	pPolicy.lock.Lock()
+	defer pPolicy.lock.Unlock()
+
+	if _, isOK := pPolicy.entryMap[pRec]; isOK {
+		return
+	}
+
+	pEntry := &tlfuEntry {
+		segment: tlfuWindow,
+	}
+	if len(pRec.KeyList) > 0 {
+		pEntry.hash = hashKey(pRec.KeyList[0])
+	}
+	pPolicy.pSketch.increment(pEntry.hash)
+	pEntry.pElem = pPolicy.lists[tlfuWindow].PushFront(pRec)
+	pPolicy.entryMap[pRec] = pEntry
+	pPolicy.fillMain()
+}
+
+
+// Moves records overflowing the window into probation for as long as main region isn't full. There's nothing to
+// evict from the main region till then; hence, the candidate is admitted without competing on frequency.
+// Must be invoked in the policy lock.
+func (pPolicy *tinyLFUPolicy) fillMain() {
+	pWindow := pPolicy.lists[tlfuWindow]
+	mainCap := pPolicy.capacity - pPolicy.windowCap
+	for (pWindow.Len() > pPolicy.windowCap) &&
+	((pPolicy.lists[tlfuProbation].Len() + pPolicy.lists[tlfuProtected].Len()) < mainCap) {
+		pCandidate := pWindow.Back().Value.(*Rec)
+		pPolicy.moveTo(pCandidate, pPolicy.entryMap[pCandidate], tlfuProbation)
+	}
+}
+
+
+func (pPolicy *tinyLFUPolicy) OnHit(pRec *Rec) {
+	pPolicy.lock.Lock()
+	defer pPolicy.lock.Unlock()
+
+	pEntry, isOK := pPolicy.entryMap[pRec]
+	if !isOK {
+		return
+	}
+	pPolicy.pSketch.increment(pEntry.hash)
+
+	switch pEntry.segment {
+	case tlfuWindow, tlfuProtected:
+		pPolicy.lists[pEntry.segment].MoveToFront(pEntry.pElem)
+
+	case tlfuProbation:
+		pPolicy.moveTo(pRec, pEntry, tlfuProtected)
+		if pPolicy.lists[tlfuProtected].Len() > pPolicy.protectedCap {  // demote least-recently-used protected record.
+			pDemoted := pPolicy.lists[tlfuProtected].Back().Value.(*Rec)
+			pPolicy.moveTo(pDemoted, pPolicy.entryMap[pDemoted], tlfuProbation)
+		}
+	}
+}
+
+
+func (pPolicy *tinyLFUPolicy) OnDelete(pRec *Rec) {
+	pPolicy.lock.Lock()
+	defer pPolicy.lock.Unlock()
+
+	if pEntry, isOK := pPolicy.entryMap[pRec]; isOK {
+		pPolicy.lists[pEntry.segment].Remove(pEntry.pElem)
+		delete(pPolicy.entryMap, pRec)
+	}
+}
+
+
+func (pPolicy *tinyLFUPolicy) Victim(isEvictable func(*Rec) bool) *Rec {
+	pPolicy.lock.Lock()
+	defer pPolicy.lock.Unlock()
+
+	pPolicy.fillMain()
+	pWindow := pPolicy.lists[tlfuWindow]
+	if pWindow.Len() > pPolicy.windowCap {  // window candidate competes against main region victim.
+		pCandidate := pWindow.Back().Value.(*Rec)
+		pMainElem := pPolicy.lists[tlfuProbation].Back()
+		if pMainElem == nil {
+			pMainElem = pPolicy.lists[tlfuProtected].Back()
+		}
+
+		if pMainElem != nil {
+			pMainVictim := pMainElem.Value.(*Rec)
+			if pPolicy.pSketch.estimate(pPolicy.entryMap[pCandidate].hash) > pPolicy.pSketch.estimate(pPolicy.entryMap[pMainVictim].hash) {
+				pPolicy.moveTo(pCandidate, pPolicy.entryMap[pCandidate], tlfuProbation)  // candidate is admitted.
+				if isEvictable(pMainVictim) {
+					return pMainVictim
+				}
+			} else if isEvictable(pCandidate) {
+				return pCandidate
+			}
+		}
+	}
+
+	for _, segment := range []int{tlfuWindow, tlfuProbation, tlfuProtected} {
+		if pRec := victimFromBack(pPolicy.lists[segment], isEvictable); pRec != nil {
+			return pRec
+		}
+	}
+
+	return nil
+}
+
+
+func (pPolicy *tinyLFUPolicy) Reset() {
+	pPolicy.lock.Lock()
+	defer pPolicy.lock.Unlock()
+
+	for i := range pPolicy.lists {
+		pPolicy.lists[i].Init()
+	}
+	pPolicy.entryMap = make(map[*Rec]*tlfuEntry)
+	pPolicy.pSketch = newCMSketch(pPolicy.capacity)
+}
@@ -0,0 +1,66 @@
+package datacache
+
+import (
+	"fmt"
+	"testing"
+)
+
+
+// Records overflowing the window fill the main region before anything competes on frequency.
+func TestTinyLFUFillsMain(t *testing.T) {
+	const capacity = 100
+	pPolicy := NewTinyLFUPolicy(capacity).(*tinyLFUPolicy)
+	pDataCache := Create(nil, nil, WithMaxRecs(capacity), WithEvictionPolicy(pPolicy))
+	defer pDataCache.Close()
+
+	for i := 0; i < capacity; i++ {
+		pDataCache.AddRec([]Key{i}, i, true)
+	}
+
+	windowLen := pPolicy.lists[tlfuWindow].Len()
+	mainLen := pPolicy.lists[tlfuProbation].Len() + pPolicy.lists[tlfuProtected].Len()
+	if (windowLen != pPolicy.windowCap) || (mainLen != capacity - pPolicy.windowCap) {
+		t.Fatalf("window %d, main %d; want %d, %d", windowLen, mainLen, pPolicy.windowCap, capacity - pPolicy.windowCap)
+	}
+}
+
+
+// Frequently hit record survives a scan of keys which are never hit again. Plain LRU evicts it.
+func TestTinyLFUScanResistance(t *testing.T) {
+	const capacity = 100
+
+	testList := []struct {
+		name string
+		policy EvictionPolicy
+		isSurvivor bool
+	}{
+		{"W-TinyLFU", NewTinyLFUPolicy(capacity), true},
+		{"LRU", NewLRUPolicy(), false},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			pDataCache := Create(nil, nil, WithMaxRecs(capacity), WithEvictionPolicy(test.policy))
+			defer pDataCache.Close()
+
+			pDataCache.AddRec([]Key{"hot"}, "hot", true)
+			for i := 0; i < capacity; i++ {
+				pDataCache.AddRec([]Key{fmt.Sprintf("warm-%d", i)}, i, true)
+				if i % 10 == 0 {
+					pDataCache.GetDataRec("hot")
+				}
+			}
+
+			for i := 0; i < 10 * capacity; i++ {
+				pDataCache.AddRec([]Key{fmt.Sprintf("scan-%d", i)}, i, true)
+			}
+
+			if _, cnt := pDataCache.GetCnt(); cnt > capacity {
+				t.Fatalf("%d records, capacity %d", cnt, capacity)
+			}
+			if isSurvivor := pDataCache.DoesKeyExist("hot"); isSurvivor != test.isSurvivor {
+				t.Fatalf("hot key survived: %v, want %v", isSurvivor, test.isSurvivor)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache
+Filename    : github.com/sameeroak1110/datacache/ttl.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Per-record TTL and reclamation of expired records.
+- An expired record is invisible to fetch operations right away. However, it's
+physically removed from the cache store only by the janitor go-routine (or by
+PurgeExpired()), which takes WR store-lock and the record lock just the way
+DeleteRec() does.
+**************************************************************************** */
+package datacache
+
+import (
+	"fmt"
+	"time"
+)
+
+
+// TTL value meaning the record never expires, irrespective of cache-wide default TTL.
+const NoTTL time.Duration = -1
+
+
+// Returns true if the record has expired by now. Record without TTL never expires.
+func (pRec *Rec) isExpired(now time.Time) bool {
+	return !pRec.expiresAt.IsZero() && !now.Before(pRec.expiresAt)
+}
+
+
+// Starts the janitor go-routine. It's started only once per datacache instance.
+func (pDataCache *DataCache) startJanitor() {
+	pDataCache.janitorOnce.Do(func() {
+		go pDataCache.janitor()
+	})
+}
+
+
+// Janitor go-routine. Periodically reclaims expired records until Close() is invoked.
+func (pDataCache *DataCache) janitor() {
+	ticker := time.NewTicker(pDataCache.janitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-pDataCache.pStopChan:
+			return
+
+		case <-ticker.C:
+			pDataCache.PurgeExpired()
+		}
+	}
+}
+
+
+/* *****************************************************************************
+Description :
+Changes TTL of the record referred to by key. The record expires once ttl has elapsed
+from now.
+
+Receiver    :
+pDataCache *DataCache: Datacache instance.
+
+Implements  : NA
+
+Arguments   :
+1> key Key: Key to the cache record.
+2> ttl time.Duration: New TTL. 0 means cache-wide default TTL. NoTTL means the record never expires.
+
+Return value:
+1> bool: true if TTL is changed. false if record isn't found or has expired.
+
+Additional note:
+- Method takes WR store-lock and releases the same. Caller go-routine shouldn't invoke
+this method in any store-lock. It's a deadlock otherwise.
+- TTL is of the record and thus applies to all its keys.
+***************************************************************************** */
+func (pDataCache *DataCache) SetTTL(key Key, ttl time.Duration) bool {
+	if pDataCache == nil {
+		return false
+	}
+
+	unlock := pDataCache.lockKeys([]Key{key})
+	defer unlock()
+
+	now := time.Now()
+	pRec, isOK := pDataCache.lookupWOLock(key)
+	if !isOK || pRec.isExpired(now) {
+		return false
+	}
+
+	if ttl == 0 {
+		ttl = pDataCache.defaultTTL
+	}
+
+	pDataCache.lockRec(pRec)
+	if ttl > 0 {
+		pRec.expiresAt = now.Add(ttl)
+		pDataCache.startJanitor()
+	} else {
+		pRec.expiresAt = time.Time{}
+	}
+	pDataCache.pWAL.logAdd(pRec)  // logged as re-add since the log doesn't carry a separate TTL entry.
+	pRec.unlock()
+
+	return true
+}
+
+
+// Returns remaining TTL of the record referred to by key. NoTTL if the record never expires.
+// false if record isn't found or has expired. Method takes RD store-lock.
+func (pDataCache *DataCache) GetTTL(key Key) (bool, time.Duration) {
+	if pDataCache == nil {
+		return false, 0
+	}
+
+	pDataCache.rlockKey(key)
+	defer pDataCache.runlockKey(key)
+
+	now := time.Now()
+	pRec, isOK := pDataCache.lookupWOLock(key)
+	if !isOK || pRec.isExpired(now) {
+		return false, 0
+	}
+
+	if pRec.expiresAt.IsZero() {
+		return true, NoTTL
+	}
+
+	return true, pRec.expiresAt.Sub(now)
+}
+
+
+/* *****************************************************************************
+Description :
+Removes all expired records from the cache. All keys of an expired record are
+disassociated.
+
+Receiver    :
+pDataCache *DataCache: Datacache instance.
+
+Implements  : NA
+
+Arguments   : NA
+
+Return value:
+1> int: Number of records removed.
+
+Additional note:
+- Method takes WR store-lock and releases the same. Caller go-routine shouldn't invoke
+this method in any store-lock. It's a deadlock otherwise.
+- An expired record which is held locked by some go-routine isn't removed. Method doesn't
+wait for the record lock to be released. The record is rather reclaimed in the subsequent
+run of the janitor.
+***************************************************************************** */
+func (pDataCache *DataCache) PurgeExpired() int {
+	if pDataCache == nil {
+		return 0
+	}
+
+	pDataCache.cacheLock.Lock()
+	defer pDataCache.unlockStore()
+
+	now := time.Now()
+	cnt := 0
+	for _, pRec := range pDataCache.uniqueRecs() {
+		if !pRec.isExpired(now) {
+			continue
+		}
+
+		if !pRec.tryLock() {  // record is held by some go-routine. it's reclaimed later.
+			continue
+		}
+
+		pDataCache.removeRecWOLock(pRec, EventExpire)
+		pRec.unlock()
+		cnt = cnt + 1
+	}
+
+	pDataCache.pCounters.expire(cnt)
+
+	return cnt
+}
+
+
+// Stops background go-routines of the datacache, for instance, the janitor, and closes the write-ahead
+// log, if any. Records remain in the cache. It's safe to invoke Close() more than once.
+// Close() shouldn't be invoked in any store-lock.
+func (pDataCache *DataCache) Close() {
+	if pDataCache == nil {
+		return
+	}
+
+	pDataCache.stopOnce.Do(func() {
+		close(pDataCache.pStopChan)
+	})
+
+	if err := pDataCache.CloseWAL(); err != nil {
+		fmt.Println(pDataCache.logTag(), "Failed to close WAL:", err.Error())
+	}
+}
@@ -0,0 +1,101 @@
+package datacache
+
+import (
+	"testing"
+	"time"
+)
+
+
+func TestTTL(t *testing.T) {
+	const shortTTL = 20 * time.Millisecond
+
+	testList := []struct {
+		name string
+		defaultTTL time.Duration
+		ttl time.Duration
+		isExpired bool
+	}{
+		{"no TTL", 0, 0, false},
+		{"own TTL", 0, shortTTL, true},
+		{"default TTL", shortTTL, 0, true},
+		{"NoTTL over default", shortTTL, NoTTL, false},
+		{"own TTL over default", shortTTL, time.Hour, false},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			pDataCache := Create(nil, nil, WithDefaultTTL(test.defaultTTL))
+			defer pDataCache.Close()
+
+			if _, err := pDataCache.AddRecWithTTL([]Key{"a", "b"}, "v", true, test.ttl); err != nil {
+				t.Fatalf("AddRecWithTTL: %v", err)
+			}
+			if !pDataCache.DoesKeyExist("a") {
+				t.Fatalf("record has expired right away")
+			}
+
+			time.Sleep(2 * shortTTL)
+			for _, key := range []Key{"a", "b"} {
+				isOK, _ := pDataCache.GetDataRec(key)
+				if isOK == test.isExpired {
+					t.Fatalf("GetDataRec(%v) = %v, want %v", key, isOK, !test.isExpired)
+				}
+			}
+
+			// expired record is invisible, yet counted until it's purged.
+			wantCnt := 0
+			if test.isExpired {
+				wantCnt = 1
+			}
+			if cnt := pDataCache.PurgeExpired(); cnt != wantCnt {
+				t.Fatalf("PurgeExpired() = %d, want %d", cnt, wantCnt)
+			}
+			if _, cnt := pDataCache.GetCnt(); cnt != 1 - wantCnt {
+				t.Fatalf("GetCnt() = %d after purge, want %d", cnt, 1 - wantCnt)
+			}
+		})
+	}
+}
+
+
+func TestJanitor(t *testing.T) {
+	pDataCache := Create(nil, nil, WithJanitorInterval(10 * time.Millisecond))
+	defer pDataCache.Close()
+
+	pDataCache.AddRecWithTTL([]Key{"a"}, "v", true, time.Millisecond)
+	pDataCache.AddRec([]Key{"b"}, "v", true)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, cnt := pDataCache.GetCnt(); cnt == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("janitor hasn't reclaimed the expired record")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if !pDataCache.DoesKeyExist("b") {
+		t.Fatalf("janitor has reclaimed the live record")
+	}
+}
+
+
+// Expired record held locked isn't purged; it's reclaimed once released.
+func TestPurgeExpiredLockedRec(t *testing.T) {
+	pDataCache := Create(nil, nil)
+	defer pDataCache.Close()
+
+	pDataCache.AddRecWithTTL([]Key{"a"}, "v", true, 20 * time.Millisecond)
+	_, pRec := pDataCache.GetRec("a")
+	time.Sleep(30 * time.Millisecond)
+
+	if cnt := pDataCache.PurgeExpired(); cnt != 0 {
+		t.Fatalf("PurgeExpired() = %d whilst the record is locked, want 0", cnt)
+	}
+	pRec.DataCacheRecUnlock()
+	if cnt := pDataCache.PurgeExpired(); cnt != 1 {
+		t.Fatalf("PurgeExpired() = %d, want 1", cnt)
+	}
+}
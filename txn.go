@@ -0,0 +1,583 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache
+Filename    : github.com/sameeroak1110/datacache/txn.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Multi-record transactions. BeginTxn() locks records of a set of keys, Add(), Update(),
+Delete() and Alias() stage changes to them and Commit() applies all of them at once, in
+the store-lock, or none of them. Rollback() discards them.
+- Records are locked in the canonical order of keys, by key hash. A record lock which is
+held by some other go-routine is never waited on whilst other record locks are held; all
+of them are released and taken again after a while. Store-locks of Commit() are polled
+the same way. Hence, transactions end up with *TxnConflictError rather than a deadlock.
+- A key which isn't associated to any record whilst the txn begins isn't locked. Commit()
+fails with *TxnConflictError in case some other go-routine has meanwhile associated it, or
+has disassociated a key from a locked record.
+**************************************************************************** */
+package datacache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+
+// Commit() gives up on the store-locks once it's waited this long, even if ctx of the txn isn't done.
+// Store-locks may be held by go-routines which wait for records locked by the txn, for instance, DeleteRec().
+const maxTxnCommitWait = 100 * time.Millisecond
+
+// Matches every *TxnConflictError through errors.Is().
+var ErrTxnConflict = errors.New("Transaction conflict.")
+
+// Returned by methods of a committed or rolled back txn.
+var ErrTxnDone = errors.New("Transaction is already committed or rolled back.")
+
+// TxnConflictError is returned when a txn couldn't lock what it needs or the records it's locked have
+// been changed meanwhile.
+type TxnConflictError struct {
+	Key Key                  // key the conflict is found at.
+	Cause error              // ctx.Err() if the txn has given up waiting. nil otherwise.
+}
+
+
+func (pErr *TxnConflictError) Error() string {
+	if pErr.Cause == nil {
+		return fmt.Sprintf("Transaction conflict at key %#v.", pErr.Key)
+	}
+
+	return fmt.Sprintf("Transaction conflict at key %#v: %s", pErr.Key, pErr.Cause.Error())
+}
+
+
+func (pErr *TxnConflictError) Is(target error) bool {
+	return target == ErrTxnConflict
+}
+
+
+func (pErr *TxnConflictError) Unwrap() error {
+	return pErr.Cause
+}
+
+
+const (
+	txnOpAdd = iota
+	txnOpUpdate
+	txnOpDelete
+	txnOpAlias
+)
+
+type txnOp struct {
+	op int
+	keyList []Key            // txnOpAdd only.
+	key Key
+	newKey Key               // txnOpAlias only.
+	pDataRec interface{}
+}
+
+// Record as is seen by the txn, i.e., with staged changes applied.
+type txnRec struct {
+	pDataRec interface{}
+}
+
+type Txn struct {
+	pDataCache *DataCache
+	ctx context.Context
+	lock sync.Mutex                  // guards all of the following.
+	keyList []Key                    // in canonical order.
+	recMap map[Key]*Rec              // record each key is associated to whilst the txn begins. nil if none.
+	lockedRecList []*Rec             // records locked by the txn.
+	viewMap map[Key]*txnRec          // nil if key isn't associated to any record.
+	opList []txnOp
+	isDone bool
+}
+
+
+// Returns keys in the canonical order, by key hash, without duplicates. Keys of the same hash are
+// ordered by their canonical form (formatKey()).
+func canonicalKeys(keyList []Key) []Key {
+	type hashedKey struct {
+		key Key
+		hash uint64
+		str string
+	}
+
+	isSeenMap := make(map[Key]bool, len(keyList))
+	hashedKeyList := make([]hashedKey, 0, len(keyList))
+	for _, key := range keyList {
+		if isSeenMap[key] {
+			continue
+		}
+		isSeenMap[key] = true
+		hashedKeyList = append(hashedKeyList, hashedKey { key: key, hash: hashKey(key), str: formatKey(key) })
+	}
+
+	sort.Slice(hashedKeyList, func(i, j int) bool {
+		if hashedKeyList[i].hash != hashedKeyList[j].hash {
+			return hashedKeyList[i].hash < hashedKeyList[j].hash
+		}
+		return hashedKeyList[i].str < hashedKeyList[j].str
+	})
+
+	tmpKeyList := make([]Key, len(hashedKeyList))
+	for i := range hashedKeyList {
+		tmpKeyList[i] = hashedKeyList[i].key
+	}
+
+	return tmpKeyList
+}
+
+
+// Waits for the poll interval or until ctx is done. Returns the next interval, or ctx.Err() if ctx is done.
+func waitPollInterval(ctx context.Context, interval time.Duration) (time.Duration, error) {
+	pTimer := time.NewTimer(interval)
+	defer pTimer.Stop()
+
+	select {
+		case <-ctx.Done():
+			return interval, ctx.Err()
+
+		case <-pTimer.C:
+	}
+
+	if interval = interval * 2; interval > maxLockPollInterval {
+		interval = maxLockPollInterval
+	}
+
+	return interval, nil
+}
+
+
+/* ****************************************************************************
+Description :
+Begins a txn over keyList. Record of each key, if any, is locked in exclusive mode until the
+txn is committed or rolled back.
+
+Receiver    :
+pDataCache *DataCache: Instance of datacache.
+
+Implements  : NA
+
+Arguments   :
+1> ctx context.Context: Txn gives up waiting for the record locks, and later on for the
+store-locks in Commit(), once ctx is done. nil means a single attempt.
+2> keyList []Key: Keys the txn operates on. Staged changes are confined to these keys.
+
+Return value:
+1> *Txn: Txn. nil in case of error.
+2> error: *TxnConflictError (matches ErrTxnConflict) in case any of the record locks couldn't
+be acquired. ctx.Err() is its cause.
+
+Additional note:
+- Method takes RD store-lock, key by key, and releases the same. Caller go-routine shouldn't
+invoke this method in any store-lock. It's a deadlock otherwise.
+- An expired record is treated as if the key isn't associated to any record.
+- Caller go-routine shouldn't invoke other methods of the datacache which lock records of the
+txn until the txn is committed or rolled back. It's a deadlock otherwise.
+**************************************************************************** */
+func (pDataCache *DataCache) BeginTxn(ctx context.Context, keyList []Key) (*Txn, error) {
+	if pDataCache == nil {
+		return nil, errors.New("Nil datacache.")
+	}
+
+	if len(keyList) == 0 {
+		return nil, errors.New("Empty key list.")
+	}
+
+	pTxn := &Txn {
+		pDataCache: pDataCache,
+		ctx: ctx,
+		keyList: canonicalKeys(keyList),
+	}
+
+	startTime := time.Now()
+	interval := minLockPollInterval
+	for {
+		conflictKey, isOK := pTxn.lockRecs()
+		if isOK {
+			break
+		}
+
+		if ctx == nil {
+			return nil, &TxnConflictError { Key: conflictKey }
+		}
+
+		var err error
+		if interval, err = waitPollInterval(ctx, interval); err != nil {
+			pDataCache.pCounters.lockWait(time.Since(startTime))
+			return nil, &TxnConflictError { Key: conflictKey, Cause: err }
+		}
+	}
+
+	pTxn.viewMap = make(map[Key]*txnRec, len(pTxn.keyList))
+	txnRecMap := make(map[*Rec]*txnRec, len(pTxn.lockedRecList))
+	for _, key := range pTxn.keyList {
+		pRec := pTxn.recMap[key]
+		if pRec == nil {
+			pTxn.viewMap[key] = nil
+			continue
+		}
+
+		if _, isOK := txnRecMap[pRec]; !isOK {
+			txnRecMap[pRec] = &txnRec { pDataRec: pRec.PDataRec }
+		}
+		pTxn.viewMap[key] = txnRecMap[pRec]
+	}
+
+	return pTxn, nil
+}
+
+
+// Single attempt to lock records of all keys of the txn, in the canonical order. Nothing is held in case
+// any of the record locks is held by some other go-routine; its key is returned.
+func (pTxn *Txn) lockRecs() (Key, bool) {
+	pDataCache := pTxn.pDataCache
+	pTxn.recMap = make(map[Key]*Rec, len(pTxn.keyList))
+	pTxn.lockedRecList = make([]*Rec, 0, len(pTxn.keyList))
+
+	isLockedMap := make(map[*Rec]bool, len(pTxn.keyList))
+	for _, key := range pTxn.keyList {
+		pDataCache.rlockKey(key)
+		pRec, isOK := pDataCache.lookupWOLock(key)
+		if !isOK || pRec.isExpired(time.Now()) {
+			pDataCache.runlockKey(key)
+			pTxn.recMap[key] = nil
+			continue
+		}
+
+		if !isLockedMap[pRec] {
+			if !pRec.tryLock() {
+				pDataCache.runlockKey(key)
+				pTxn.unlockRecs(false)
+				return key, false
+			}
+			isLockedMap[pRec] = true
+			pTxn.lockedRecList = append(pTxn.lockedRecList, pRec)
+		}
+		pDataCache.runlockKey(key)
+		pTxn.recMap[key] = pRec
+	}
+
+	return nil, true
+}
+
+
+// Unlocks records of the txn. Versions are bumped only if the txn has updated the records.
+func (pTxn *Txn) unlockRecs(isModified bool) {
+	for i := len(pTxn.lockedRecList) - 1; i >= 0; i-- {
+		if isModified {
+			pTxn.lockedRecList[i].unlock()
+		} else {
+			pTxn.lockedRecList[i].unlockUnmodified()
+		}
+	}
+	pTxn.lockedRecList = nil
+}
+
+
+// Returns error if the txn is over or key isn't one of its keys.
+// Must be invoked in the txn lock.
+func (pTxn *Txn) checkKey(key Key) error {
+	if pTxn.isDone {
+		return ErrTxnDone
+	}
+
+	if _, isOK := pTxn.viewMap[key]; !isOK {
+		return errors.New(fmt.Sprintf("Key %#v isn't part of the transaction.", key))
+	}
+
+	return nil
+}
+
+
+// Returns payload of the record key is associated to, as is seen by the txn, i.e., with staged changes applied.
+// false if key isn't associated to any record, isn't one of the keys of the txn or the txn is over.
+func (pTxn *Txn) Get(key Key) (bool, interface{}) {
+	if pTxn == nil {
+		return false, nil
+	}
+
+	pTxn.lock.Lock()
+	defer pTxn.lock.Unlock()
+
+	if pTxn.checkKey(key) != nil {
+		return false, nil
+	}
+
+	pTxnRec := pTxn.viewMap[key]
+	if pTxnRec == nil {
+		return false, nil
+	}
+
+	return true, pTxnRec.pDataRec
+}
+
+
+// Stages addition of a new record. Each key of keyList must be one of the keys of the txn and mustn't be
+// associated to any record, as is seen by the txn.
+func (pTxn *Txn) Add(keyList []Key, pDataRec interface{}) error {
+	if (pTxn == nil) || (pDataRec == nil) {
+		return errors.New("Nil transaction or payload.")
+	}
+
+	if len(keyList) == 0 {
+		return errors.New("Empty key list.")
+	}
+
+	pTxn.lock.Lock()
+	defer pTxn.lock.Unlock()
+
+	for _, key := range keyList {
+		if err := pTxn.checkKey(key); err != nil {
+			return err
+		}
+		if pTxn.viewMap[key] != nil {
+			return errors.New(fmt.Sprintf("Key \"%v\" exists.", key))
+		}
+	}
+
+	pTxnRec := &txnRec { pDataRec: pDataRec }
+	for _, key := range keyList {
+		pTxn.viewMap[key] = pTxnRec
+	}
+	pTxn.opList = append(pTxn.opList, txnOp { op: txnOpAdd, keyList: append([]Key(nil), keyList...), pDataRec: pDataRec })
+
+	return nil
+}
+
+
+// Stages replacement of payload of the record key is associated to.
+func (pTxn *Txn) Update(key Key, pDataRec interface{}) error {
+	if (pTxn == nil) || (pDataRec == nil) {
+		return errors.New("Nil transaction or payload.")
+	}
+
+	pTxn.lock.Lock()
+	defer pTxn.lock.Unlock()
+
+	if err := pTxn.checkKey(key); err != nil {
+		return err
+	}
+
+	pTxnRec := pTxn.viewMap[key]
+	if pTxnRec == nil {
+		return errors.New(fmt.Sprintf("Missing key: %#v.", key))
+	}
+
+	pTxnRec.pDataRec = pDataRec
+	pTxn.opList = append(pTxn.opList, txnOp { op: txnOpUpdate, key: key, pDataRec: pDataRec })
+
+	return nil
+}
+
+
+// Stages removal of the record key is associated to. All keys of the record are disassociated.
+func (pTxn *Txn) Delete(key Key) error {
+	if pTxn == nil {
+		return errors.New("Nil transaction.")
+	}
+
+	pTxn.lock.Lock()
+	defer pTxn.lock.Unlock()
+
+	if err := pTxn.checkKey(key); err != nil {
+		return err
+	}
+
+	pTxnRec := pTxn.viewMap[key]
+	if pTxnRec == nil {
+		return errors.New(fmt.Sprintf("Missing key: %#v.", key))
+	}
+
+	for tmpKey, pTmpTxnRec := range pTxn.viewMap {
+		if pTmpTxnRec == pTxnRec {
+			pTxn.viewMap[tmpKey] = nil
+		}
+	}
+	pTxn.opList = append(pTxn.opList, txnOp { op: txnOpDelete, key: key })
+
+	return nil
+}
+
+
+// Stages association of newKey to the record originalKey is associated to, just the way ReAddRec() does.
+// Both keys must be keys of the txn.
+func (pTxn *Txn) Alias(originalKey Key, newKey Key) error {
+	if pTxn == nil {
+		return errors.New("Nil transaction.")
+	}
+
+	pTxn.lock.Lock()
+	defer pTxn.lock.Unlock()
+
+	if err := pTxn.checkKey(originalKey); err != nil {
+		return err
+	}
+	if err := pTxn.checkKey(newKey); err != nil {
+		return err
+	}
+
+	pTxnRec := pTxn.viewMap[originalKey]
+	if pTxnRec == nil {
+		return errors.New(fmt.Sprintf("Missing key: %#v.", originalKey))
+	}
+
+	pTxn.viewMap[newKey] = pTxnRec
+	pTxn.opList = append(pTxn.opList, txnOp { op: txnOpAlias, key: originalKey, newKey: newKey })
+
+	return nil
+}
+
+
+/* ****************************************************************************
+Description :
+Applies all staged changes at once and releases the records locked by the txn.
+
+Receiver    :
+pTxn *Txn: Txn.
+
+Implements  : NA
+
+Arguments   : NA
+
+Return value:
+1> error: *TxnConflictError (matches ErrTxnConflict) in case store-locks couldn't be taken or
+some key of the txn is associated to some other record than it's been whilst the txn began.
+Nothing is applied in that case and the txn is rolled back. ErrTxnDone if the txn is over.
+
+Additional note:
+- Store-locks are polled, whilst records remain locked, until ctx of the txn is done but no
+longer than maxTxnCommitWait. With nil ctx, that's a single attempt.
+- Readers, Update() and CompareAndSwap() release the store-lock whilst they wait for a record
+locked by the txn. Hence, they don't hold up the commit. UpdateRecState() and the methods
+which take WR store-lock, for instance, DeleteRec(), wait for the record in the store-lock.
+Commit() may thus fail in case such a method waits for a record of the txn.
+- Caller go-routine shouldn't invoke this method in any store-lock.
+**************************************************************************** */
+func (pTxn *Txn) Commit() error {
+	if pTxn == nil {
+		return errors.New("Nil transaction.")
+	}
+
+	pTxn.lock.Lock()
+	defer pTxn.lock.Unlock()
+
+	if pTxn.isDone {
+		return ErrTxnDone
+	}
+	pTxn.isDone = true
+
+	unlock, err := pTxn.lockStore()
+	if err != nil {
+		pTxn.unlockRecs(false)
+		return err
+	}
+	defer unlock()
+	isModified := false  // versions aren't bumped in case of conflict.
+	defer func() {
+		pTxn.unlockRecs(isModified)  // before store-locks, so that records are published along with the keys.
+	}()
+
+	pDataCache := pTxn.pDataCache
+	now := time.Now()
+	for _, key := range pTxn.keyList {
+		pRec, isOK := pDataCache.lookupWOLock(key)
+		if !isOK || pRec.isExpired(now) {
+			pRec = nil
+		}
+		if pRec != pTxn.recMap[key] {
+			return &TxnConflictError { Key: key }
+		}
+	}
+
+	isModified = len(pTxn.opList) > 0
+	isLockedMap := make(map[*Rec]bool, len(pTxn.lockedRecList))
+	for _, pRec := range pTxn.lockedRecList {
+		isLockedMap[pRec] = true
+	}
+
+	for _, op := range pTxn.opList {
+		switch op.op {
+			case txnOpAdd:
+				pDataCache.storeRecWOLock(pDataCache.newRec(op.keyList, op.pDataRec, 0))
+
+			case txnOpUpdate:
+				pRec, _ := pDataCache.lookupWOLock(op.key)
+				pDataCache.replacePayloadWOLock(pRec, op.pDataRec)
+				if !isLockedMap[pRec] {  // added by the txn. locked records are published once unlocked.
+					pRec.publishCOW()
+				}
+
+			case txnOpDelete:
+				pRec, _ := pDataCache.lookupWOLock(op.key)
+				pDataCache.removeRecWOLock(pRec, EventDelete)
+				pDataCache.pCounters.delete(1)
+
+			case txnOpAlias:
+				pRec, _ := pDataCache.lookupWOLock(op.key)
+				pDataCache.aliasRecWOLock(pRec, op.newKey)
+		}
+	}
+
+	return nil
+}
+
+
+// Polls store-locks needed to commit the txn. Records of the txn remain locked meanwhile.
+func (pTxn *Txn) lockStore() (func(), error) {
+	ctx := pTxn.ctx
+	if ctx != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, maxTxnCommitWait)
+		defer cancel()
+	}
+
+	interval := minLockPollInterval
+	for {
+		if unlock, isOK := pTxn.pDataCache.tryLockKeys(pTxn.keyList); isOK {
+			return unlock, nil
+		}
+
+		if ctx == nil {
+			return nil, &TxnConflictError { Key: pTxn.keyList[0] }
+		}
+
+		var err error
+		if interval, err = waitPollInterval(ctx, interval); err != nil {
+			return nil, &TxnConflictError { Key: pTxn.keyList[0], Cause: err }
+		}
+	}
+}
+
+
+// Discards staged changes and releases the records locked by the txn. It's safe to invoke Rollback()
+// once the txn is over, for instance, in defer; it's a no-op then.
+func (pTxn *Txn) Rollback() {
+	if pTxn == nil {
+		return
+	}
+
+	pTxn.lock.Lock()
+	defer pTxn.lock.Unlock()
+
+	if pTxn.isDone {
+		return
+	}
+
+	pTxn.isDone = true
+	pTxn.opList = nil
+	pTxn.unlockRecs(false)
+}
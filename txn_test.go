@@ -0,0 +1,111 @@
+package datacache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+
+// Readers waiting for a record locked by a txn don't hold up the store-locks Commit() needs.
+func TestTxnCommitWithBlockedReaders(t *testing.T) {
+	testList := []struct {
+		name string
+		opts []Option
+	}{
+		{"single shard", nil},
+		{"sharded", []Option{WithShards(8)}},
+		{"copy-on-write", []Option{WithCopyOnWrite(0)}},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			pDataCache := Create(nil, nil, test.opts...)
+			defer pDataCache.Close()
+			pDataCache.AddRec([]Key{"a"}, "v1", true)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
+			defer cancel()
+			pTxn, err := pDataCache.BeginTxn(ctx, []Key{"a"})
+			if err != nil {
+				t.Fatalf("BeginTxn: %v", err)
+			}
+
+			readerList := []func() interface{} {
+				func() interface{} {
+					_, pDataRec := pDataCache.GetDataRec("a")
+					return pDataRec
+				},
+				func() interface{} {
+					isOK, pRec := pDataCache.GetRec("a")
+					if !isOK {
+						return nil
+					}
+					defer pRec.DataCacheRecUnlock()
+					return pRec.PDataRec
+				},
+				func() interface{} {
+					_, pDataRec, _ := pDataCache.GetDataRecVersion("a")
+					return pDataRec
+				},
+			}
+
+			var wg sync.WaitGroup
+			resultChan := make(chan interface{}, 4 * len(readerList))
+			for i := 0; i < 4; i++ {
+				for _, reader := range readerList {
+					wg.Add(1)
+					go func(reader func() interface{}) {
+						defer wg.Done()
+						resultChan <- reader()
+					}(reader)
+				}
+			}
+			time.Sleep(50 * time.Millisecond)  // readers wait for the record by now.
+
+			pTxn.Update("a", "v2")
+			runWithTimeout(t, 5 * time.Second, func() {
+				if err := pTxn.Commit(); err != nil {
+					t.Errorf("Commit: %v", err)
+				}
+			})
+
+			runWithTimeout(t, 5 * time.Second, wg.Wait)
+			close(resultChan)
+			for pDataRec := range resultChan {
+				if pDataRec != "v2" {  // COW readers don't wait for the record and may've read v1.
+					if (pDataRec != "v1") || (pDataCache.pCOW == nil) {
+						t.Fatalf("reader got %v, want v2", pDataRec)
+					}
+				}
+			}
+		})
+	}
+}
+
+
+// Commit() which fails validation doesn't bump versions of the records it's locked.
+func TestTxnConflictKeepsVersion(t *testing.T) {
+	pDataCache := Create(nil, nil, WithShards(8))
+	defer pDataCache.Close()
+	pDataCache.AddRec([]Key{"a"}, "v1", true)
+	_, _, version := pDataCache.GetDataRecVersion("a")
+
+	pTxn, err := pDataCache.BeginTxn(nil, []Key{"a", "b"})
+	if err != nil {
+		t.Fatalf("BeginTxn: %v", err)
+	}
+	pTxn.Update("a", "v2")
+	pDataCache.AddRec([]Key{"b"}, "other", true)  // b wasn't associated whilst the txn began.
+
+	if err = pTxn.Commit(); !errors.Is(err, ErrTxnConflict) {
+		t.Fatalf("Commit() = %v, want ErrTxnConflict", err)
+	}
+
+	_, pDataRec, tmpVersion := pDataCache.GetDataRecVersion("a")
+	if (pDataRec != "v1") || (tmpVersion != version) {
+		t.Fatalf("a = %v at version %d, want v1 at %d", pDataRec, tmpVersion, version)
+	}
+}
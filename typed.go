@@ -0,0 +1,539 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache
+Filename    : github.com/sameeroak1110/datacache/typed.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Generic, type-safe wrapper over DataCache.
+- Key and payload types are fixed at compile time. Hence, a key of wrong type (for
+instance, int vs int64) or a payload of wrong type is caught by the compiler rather
+than silently missing the cache.
+- The untyped DataCache API continues to work alongside. TypedCache just converts
+keys and payloads while delegating every operation to the DataCache it wraps.
+**************************************************************************** */
+package datacache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+
+// TypedPayload is the typed counterpart of Payload.
+type TypedPayload[K comparable, V any] struct {
+	KeyList []K    // cache record may have multiple keys.
+	PDataRec V     // actual payload-data. should preferably be a pointer type.
+	TTL time.Duration  // optional. same as Payload.TTL.
+}
+
+// function types for loading the typed cache and iteration callback.
+type TypedLoadFunc[K comparable, V any] func() ([]TypedPayload[K, V], error)
+type TypedRecHandlerFunc[V any] func(V) bool
+
+type TypedCache[K comparable, V any] struct {
+	pDataCache *DataCache  // underlying untyped datacache. all operations are delegated to it.
+}
+
+
+// converts typed key list to the untyped one as is stored in the DataCache.
+func toKeyList[K comparable](keyList []K) []Key {
+	tmpKeyList := make([]Key, len(keyList))
+	for i := range keyList {
+		tmpKeyList[i] = keyList[i]
+	}
+
+	return tmpKeyList
+}
+
+
+// asserts untyped payload to V. returns false if payload isn't of type V.
+func toTypedPayload[V any](pDataRec interface{}) (bool, V) {
+	var zero V
+
+	if pDataRec == nil {
+		return false, zero
+	}
+
+	pTypedRec, isOK := pDataRec.(V)
+	if !isOK {
+		return false, zero
+	}
+
+	return true, pTypedRec
+}
+
+
+/* ****************************************************************************
+Description :
+Function creates typed datacache instance. Underlying untyped datacache is created
+through Create().
+
+Receiver    : NA
+
+Implements  : NA
+
+Arguments   :
+1> loadFunc TypedLoadFunc[K, V]: Callback used by Load() to load the cache. Can be nil.
+2> iteratorFunc TypedRecHandlerFunc[V]: Callback used by Iterate(). Can be nil.
+3> opts ...Option: Optional configuration, same as that of Create().
+
+Return value:
+1> *TypedCache[K, V]: Newly created typed datacache instance.
+
+Additional note: NA
+**************************************************************************** */
+func CreateTyped[K comparable, V any](loadFunc TypedLoadFunc[K, V], iteratorFunc TypedRecHandlerFunc[V], opts ...Option) *TypedCache[K, V] {
+	var loadfn LoadFunc
+	var reciteratefn RecHandlerFunc
+
+	if loadFunc != nil {
+		loadfn = func() ([]Payload, error) {
+			typedRecList, err := loadFunc()
+			if err != nil {
+				return nil, err
+			}
+
+			recList := make([]Payload, len(typedRecList))
+			for i := range typedRecList {
+				recList[i] = Payload {
+					KeyList: toKeyList(typedRecList[i].KeyList),
+					PDataRec: typedRecList[i].PDataRec,
+					TTL: typedRecList[i].TTL,
+				}
+			}
+
+			return recList, nil
+		}
+	}
+
+	if iteratorFunc != nil {
+		reciteratefn = func(pDataRec interface{}) bool {
+			isOK, pTypedRec := toTypedPayload[V](pDataRec)
+			if !isOK {
+				return false
+			}
+
+			return iteratorFunc(pTypedRec)
+		}
+	}
+
+	return &TypedCache[K, V] {
+		pDataCache: Create(loadfn, reciteratefn, opts...),
+	}
+}
+
+
+// Returns underlying untyped datacache. Useful for the operations which TypedCache doesn't wrap,
+// for instance, store-locks and WOLock methods.
+func (pTypedCache *TypedCache[K, V]) Untyped() *DataCache {
+	if pTypedCache == nil {
+		return nil
+	}
+
+	return pTypedCache.pDataCache
+}
+
+
+/* ****************************************************************************
+Description :
+Typed equivalent of AddRec(). Payload pRec is added in the data-cache against all
+keys of keyList.
+
+Receiver    :
+pTypedCache *TypedCache[K, V]: Typed datacache instance.
+
+Implements  : NA
+
+Arguments   :
+1> keyList []K: List of keys that refers to the cache record payload.
+2> pRec V: Record payload.
+3> recExistsErrFlag bool: If true: error is returned in case a record is found for any key.
+
+Return value:
+1> int: Number of records in the cache.
+2> error: Error string in case of error.
+
+Additional note:
+- Same locking rules as AddRec(). Caller shouldn't take any lock before calling this method.
+**************************************************************************** */
+func (pTypedCache *TypedCache[K, V]) Add(keyList []K, pRec V, recExistsErrFlag bool) (int, error) {
+	if pTypedCache == nil {
+		return -1, errors.New("NULL datacache.")
+	}
+
+	return pTypedCache.pDataCache.AddRec(toKeyList(keyList), pRec, recExistsErrFlag)
+}
+
+
+// Typed equivalent of AddRecWithTTL().
+func (pTypedCache *TypedCache[K, V]) AddWithTTL(keyList []K, pRec V, recExistsErrFlag bool, ttl time.Duration) (int, error) {
+	if pTypedCache == nil {
+		return -1, errors.New("NULL datacache.")
+	}
+
+	return pTypedCache.pDataCache.AddRecWithTTL(toKeyList(keyList), pRec, recExistsErrFlag, ttl)
+}
+
+
+// Typed equivalent of ForceAddRec().
+func (pTypedCache *TypedCache[K, V]) ForceAdd(keyList []K, pRec V) (int, error) {
+	if pTypedCache == nil {
+		return -1, errors.New("NULL datacache.")
+	}
+
+	return pTypedCache.pDataCache.ForceAddRec(toKeyList(keyList), pRec)
+}
+
+
+// Typed equivalent of ForceAddRecWithTTL().
+func (pTypedCache *TypedCache[K, V]) ForceAddWithTTL(keyList []K, pRec V, ttl time.Duration) (int, error) {
+	if pTypedCache == nil {
+		return -1, errors.New("NULL datacache.")
+	}
+
+	return pTypedCache.pDataCache.ForceAddRecWithTTL(toKeyList(keyList), pRec, ttl)
+}
+
+
+// Typed equivalent of ReAddRec().
+func (pTypedCache *TypedCache[K, V]) ReAdd(originalKey K, newKey K) (int, error) {
+	if pTypedCache == nil {
+		return -1, errors.New("NULL datacache.")
+	}
+
+	return pTypedCache.pDataCache.ReAddRec(originalKey, newKey)
+}
+
+
+/* ****************************************************************************
+Description :
+Typed equivalent of GetDataRec(). Returns payload of the cache record referred to by key.
+
+Receiver    :
+pTypedCache *TypedCache[K, V]: Typed datacache instance.
+
+Implements  : NA
+
+Arguments   :
+1> key K: Key to fetch cache record.
+
+Return value:
+1> bool: true if successful. false if record isn't found or if its payload isn't of type V.
+The latter happens only if the underlying datacache has been written to through the untyped API.
+2> V: Payload of fetched datacache record. Zero value of V in case of error.
+
+Additional note:
+- Same locking rules as GetDataRec(). Caller shouldn't take any lock before calling this method.
+**************************************************************************** */
+func (pTypedCache *TypedCache[K, V]) Get(key K) (bool, V) {
+	var zero V
+
+	if pTypedCache == nil {
+		return false, zero
+	}
+
+	isOK, pDataRec := pTypedCache.pDataCache.GetDataRec(key)
+	if !isOK {
+		return false, zero
+	}
+
+	return toTypedPayload[V](pDataRec)
+}
+
+
+// Typed equivalent of GetOrLoad(). Loader is configured through WithKeyLoader() whilst creating the cache.
+func (pTypedCache *TypedCache[K, V]) GetOrLoad(key K) (bool, V, error) {
+	var zero V
+
+	if pTypedCache == nil {
+		return false, zero, errors.New("Nil datacache.")
+	}
+
+	isOK, pDataRec, err := pTypedCache.pDataCache.GetOrLoad(key)
+	if !isOK {
+		return false, zero, err
+	}
+
+	isOK, pTypedRec := toTypedPayload[V](pDataRec)
+	return isOK, pTypedRec, err
+}
+
+
+// Typed equivalent of GetRec(). Returned record is locked. It's caller's responsibility to unlock the same.
+func (pTypedCache *TypedCache[K, V]) GetRec(key K) (bool, *Rec) {
+	if pTypedCache == nil {
+		return false, nil
+	}
+
+	return pTypedCache.pDataCache.GetRec(key)
+}
+
+
+// Typed equivalent of DeleteRec().
+func (pTypedCache *TypedCache[K, V]) Delete(key K) (int, error) {
+	if pTypedCache == nil {
+		return -1, errors.New("Nil datacache")
+	}
+
+	return pTypedCache.pDataCache.DeleteRec(key)
+}
+
+
+// Typed equivalent of DeleteKey().
+func (pTypedCache *TypedCache[K, V]) DeleteKey(key K) error {
+	if pTypedCache == nil {
+		return errors.New("Nil datacache")
+	}
+
+	return pTypedCache.pDataCache.DeleteKey(key)
+}
+
+
+// Typed equivalent of UpdateRecState().
+func (pTypedCache *TypedCache[K, V]) UpdateState(key K, recState bool) bool {
+	if pTypedCache == nil {
+		return false
+	}
+
+	return pTypedCache.pDataCache.UpdateRecState(key, recState)
+}
+
+
+// Typed equivalent of GetRecContext().
+func (pTypedCache *TypedCache[K, V]) GetRecContext(ctx context.Context, key K) (bool, *Rec, error) {
+	if pTypedCache == nil {
+		return false, nil, errors.New("Nil datacache.")
+	}
+
+	return pTypedCache.pDataCache.GetRecContext(ctx, key)
+}
+
+
+// Typed equivalent of GetRecTimeout().
+func (pTypedCache *TypedCache[K, V]) GetRecTimeout(key K, d time.Duration) (bool, *Rec, error) {
+	if pTypedCache == nil {
+		return false, nil, errors.New("Nil datacache.")
+	}
+
+	return pTypedCache.pDataCache.GetRecTimeout(key, d)
+}
+
+
+// Typed equivalent of TryGetRec().
+func (pTypedCache *TypedCache[K, V]) TryGetRec(key K) (bool, *Rec, error) {
+	if pTypedCache == nil {
+		return false, nil, errors.New("Nil datacache.")
+	}
+
+	return pTypedCache.pDataCache.TryGetRec(key)
+}
+
+
+// Typed equivalent of DeleteRecContext().
+func (pTypedCache *TypedCache[K, V]) DeleteContext(ctx context.Context, key K) (int, error) {
+	if pTypedCache == nil {
+		return -1, errors.New("Nil datacache")
+	}
+
+	return pTypedCache.pDataCache.DeleteRecContext(ctx, key)
+}
+
+
+// Typed equivalent of UpdateRecStateContext().
+func (pTypedCache *TypedCache[K, V]) UpdateStateContext(ctx context.Context, key K, recState bool) (bool, error) {
+	if pTypedCache == nil {
+		return false, errors.New("Nil datacache.")
+	}
+
+	return pTypedCache.pDataCache.UpdateRecStateContext(ctx, key, recState)
+}
+
+
+// Typed equivalent of DoesKeyExist().
+func (pTypedCache *TypedCache[K, V]) DoesKeyExist(key K) bool {
+	if pTypedCache == nil {
+		return false
+	}
+
+	return pTypedCache.pDataCache.DoesKeyExist(key)
+}
+
+
+// Typed equivalent of GetDataRecVersion().
+func (pTypedCache *TypedCache[K, V]) GetWithVersion(key K) (bool, V, uint64) {
+	var zero V
+
+	if pTypedCache == nil {
+		return false, zero, 0
+	}
+
+	isOK, pDataRec, version := pTypedCache.pDataCache.GetDataRecVersion(key)
+	if !isOK {
+		return false, zero, 0
+	}
+
+	isOK, pTypedRec := toTypedPayload[V](pDataRec)
+	if !isOK {
+		return false, zero, 0
+	}
+
+	return true, pTypedRec, version
+}
+
+
+// Typed equivalent of CompareAndSwap().
+func (pTypedCache *TypedCache[K, V]) CompareAndSwap(key K, expectedVersion uint64, pRec V) (uint64, error) {
+	if pTypedCache == nil {
+		return 0, errors.New("Nil datacache.")
+	}
+
+	return pTypedCache.pDataCache.CompareAndSwap(key, expectedVersion, pRec)
+}
+
+
+// Wraps typed update function as UpdateFunc. nil current payload, i.e., the one of Upsert() which adds the record,
+// is passed as zero value of V.
+func toUpdateFunc[V any](updateFunc func(V) (V, error)) UpdateFunc {
+	return func(pOldDataRec interface{}) (interface{}, error) {
+		var pOldRec V
+		if pOldDataRec != nil {
+			var isOK bool
+			if isOK, pOldRec = toTypedPayload[V](pOldDataRec); !isOK {
+				return nil, errors.New(fmt.Sprintf("Payload is of type %T.", pOldDataRec))
+			}
+		}
+
+		return updateFunc(pOldRec)
+	}
+}
+
+
+// Typed equivalent of Update().
+func (pTypedCache *TypedCache[K, V]) Update(key K, updateFunc func(V) (V, error)) (uint64, error) {
+	if (pTypedCache == nil) || (updateFunc == nil) {
+		return 0, errors.New("Nil datacache or update function.")
+	}
+
+	return pTypedCache.pDataCache.Update(key, toUpdateFunc(updateFunc))
+}
+
+
+// Typed equivalent of Upsert(). updateFunc is passed zero value of V in case the record is added.
+func (pTypedCache *TypedCache[K, V]) Upsert(keyList []K, updateFunc func(V) (V, error)) (uint64, error) {
+	if (pTypedCache == nil) || (updateFunc == nil) {
+		return 0, errors.New("Nil datacache or update function.")
+	}
+
+	return pTypedCache.pDataCache.Upsert(toKeyList(keyList), toUpdateFunc(updateFunc))
+}
+
+
+// Typed equivalent of CompareAndDelete().
+func (pTypedCache *TypedCache[K, V]) CompareAndDelete(key K, expectedVersion uint64) (int, error) {
+	if pTypedCache == nil {
+		return -1, errors.New("Nil datacache")
+	}
+
+	return pTypedCache.pDataCache.CompareAndDelete(key, expectedVersion)
+}
+
+
+// Stops background go-routines of the underlying datacache.
+func (pTypedCache *TypedCache[K, V]) Close() {
+	if pTypedCache != nil {
+		pTypedCache.pDataCache.Close()
+	}
+}
+
+
+// Typed equivalent of GetCnt().
+func (pTypedCache *TypedCache[K, V]) GetCnt() (bool, int) {
+	if pTypedCache == nil {
+		return false, 0
+	}
+
+	return pTypedCache.pDataCache.GetCnt()
+}
+
+
+// Typed equivalent of Load().
+func (pTypedCache *TypedCache[K, V]) Load(isLoaderProvided bool) (bool, error) {
+	if pTypedCache == nil {
+		return false, errors.New("Nil datacache.")
+	}
+
+	return pTypedCache.pDataCache.Load(isLoaderProvided)
+}
+
+
+// Typed equivalent of Iterate().
+func (pTypedCache *TypedCache[K, V]) Iterate(cacheName string, isIteratorProvided bool) (bool, error) {
+	if pTypedCache == nil {
+		return false, errors.New("Nil datacache.")
+	}
+
+	return pTypedCache.pDataCache.Iterate(cacheName, isIteratorProvided)
+}
+
+
+// Typed equivalent of LoadAndIterate().
+func (pTypedCache *TypedCache[K, V]) LoadAndIterate(isLoaderProvided bool, isIteratorProvided bool) (bool, error) {
+	if pTypedCache == nil {
+		return false, errors.New("Nil datacache.")
+	}
+
+	return pTypedCache.pDataCache.LoadAndIterate(isLoaderProvided, isIteratorProvided)
+}
+
+
+/* ****************************************************************************
+Description :
+Typed equivalent of AuxIterate(). recHandler is invoked on payload of each iterated
+record whilst the record is guarded in its record lock.
+
+Receiver    :
+pTypedCache *TypedCache[K, V]: Typed datacache instance.
+
+Implements  : NA
+
+Arguments   :
+1> cacheName string: Name of the cache.
+2> recHandler TypedRecHandlerFunc[V]: Handler function of each iterated record's payload.
+
+Return value:
+1> bool: true if successful, false otherwise.
+2> error: Returns cause of error.
+
+Additional note:
+- Same locking rules and word of caution as AuxIterate().
+- Records with payload not of type V are skipped.
+**************************************************************************** */
+func (pTypedCache *TypedCache[K, V]) AuxIterate(cacheName string, recHandler TypedRecHandlerFunc[V]) (bool, error) {
+	if pTypedCache == nil {
+		return false, errors.New("Nil datacache.")
+	}
+
+	if recHandler == nil {
+		return false, errors.New("Nil or no cache record handler provided.")
+	}
+
+	return pTypedCache.pDataCache.AuxIterate(cacheName, func(pRec interface{}) bool {
+		isOK, pTypedRec := toTypedPayload[V](pRec.(*Rec).PDataRec)
+		if !isOK {
+			return false
+		}
+
+		return recHandler(pTypedRec)
+	})
+}
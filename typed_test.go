@@ -0,0 +1,81 @@
+package datacache
+
+import (
+	"errors"
+	"testing"
+)
+
+
+type testUser struct {
+	Name string
+	Age int
+}
+
+
+func TestTypedCache(t *testing.T) {
+	loadFunc := func() ([]TypedPayload[string, *testUser], error) {
+		return []TypedPayload[string, *testUser] {
+			{KeyList: []string{"u1", "alice"}, PDataRec: &testUser { Name: "alice", Age: 30 }},
+			{KeyList: []string{"u2"}, PDataRec: &testUser { Name: "bob", Age: 40 }},
+		}, nil
+	}
+	pTypedCache := CreateTyped[string, *testUser](loadFunc, nil)
+	defer pTypedCache.Close()
+
+	if isOK, err := pTypedCache.Load(true); !isOK || (err != nil) {
+		t.Fatalf("Load() = %v, %v", isOK, err)
+	}
+
+	testList := []struct {
+		key string
+		isFound bool
+		name string
+	}{
+		{"u1", true, "alice"},
+		{"alice", true, "alice"},
+		{"u2", true, "bob"},
+		{"u3", false, ""},
+	}
+	for _, test := range testList {
+		isOK, pUser := pTypedCache.Get(test.key)
+		if (isOK != test.isFound) || (isOK && (pUser.Name != test.name)) {
+			t.Fatalf("Get(%s) = %v, %v; want %v, %s", test.key, isOK, pUser, test.isFound, test.name)
+		}
+	}
+
+	if _, err := pTypedCache.Update("u2", func(pUser *testUser) (*testUser, error) {
+		return &testUser { Name: pUser.Name, Age: pUser.Age + 1 }, nil
+	}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if _, pUser := pTypedCache.Get("u2"); pUser.Age != 41 {
+		t.Fatalf("age after Update() = %d, want 41", pUser.Age)
+	}
+
+	if _, err := pTypedCache.Upsert([]string{"u3"}, func(pUser *testUser) (*testUser, error) {
+		if pUser != nil {
+			return nil, errors.New("u3 exists")
+		}
+		return &testUser { Name: "carol" }, nil
+	}); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	if isOK, pUser := pTypedCache.Get("u3"); !isOK || (pUser.Name != "carol") {
+		t.Fatalf("Get(u3) after Upsert() = %v, %v", isOK, pUser)
+	}
+}
+
+
+// Payload of some other type, written through the untyped API, isn't returned as V.
+func TestTypedCacheForeignPayload(t *testing.T) {
+	pTypedCache := CreateTyped[int, string](nil, nil)
+	defer pTypedCache.Close()
+
+	pTypedCache.Untyped().AddRec([]Key{1}, 42, true)
+	if isOK, s := pTypedCache.Get(1); isOK || (s != "") {
+		t.Fatalf("Get(1) = %v, %q; want false", isOK, s)
+	}
+	if _, err := pTypedCache.Update(1, func(s string) (string, error) { return s, nil }); err == nil {
+		t.Fatalf("Update() of foreign payload succeeded")
+	}
+}
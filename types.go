@@ -19,6 +19,7 @@ package datacache
 
 import (
 	"sync"
+	"time"
 )
 
 
@@ -27,6 +28,7 @@ type Key interface{}
 type Payload struct {
 	KeyList []Key           // Key is of type interface{}. cache record may have multiple keys.
 	PDataRec interface{}    // this's actual payload-data. should've been created dynamically, i.e., it should be a pointer.
+	TTL time.Duration       // optional. 0 means cache-wide default TTL, NoTTL means the record never expires.
 }
 
 type Rec struct {
@@ -35,13 +37,20 @@ type Rec struct {
 	isActive bool           // if false, the record is assumed to be deactivated. each record fetch request should be dishonoured if this flag is unset.
 	//isDeleted bool        // if true, the record is scheduled for deletion. deleted record is purged at some very low traffic hour. typically, at 0 hrs.
 	refcnt uint
+	expiresAt time.Time     // zero if the record never expires. guarded by WR store-lock.
+	size int64              // payload size as reported by SizeFunc when the record was added.
+	version uint64          // bumped each time the record lock is released in exclusive mode. guarded by the record lock.
 
 	/* record lock: a successful search through the cache returns a locked-record.
-	- any transaction on the record is mutually exclusive.
+	- any update of the record is mutually exclusive. reads, such as GetDataRec(), GetRecForRead() and
+	iteration, share the record lock amongst themselves.
 	- it's the caller's prerogative to unlock the locked-record.
 	- any further attempt to lock an already locked-record in the same go-routine results in a deadlock. */
-	pRecLock *sync.Mutex
+	pRecLock *sync.RWMutex      // exclusive for updates, shared for reads.
+	isWRHeld int32              // 1 whilst pRecLock is held in exclusive mode. accessed atomically.
 	pUnlockRecLock *sync.Mutex  // used specifically during unlocking.
+	pLockTracker *lockTracker   // same as that of the datacache. nil unless lock debug mode is on.
+	pCOWRec *cowRec             // published copy of the record. nil unless copy-on-write mode is on.
 }
 
 
@@ -55,7 +64,16 @@ type CacheStore map[Key]*Rec
 type LoadFunc func() ([]Payload, error)
 type RecHandlerFunc func(interface{}) bool
 
+// returns the new payload of a record given the current one. used by Update() and Upsert().
+type UpdateFunc func(interface{}) (interface{}, error)
+
+// reports size of a record payload in bytes. used for bounding the cache by bytes.
+type SizeFunc func(interface{}) int64
+
 type DataCache struct {
+	cnt int64                    // number of records in the cache. accessed atomically. first in the struct to keep it 64-bit aligned.
+	bytes int64                  // sum of payload sizes of all records. accessed atomically.
+
 	// cache store-lock. there're 2 simple rules for store-lock primitives
 	// wr store-lock: It's mutually exclusive for any other store-lock.
 	// rd store-lock: It's mutually inclusive for any other rd store-lock but exclusive for wr store-lock
@@ -63,12 +81,47 @@ type DataCache struct {
 	// for instance, when a record is added to or removed from the cache. equally, when the cache is iterated.
 	// and rd store-lock is to be invoked when typically a cache record is to fetched for update or just to fetch
 	// record data.
+	// with more than one shard, rd store-lock is taken along with shard-locks. see shard.go.
 	cacheLock sync.RWMutex       // cache store-lock. rd store lock/unlock and wr store lock/unlock operations.
-	cache CacheStore             // actual cache store
+	shards []*cacheShard         // actual cache store. keys are spread across shards by their hash.
 	loadfn LoadFunc              // function loads the cache during server boot-up.
 	reciteratefn RecHandlerFunc  // each record is handled by iterator.
-	cnt int                      // number of records in the cache.
 	singletonFlag bool           // should be guarded in WR store lock.
+
+	defaultTTL time.Duration       // applied to records added without their own TTL. 0 if records don't expire by default.
+	janitorInterval time.Duration  // interval at which expired records are reclaimed.
+	janitorOnce sync.Once          // janitor go-routine is started when first expiring record is added.
+	pStopChan chan struct{}        // closed by Close(). stops background go-routines.
+	stopOnce sync.Once
+
+	maxRecs int                    // maximum number of records. 0 if unbounded.
+	maxBytes int64                 // maximum sum of payload sizes. 0 if unbounded.
+	sizefn SizeFunc                // reports payload size. nil unless cache is bounded by bytes.
+	policy EvictionPolicy          // decides which record is evicted. nil if the cache is unbounded.
+
+	keyloadfn KeyLoadFunc          // loads a single record on cache miss. used by GetOrLoad().
+	loadLock sync.Mutex            // guards loadCallMap.
+	loadCallMap map[Key]*loadCall  // in-flight loader calls.
+
+	reloadLock sync.Mutex          // serializes reloads.
+	refreshInterval time.Duration  // interval of periodic reload. 0 if the cache isn't reloaded periodically.
+	generation uint64              // incremented by each reload. guarded by WR store-lock.
+	lastReloadStats ReloadStats    // guarded by WR store-lock.
+
+	codec Codec                    // serializes records, for instance, in snapshots.
+	newPayloadFn func() interface{}  // returns a new, empty payload to decode into. optional.
+
+	pWAL *wal                      // write-ahead log. nil unless OpenWAL() is invoked. set and cleared in WR store-lock.
+	                               // entries are logged in RD store-lock too; the wal's own lock serializes them.
+	pCounters *cacheCounters       // stats counters. accessed atomically.
+	name string                    // name the cache is registered under. guarded by registry lock.
+
+	defaultLease time.Duration     // lease of handles acquired without their own lease. 0 if handles don't expire.
+	leaseExpiryfn LeaseExpiryFunc  // invoked once the lease of a handle expires. nil means the expiry is logged.
+	pLockTracker *lockTracker      // tracks lock holders. nil unless lock debug mode is on.
+	pCOW *cowStore                 // published key map. nil unless copy-on-write mode is on.
+	pWatch *watchHub               // change-event subscribers.
+	pHooks *hookRegistry           // lifecycle hooks.
 }
 
 //var singletonFlag bool       // should be guarded in WR store lock.
@@ -0,0 +1,166 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache
+Filename    : github.com/sameeroak1110/datacache/update.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Atomic read-modify-write of a record payload. Update() and Upsert() run the caller's
+function in the record lock and replace the payload with the one it returns, so that the
+caller never has to pair GetRec() with RecUnlock().
+- Locks are released through defer. Hence, they're released even if the function panics,
+in which case the payload is left as is and the panic is propagated to the caller.
+**************************************************************************** */
+package datacache
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+
+// Replaces payload of pRec, accounts it in stats, raises change event and bumps version of the record. Returns the new version.
+// Must be invoked in the exclusive record lock. The lock is then to be released through unlockUnmodified().
+func (pDataCache *DataCache) replacePayloadWOLock(pRec *Rec, pDataRec interface{}) uint64 {
+	pDataCache.raiseEvent(EventUpdate, pRec.KeyList, pRec.PDataRec, pDataRec, pRec.isActive)
+	pRec.PDataRec = pDataRec
+	if pDataCache.sizefn != nil {
+		size := pDataCache.sizefn(pDataRec)
+		pDataCache.addBytes(size - pRec.size)
+		pRec.size = size
+	}
+	pDataCache.pWAL.logAdd(pRec)
+	pDataCache.pCounters.update()
+
+	return pRec.bumpVersion()
+}
+
+
+/* ****************************************************************************
+Description :
+Replaces payload of the record referred to by key with the one updateFunc returns.
+updateFunc is passed the current payload and runs in the exclusive record lock.
+
+Receiver    :
+pDataCache *DataCache: Instance of datacache.
+
+Implements  : NA
+
+Arguments   :
+1> key Key: Key to the cache record.
+2> updateFunc UpdateFunc: Returns the new payload. Payload is left as is in case it returns
+error or panics.
+
+Return value:
+1> uint64: New version of the record. 0 in case of error.
+2> error: Error returned by updateFunc, or error string in case the record isn't found, has
+expired or updateFunc returns nil payload.
+
+Additional note:
+- Method takes RD store-lock and the record lock. Store-lock is released whilst waiting for
+the record, just the way GetRec() does. Caller go-routine shouldn't invoke this method in any
+store-lock or whilst holding the record lock. It's a deadlock otherwise.
+- updateFunc shouldn't invoke any method of the datacache which takes a WR store-lock or the
+lock of the same record.
+**************************************************************************** */
+func (pDataCache *DataCache) Update(key Key, updateFunc UpdateFunc) (uint64, error) {
+	if (pDataCache == nil) || (updateFunc == nil) {
+		return 0, errors.New("NULL datacache or update function.")
+	}
+
+	defer pDataCache.dispatchEvents()  // RD store-lock is released by then.
+	pRec := pDataCache.rlockKeyAndRec(key, false)
+	defer pDataCache.runlockKey(key)
+
+	if pRec == nil {
+		return 0, errors.New(fmt.Sprintf("Key \"%v\" doesn't exist.", key))
+	}
+
+	defer pRec.unlockUnmodified()  // version is bumped only if the payload is replaced.
+
+	return pDataCache.applyUpdateWOLock(pRec, updateFunc)
+}
+
+
+// Runs updateFunc and replaces payload of pRec with the one it returns. Must be invoked in the exclusive
+// record lock.
+func (pDataCache *DataCache) applyUpdateWOLock(pRec *Rec, updateFunc UpdateFunc) (uint64, error) {
+	pDataRec, err := updateFunc(pRec.PDataRec)
+	if err != nil {
+		return 0, err
+	}
+	if pDataRec == nil {
+		return 0, errors.New("NULL payload.")
+	}
+
+	return pDataCache.replacePayloadWOLock(pRec, pDataRec), nil
+}
+
+
+/* ****************************************************************************
+Description :
+Same as Update(), however, the record is added in case none of the keys of keyList refers to
+a live record. updateFunc is passed nil in that case and the payload it returns is added as a
+new record with keys keyList and the cache-wide default TTL.
+
+Receiver    :
+pDataCache *DataCache: Instance of datacache.
+
+Implements  : NA
+
+Arguments   :
+1> keyList []Key: Keys of the record. The first key that refers to a live record decides the
+record that's updated.
+2> updateFunc UpdateFunc: Returns the new payload. Nothing changes in case it returns error
+or panics.
+
+Return value:
+1> uint64: Version of the updated or added record. 0 in case of error.
+2> error: Error returned by updateFunc, or error string in case of error.
+
+Additional note:
+- Method takes the same locks as AddRec() and runs updateFunc in them. Hence, updateFunc mustn't
+invoke any method of the datacache. It's a deadlock otherwise.
+- Keys of an existing record are left as they are. Keys of keyList which don't refer to the
+record aren't aliased to it.
+**************************************************************************** */
+func (pDataCache *DataCache) Upsert(keyList []Key, updateFunc UpdateFunc) (uint64, error) {
+	if (pDataCache == nil) || (updateFunc == nil) || (len(keyList) == 0) {
+		return 0, errors.New("NULL datacache, update function or empty key list.")
+	}
+
+	unlock := pDataCache.lockKeys(keyList)
+	defer unlock()
+
+	now := time.Now()
+	for _, key := range keyList {
+		if pRec, isOK := pDataCache.lookupWOLock(key); isOK && !pRec.isExpired(now) {
+			pDataCache.lockRec(pRec)
+			defer pRec.unlockUnmodified()  // version is bumped only if the payload is replaced.
+
+			return pDataCache.applyUpdateWOLock(pRec, updateFunc)
+		}
+	}
+
+	pDataRec, err := updateFunc(nil)
+	if err != nil {
+		return 0, err
+	}
+	if pDataRec == nil {
+		return 0, errors.New("NULL payload.")
+	}
+
+	pRec := pDataCache.newRec(keyList, pDataRec, 0)
+	pDataCache.storeRecWOLock(pRec)
+
+	return pRec.version, nil
+}
@@ -0,0 +1,181 @@
+package datacache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+
+func TestUpdate(t *testing.T) {
+	errUpdate := errors.New("update failed")
+
+	testList := []struct {
+		name string
+		key Key
+		updateFunc UpdateFunc
+		pDataRec interface{}    // payload of k afterwards.
+		isErr bool
+	}{
+		{"updated", "k", func(pDataRec interface{}) (interface{}, error) {
+			return pDataRec.(int) + 1, nil
+		}, 2, false},
+		{"updated through alias", "alias", func(pDataRec interface{}) (interface{}, error) {
+			return pDataRec.(int) + 1, nil
+		}, 2, false},
+		{"missing key", "nope", func(pDataRec interface{}) (interface{}, error) {
+			return 5, nil
+		}, 1, true},
+		{"function error", "k", func(pDataRec interface{}) (interface{}, error) {
+			return 5, errUpdate
+		}, 1, true},
+		{"nil payload", "k", func(pDataRec interface{}) (interface{}, error) {
+			return nil, nil
+		}, 1, true},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			pDataCache := Create(nil, nil, WithShards(8))
+			defer pDataCache.Close()
+			pDataCache.AddRec([]Key{"k", "alias"}, 1, true)
+			_, _, version := pDataCache.GetDataRecVersion("k")
+
+			newVersion, err := pDataCache.Update(test.key, test.updateFunc)
+			if (err != nil) != test.isErr {
+				t.Fatalf("Update() = %d, %v", newVersion, err)
+			}
+
+			_, pDataRec, tmpVersion := pDataCache.GetDataRecVersion("k")
+			if pDataRec != test.pDataRec {
+				t.Fatalf("k = %v, want %v", pDataRec, test.pDataRec)
+			}
+			if test.isErr && (tmpVersion != version) {
+				t.Fatalf("failed Update() bumped version %d to %d", version, tmpVersion)
+			}
+			if !test.isErr && (tmpVersion != newVersion) {
+				t.Fatalf("version %d, Update() returned %d", tmpVersion, newVersion)
+			}
+		})
+	}
+}
+
+
+// Panic of the update function is propagated and leaves both the payload and the record lock as they were.
+func TestUpdatePanic(t *testing.T) {
+	pDataCache := Create(nil, nil)
+	defer pDataCache.Close()
+	pDataCache.AddRec([]Key{"k"}, 1, true)
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("panic isn't propagated")
+			}
+		}()
+		pDataCache.Update("k", func(interface{}) (interface{}, error) {
+			panic("boom")
+		})
+	}()
+
+	runWithTimeout(t, time.Second, func() {
+		if _, pDataRec := pDataCache.GetDataRec("k"); pDataRec != 1 {
+			t.Errorf("k = %v after panic, want 1", pDataRec)
+		}
+		if _, err := pDataCache.TryDeleteRec("k"); err != nil {
+			t.Errorf("record is still locked after panic: %v", err)
+		}
+	})
+}
+
+
+func TestUpsert(t *testing.T) {
+	testList := []struct {
+		name string
+		keyList []Key
+		isAdded bool
+		pDataRec interface{}
+	}{
+		{"existing", []Key{"k"}, false, 2},
+		{"existing through second key", []Key{"new", "alias"}, false, 2},
+		{"added", []Key{"new", "new2"}, true, 1},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			pDataCache := Create(nil, nil, WithShards(8))
+			defer pDataCache.Close()
+			pDataCache.AddRec([]Key{"k", "alias"}, 1, true)
+
+			version, err := pDataCache.Upsert(test.keyList, func(pDataRec interface{}) (interface{}, error) {
+				if pDataRec == nil {
+					return 1, nil
+				}
+				return pDataRec.(int) + 1, nil
+			})
+			if err != nil {
+				t.Fatalf("Upsert: %v", err)
+			}
+
+			_, pDataRec, tmpVersion := pDataCache.GetDataRecVersion(test.keyList[len(test.keyList) - 1])
+			if (pDataRec != test.pDataRec) || (tmpVersion != version) {
+				t.Fatalf("%v = %v at version %d; want %v at %d", test.keyList, pDataRec, tmpVersion, test.pDataRec, version)
+			}
+			if _, cnt := pDataCache.GetCnt(); (cnt == 2) != test.isAdded {
+				t.Fatalf("%d records, added %v", cnt, test.isAdded)
+			}
+			if !test.isAdded && pDataCache.DoesKeyExist("new") {
+				t.Fatalf("key not referring to the record is aliased to it")
+			}
+		})
+	}
+}
+
+
+// Concurrent increments through Update() and Upsert() don't lose any update.
+func TestUpdateCounter(t *testing.T) {
+	testList := []struct {
+		name string
+		opts []Option
+	}{
+		{"single shard", nil},
+		{"sharded", []Option{WithShards(8)}},
+		{"copy-on-write", []Option{WithCopyOnWrite(0)}},
+	}
+
+	inc := func(pDataRec interface{}) (interface{}, error) {
+		if pDataRec == nil {
+			return 1, nil
+		}
+		return pDataRec.(int) + 1, nil
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			pDataCache := Create(nil, nil, test.opts...)
+			defer pDataCache.Close()
+
+			const goroutineCnt, incCnt = 8, 200
+			var wg sync.WaitGroup
+			for i := 0; i < goroutineCnt; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					for j := 0; j < incCnt; j++ {
+						if (i + j) % 2 == 0 {
+							pDataCache.Upsert([]Key{"cnt"}, inc)
+						} else if _, err := pDataCache.Update("cnt", inc); err != nil {
+							pDataCache.Upsert([]Key{"cnt"}, inc)  // not added yet.
+						}
+					}
+				}(i)
+			}
+			runWithTimeout(t, 30 * time.Second, wg.Wait)
+
+			if _, pDataRec := pDataCache.GetDataRec("cnt"); pDataRec != goroutineCnt * incCnt {
+				t.Fatalf("cnt = %v, want %d", pDataRec, goroutineCnt * incCnt)
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache
+Filename    : github.com/sameeroak1110/datacache/version.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Record versions and optimistic concurrency. A reader fetches the payload along with the
+version through GetDataRecVersion() and writes it back through CompareAndSwap(), which
+fails with *VersionConflictError in case the record has changed meanwhile.
+- Versions are drawn from a single sequence, so that a record which replaces another one
+under the same key, for instance, through ForceAddRec(), never repeats the version of the
+one it's replaced. The sequence is seeded with the clock, hence, versions keep increasing
+across restarts of the process too.
+- Version of a record is bumped each time its record lock taken in exclusive mode is released,
+as the holder may have updated the record. Locking in shared mode doesn't bump it.
+**************************************************************************** */
+package datacache
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+
+var recVersionSeq = uint64(time.Now().UnixNano())
+
+// Matches every *VersionConflictError through errors.Is().
+var ErrVersionConflict = errors.New("Record version conflict.")
+
+// VersionConflictError is returned when the record isn't of the expected version.
+type VersionConflictError struct {
+	Key Key
+	Expected uint64
+	Actual uint64        // 0 if key isn't associated to any record.
+}
+
+
+func (pErr *VersionConflictError) Error() string {
+	if pErr.Actual == 0 {
+		return fmt.Sprintf("Record of key %#v expected at version %d doesn't exist.", pErr.Key, pErr.Expected)
+	}
+
+	return fmt.Sprintf("Record of key %#v is at version %d, expected %d.", pErr.Key, pErr.Actual, pErr.Expected)
+}
+
+
+func (pErr *VersionConflictError) Is(target error) bool {
+	return target == ErrVersionConflict
+}
+
+
+func nextRecVersion() uint64 {
+	return atomic.AddUint64(&recVersionSeq, 1)
+}
+
+
+// Returns version of the record. Caller should hold the record lock. The version is the one the record
+// has been locked at; it's bumped once the record lock taken in exclusive mode is released.
+func (pRec *Rec) Version() uint64 {
+	if pRec == nil {
+		return 0
+	}
+
+	return pRec.version
+}
+
+
+/* ****************************************************************************
+Description :
+Same as GetDataRec(). Additionally, returns version of the record.
+
+Receiver    :
+pDataCache *DataCache: Instance of datacache.
+
+Implements  : NA
+
+Arguments   :
+1> key Key: Key to fetch cache record.
+
+Return value:
+1> bool: true if successful. false if record isn't found or has expired.
+2> interface{}: Payload of fetched datacache record. nil in case of error.
+3> uint64: Version of the record. 0 in case of error.
+
+Additional note:
+- Same locking rules as GetDataRec(). Caller go-routine shouldn't invoke this method in
+any store-lock.
+**************************************************************************** */
+func (pDataCache *DataCache) GetDataRecVersion(key Key) (bool, interface{}, uint64) {
+	if pDataCache == nil {
+		return false, nil, 0
+	}
+
+	if pDataCache.pCOW != nil {
+		pCOWRec, pState := pDataCache.lookupCOW(key)
+		if pState == nil {
+			pDataCache.pCounters.miss()
+			return false, nil, 0
+		}
+
+		pDataCache.pCounters.hit()
+		if pDataCache.policy != nil {
+			pDataCache.policy.OnHit(pCOWRec.pRec)
+		}
+		return true, pState.pDataRec, pState.version
+	}
+
+	pRec := pDataCache.rlockKeyAndRec(key, true)
+	defer pDataCache.runlockKey(key)
+
+	if pRec == nil {
+		pDataCache.pCounters.miss()
+		return false, nil, 0
+	}
+
+	pDataRec, version := pRec.PDataRec, pRec.version
+	pRec.runlock()
+	pDataCache.pCounters.hit()
+	if pDataCache.policy != nil {
+		pDataCache.policy.OnHit(pRec)
+	}
+
+	return true, pDataRec, version
+}
+
+
+/* ****************************************************************************
+Description :
+Replaces payload of the record referred to by key, provided the record is still at
+expectedVersion.
+
+Receiver    :
+pDataCache *DataCache: Instance of datacache.
+
+Implements  : NA
+
+Arguments   :
+1> key Key: Key to the cache record.
+2> expectedVersion uint64: Version the record has been read at, for instance, through
+GetDataRecVersion().
+3> pDataRec interface{}: New payload.
+
+Return value:
+1> uint64: New version of the record. 0 in case of error.
+2> error: *VersionConflictError (matches ErrVersionConflict) in case the record isn't at
+expectedVersion or doesn't exist any longer.
+
+Additional note:
+- Method takes RD store-lock and the record lock. Store-lock is released whilst waiting for
+the record, just the way GetRec() does. Caller go-routine shouldn't invoke this method in any
+store-lock or whilst holding the record lock. It's a deadlock otherwise.
+**************************************************************************** */
+func (pDataCache *DataCache) CompareAndSwap(key Key, expectedVersion uint64, pDataRec interface{}) (uint64, error) {
+	if (pDataCache == nil) || (pDataRec == nil) {
+		return 0, errors.New("NULL datacache or payload.")
+	}
+
+	defer pDataCache.dispatchEvents()  // RD store-lock is released by then.
+	pRec := pDataCache.rlockKeyAndRec(key, false)
+	defer pDataCache.runlockKey(key)
+
+	if pRec == nil {
+		return 0, &VersionConflictError { Key: key, Expected: expectedVersion }
+	}
+
+	if pRec.version != expectedVersion {
+		err := &VersionConflictError { Key: key, Expected: expectedVersion, Actual: pRec.version }
+		pRec.unlockUnmodified()
+		return 0, err
+	}
+
+	version := pDataCache.replacePayloadWOLock(pRec, pDataRec)
+	pRec.unlockUnmodified()  // version is already bumped.
+
+	return version, nil
+}
+
+
+/* ****************************************************************************
+Description :
+Deletes the record referred to by key, provided the record is still at expectedVersion.
+All keys of the record are disassociated.
+
+Receiver    :
+pDataCache *DataCache: Instance of datacache.
+
+Implements  : NA
+
+Arguments   :
+1> key Key: Key to the cache record.
+2> expectedVersion uint64: Version the record has been read at.
+
+Return value:
+1> int: Number of records remained in the cache. -1 in case of error.
+2> error: *VersionConflictError (matches ErrVersionConflict) in case the record isn't at
+expectedVersion or doesn't exist any longer.
+
+Additional note:
+- Same locking rules as DeleteRec(). Caller go-routine shouldn't invoke this method in any
+store-lock.
+**************************************************************************** */
+func (pDataCache *DataCache) CompareAndDelete(key Key, expectedVersion uint64) (int, error) {
+	if pDataCache == nil {
+		return -1, errors.New("Nil datacache")
+	}
+
+	unlock := pDataCache.lockKeys([]Key{key})
+	defer unlock()
+
+	pRec, isOK := pDataCache.lookupWOLock(key)
+	if !isOK || pRec.isExpired(time.Now()) {
+		return -1, &VersionConflictError { Key: key, Expected: expectedVersion }
+	}
+
+	pDataCache.lockRec(pRec)
+	if pRec.version != expectedVersion {
+		err := &VersionConflictError { Key: key, Expected: expectedVersion, Actual: pRec.version }
+		pRec.unlockUnmodified()
+		return -1, err
+	}
+
+	pDataCache.removeRecWOLock(pRec, EventDelete)  // all keys disassociated.
+	pDataCache.pCounters.delete(1)
+	pRec.unlock()
+
+	return pDataCache.recCnt(), nil
+}
@@ -0,0 +1,151 @@
+package datacache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+
+func TestCompareAndSwap(t *testing.T) {
+	testList := []struct {
+		name string
+		isStale bool      // CAS against the version read before some other write.
+		key Key
+		pDataRec interface{}
+		isConflict bool
+		isErr bool
+	}{
+		{"current version", false, "k", "v2", false, false},
+		{"alias", false, "alias", "v2", false, false},
+		{"stale version", true, "k", "v2", true, true},
+		{"missing key", false, "nope", "v2", true, true},
+		{"nil payload", false, "k", nil, false, true},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			pDataCache := Create(nil, nil, WithShards(8))
+			defer pDataCache.Close()
+			pDataCache.AddRec([]Key{"k", "alias"}, "v1", true)
+
+			_, _, version := pDataCache.GetDataRecVersion("k")
+			if test.isStale {
+				pDataCache.Update("k", func(interface{}) (interface{}, error) {
+					return "other", nil
+				})
+			}
+
+			newVersion, err := pDataCache.CompareAndSwap(test.key, version, test.pDataRec)
+			if ((err != nil) != test.isErr) || (errors.Is(err, ErrVersionConflict) != test.isConflict) {
+				t.Fatalf("CompareAndSwap() = %d, %v", newVersion, err)
+			}
+			if err != nil {
+				return
+			}
+
+			_, pDataRec, tmpVersion := pDataCache.GetDataRecVersion("k")
+			if (pDataRec != test.pDataRec) || (tmpVersion != newVersion) || (newVersion == version) {
+				t.Fatalf("k = %v at version %d; want %v at new version %d", pDataRec, tmpVersion, test.pDataRec, newVersion)
+			}
+		})
+	}
+}
+
+
+// Version changes with every exclusive record lock released, not just with the writes of the version API.
+func TestVersionBumpedByWrites(t *testing.T) {
+	testList := []struct {
+		name string
+		write func(*DataCache)
+	}{
+		{"GetRec", func(pDataCache *DataCache) {
+			_, pRec := pDataCache.GetRec("k")
+			pRec.DataCacheRecUnlock()
+		}},
+		{"UpdateRecState", func(pDataCache *DataCache) {
+			pDataCache.UpdateRecState("k", false)
+		}},
+		{"ForceAddRec", func(pDataCache *DataCache) {
+			pDataCache.ForceAddRec([]Key{"k"}, "v2")
+		}},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			pDataCache := Create(nil, nil)
+			defer pDataCache.Close()
+			pDataCache.AddRec([]Key{"k"}, "v1", true)
+
+			_, _, version := pDataCache.GetDataRecVersion("k")
+			test.write(pDataCache)
+			if _, err := pDataCache.CompareAndSwap("k", version, "v3"); !errors.Is(err, ErrVersionConflict) {
+				t.Fatalf("CompareAndSwap() after %s = %v, want ErrVersionConflict", test.name, err)
+			}
+		})
+	}
+}
+
+
+func TestCompareAndDelete(t *testing.T) {
+	pDataCache := Create(nil, nil)
+	defer pDataCache.Close()
+	pDataCache.AddRec([]Key{"k", "alias"}, "v1", true)
+	pDataCache.AddRec([]Key{"other"}, "v1", true)
+	_, _, version := pDataCache.GetDataRecVersion("k")
+
+	if _, err := pDataCache.CompareAndDelete("k", version + 1); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("CompareAndDelete() of wrong version = %v", err)
+	}
+	if cnt, err := pDataCache.CompareAndDelete("alias", version); (err != nil) || (cnt != 1) {
+		t.Fatalf("CompareAndDelete() = %d, %v", cnt, err)
+	}
+	if pDataCache.DoesKeyExist("k") {
+		t.Fatalf("k still exists")
+	}
+	if _, err := pDataCache.CompareAndDelete("k", version); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("CompareAndDelete() of deleted record = %v", err)
+	}
+}
+
+
+// Concurrent read-CAS-retry increments don't lose any update.
+func TestCompareAndSwapCounter(t *testing.T) {
+	testList := []struct {
+		name string
+		opts []Option
+	}{
+		{"single shard", nil},
+		{"sharded", []Option{WithShards(8)}},
+		{"copy-on-write", []Option{WithCopyOnWrite(0)}},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			pDataCache := Create(nil, nil, test.opts...)
+			defer pDataCache.Close()
+			pDataCache.AddRec([]Key{"cnt"}, 0, true)
+
+			const goroutineCnt, incCnt = 8, 200
+			var wg sync.WaitGroup
+			for i := 0; i < goroutineCnt; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < incCnt; {
+						_, pDataRec, version := pDataCache.GetDataRecVersion("cnt")
+						if _, err := pDataCache.CompareAndSwap("cnt", version, pDataRec.(int) + 1); err == nil {
+							j++
+						}
+					}
+				}()
+			}
+			runWithTimeout(t, 30 * time.Second, wg.Wait)
+
+			if _, pDataRec := pDataCache.GetDataRec("cnt"); pDataRec != goroutineCnt * incCnt {
+				t.Fatalf("cnt = %v, want %d", pDataRec, goroutineCnt * incCnt)
+			}
+		})
+	}
+}
@@ -0,0 +1,596 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache
+Filename    : github.com/sameeroak1110/datacache/wal.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Optional append-only write-ahead log (WAL) for crash-consistent durability.
+- Each mutation of the cache store, i.e., a record added (AddRec() and the like),
+an alias added (ReAddRec()), a record removed (DeleteRec(), eviction, expiry), a
+key disassociated (DeleteKey()), a state change (UpdateRecState()) and the cache
+cleared (DeleteCache()), is appended to the log with a sequence number. Changes
+made to a payload in place, through a locked record, aren't logged.
+- OpenWAL() replays the existing log into the cache, then compacts it into a full
+dump of the store and appends further mutations to it. Log is compacted again
+once it crosses a size threshold, and after each Reload().
+- Each mutation is logged whilst the store-lock which guards it is still held.
+Hence, the order of the log is the order of mutations.
+- With JSONCodec, a mutation involving a non-string key isn't logged, as the key
+wouldn't match once replayed. See codec.go.
+**************************************************************************** */
+package datacache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+
+type FsyncPolicy int
+
+const (
+	FsyncAlways FsyncPolicy = iota   // log is fsync'ed after each mutation.
+	FsyncInterval                    // log is fsync'ed periodically.
+	FsyncNever                       // flushing to disk is left to the OS.
+)
+
+const (
+	defaultWALFsyncInterval = time.Second
+	defaultWALCompactThreshold = 64 << 20
+	minWALCompactBackoff = 10 * time.Millisecond
+	maxWALCompactBackoff = 5 * time.Second
+)
+
+// Background compaction gave up as some record is locked by its user.
+var errWALRecBusy = errors.New("Record is locked; WAL compaction is deferred.")
+
+type WALOptions struct {
+	Path string                    // path of the log file.
+	Fsync FsyncPolicy
+	FsyncInterval time.Duration    // used with FsyncInterval. default is a second.
+	CompactThreshold int64         // log is compacted once it grows beyond these many bytes. default is 64 MiB.
+}
+
+type walOp uint8
+
+const (
+	walOpAdd walOp = iota + 1      // KeyList, PDataRec, IsActive, ExpiresAt.
+	walOpAlias                     // Key, NewKey.
+	walOpDeleteRec                 // Key.
+	walOpDeleteKey                 // Key.
+	walOpState                     // Key, IsActive.
+	walOpClear
+)
+
+type walEntry struct {
+	Seq uint64
+	Op walOp
+	KeyList []Key
+	Key Key
+	NewKey Key
+	PDataRec interface{}
+	IsActive bool
+	ExpiresAt time.Time
+}
+
+// counts bytes written to the log.
+type countingWriter struct {
+	w io.Writer
+	cnt int64
+}
+
+type wal struct {
+	lock sync.Mutex
+	opts WALOptions
+	codec Codec
+	pFile *os.File
+	pBufWriter *bufio.Writer
+	pCountingWriter *countingWriter
+	pEncoder Encoder
+	seq uint64                     // sequence number of the last logged mutation.
+	isDirty bool                   // true if there're mutations which haven't been fsync'ed.
+	compactChan chan struct{}      // compaction requests to the compactor go-routine.
+	pStopChan chan struct{}
+}
+
+
+func (pCountingWriter *countingWriter) Write(p []byte) (int, error) {
+	n, err := pCountingWriter.w.Write(p)
+	pCountingWriter.cnt = pCountingWriter.cnt + int64(n)
+	return n, err
+}
+
+
+// Appends an entry to the log. Log is fsync'ed as per the fsync policy and compaction is requested
+// once the log crosses its size threshold.
+func (pWAL *wal) append(entry *walEntry) {
+	if pWAL == nil {
+		return
+	}
+
+	pWAL.lock.Lock()
+	defer pWAL.lock.Unlock()
+
+	if pWAL.pEncoder == nil {  // log has been closed.
+		return
+	}
+
+	if err := checkWALKeys(pWAL.codec, entry); err != nil {
+		fmt.Println("Failed to write WAL entry:", err.Error())
+		return
+	}
+
+	pWAL.seq = pWAL.seq + 1
+	entry.Seq = pWAL.seq
+	err := pWAL.pEncoder.Encode(entry)
+	if err == nil {
+		err = pWAL.pBufWriter.Flush()
+	}
+	if (err == nil) && (pWAL.opts.Fsync == FsyncAlways) {
+		err = pWAL.pFile.Sync()
+	}
+	if err != nil {
+		fmt.Println("Failed to write WAL entry:", err.Error())
+		return
+	}
+	pWAL.isDirty = true
+
+	if pWAL.pCountingWriter.cnt > pWAL.opts.CompactThreshold {
+		select {
+		case pWAL.compactChan <- struct{}{}:
+		default:  // compaction already requested.
+		}
+	}
+}
+
+
+// Returns error in case codec can't round-trip some key of the entry. See checkCodecKeys().
+func checkWALKeys(codec Codec, entry *walEntry) error {
+	keyList := entry.KeyList
+	if entry.Key != nil {
+		keyList = append([]Key{entry.Key}, keyList...)
+	}
+	if entry.NewKey != nil {
+		keyList = append(keyList, entry.NewKey)
+	}
+
+	return checkCodecKeys(codec, keyList)
+}
+
+
+func (pWAL *wal) logAdd(pRec *Rec) {
+	if pWAL != nil {
+		pWAL.append(&walEntry {
+			Op: walOpAdd,
+			KeyList: pRec.KeyList,
+			PDataRec: pRec.PDataRec,
+			IsActive: pRec.isActive,
+			ExpiresAt: pRec.expiresAt,
+		})
+	}
+}
+
+
+func (pWAL *wal) logKeyOp(op walOp, key Key, newKey Key) {
+	if pWAL != nil {
+		pWAL.append(&walEntry {
+			Op: op,
+			Key: key,
+			NewKey: newKey,
+		})
+	}
+}
+
+
+func (pWAL *wal) logState(key Key, recState bool) {
+	if pWAL != nil {
+		pWAL.append(&walEntry {
+			Op: walOpState,
+			Key: key,
+			IsActive: recState,
+		})
+	}
+}
+
+
+func (pWAL *wal) logClear() {
+	if pWAL != nil {
+		pWAL.append(&walEntry {
+			Op: walOpClear,
+		})
+	}
+}
+
+
+// fsync's the log if there're mutations which haven't been fsync'ed.
+func (pWAL *wal) sync() {
+	pWAL.lock.Lock()
+	defer pWAL.lock.Unlock()
+
+	if (pWAL.pFile != nil) && pWAL.isDirty {
+		if err := pWAL.pFile.Sync(); err != nil {
+			fmt.Println("Failed to fsync WAL:", err.Error())
+			return
+		}
+		pWAL.isDirty = false
+	}
+}
+
+
+// Flushes and closes the log. Further mutations aren't logged.
+func (pWAL *wal) close() error {
+	pWAL.lock.Lock()
+	defer pWAL.lock.Unlock()
+
+	if pWAL.pFile == nil {
+		return nil
+	}
+
+	close(pWAL.pStopChan)
+	err := pWAL.pBufWriter.Flush()
+	if err == nil {
+		err = pWAL.pFile.Sync()
+	}
+	if err1 := pWAL.pFile.Close(); err == nil {
+		err = err1
+	}
+	pWAL.pFile = nil
+	pWAL.pEncoder = nil
+
+	return err
+}
+
+
+/* ****************************************************************************
+Description :
+Replays the write-ahead log at opts.Path, if it exists, into the cache and enables
+logging of every further mutation.
+
+Receiver    :
+pDataCache *DataCache: Instance of datacache.
+
+Implements  : NA
+
+Arguments   :
+1> opts WALOptions: Path of the log, fsync policy and compaction threshold.
+
+Return value:
+1> int: Number of log entries replayed.
+2> error: Returns cause of error.
+
+Additional note:
+- Can be invoked after Load(), LoadSnapshot() or on its own, typically during server start-up.
+Replayed mutations are applied over whatever the cache holds.
+- A torn entry at the tail of the log, typically due to a crash whilst it was being written,
+ends the replay. Entries before it are retained.
+- Once replayed, the log is compacted into a full dump of the store.
+- Method takes WR store-lock and releases the same. Caller go-routine shouldn't invoke this
+method in any store-lock or record lock. It's a deadlock otherwise. Compaction herein waits for
+records locked by other go-routines, whereas later compactions, triggered by the size threshold,
+are deferred until no record is locked.
+- The codec of the cache (WithCodec()) is used to encode log entries.
+- With JSONCodec, every key must be a string. Error is returned in case the cache holds a record
+with a non-string key. A mutation involving a non-string key made afterwards isn't logged.
+**************************************************************************** */
+func (pDataCache *DataCache) OpenWAL(opts WALOptions) (int, error) {
+	if pDataCache == nil {
+		return -1, errors.New("Nil datacache.")
+	}
+
+	if opts.Path == "" {
+		return -1, errors.New("Empty WAL path.")
+	}
+	if opts.FsyncInterval <= 0 {
+		opts.FsyncInterval = defaultWALFsyncInterval
+	}
+	if opts.CompactThreshold <= 0 {
+		opts.CompactThreshold = defaultWALCompactThreshold
+	}
+
+	pDataCache.cacheLock.Lock()
+	defer pDataCache.unlockStore()
+
+	if pDataCache.pWAL != nil {
+		return -1, errors.New("WAL is already open.")
+	}
+
+	cnt, lastSeq, err := pDataCache.replayWALWOLock(opts.Path)
+	if err != nil {
+		return -1, err
+	}
+
+	pWAL := &wal {
+		opts: opts,
+		codec: pDataCache.codec,
+		seq: lastSeq,
+		compactChan: make(chan struct{}, 1),
+		pStopChan: make(chan struct{}),
+	}
+	pDataCache.pWAL = pWAL
+	if err = pDataCache.compactWALWOLock(false); err != nil {
+		pDataCache.pWAL = nil
+		return -1, err
+	}
+
+	go pDataCache.walCompactor(pWAL)
+	if opts.Fsync == FsyncInterval {
+		go pWAL.syncer()
+	}
+
+	return cnt, nil
+}
+
+
+// Closes the write-ahead log, if it's open. Close() closes it as well.
+func (pDataCache *DataCache) CloseWAL() error {
+	if pDataCache == nil {
+		return errors.New("Nil datacache.")
+	}
+
+	pDataCache.cacheLock.Lock()
+	defer pDataCache.unlockStore()
+
+	if pDataCache.pWAL == nil {
+		return nil
+	}
+
+	err := pDataCache.pWAL.close()
+	pDataCache.pWAL = nil
+
+	return err
+}
+
+
+// Replays the log at path. Returns number of replayed entries and sequence number of the last one.
+// Must be invoked in WR store-lock whilst logging is disabled.
+func (pDataCache *DataCache) replayWALWOLock(path string) (int, uint64, error) {
+	pFile, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, 0, nil
+		}
+		return -1, 0, errors.New(fmt.Sprintf("Failed to open WAL: %s", err.Error()))
+	}
+	defer pFile.Close()
+
+	now := time.Now()
+	cnt := 0
+	var lastSeq uint64
+	pDecoder := pDataCache.codec.NewDecoder(bufio.NewReader(pFile))
+	for {
+		entry := walEntry {
+			PDataRec: pDataCache.NewPayload(),
+		}
+		if err = pDecoder.Decode(&entry); err != nil {
+			if err != io.EOF {
+				fmt.Println(pDataCache.logTag(), "WAL replay stopped at a torn or corrupt entry:", err.Error())
+			}
+			break
+		}
+
+		if entry.Seq <= lastSeq {
+			fmt.Printf("%s WAL replay stopped at out of sequence entry %d.\n", pDataCache.logTag(), entry.Seq)
+			break
+		}
+		lastSeq = entry.Seq
+		cnt = cnt + 1
+
+		pRec, isOK := pDataCache.lookupWOLock(entry.Key)
+		switch entry.Op {
+		case walOpAdd:
+			if (len(entry.KeyList) == 0) || (entry.PDataRec == nil) {
+				continue
+			}
+
+			// an expired record is added nonetheless, as it had replaced whatever its keys referred to,
+			// and then removed.
+			ttl := NoTTL
+			isExpired := false
+			if !entry.ExpiresAt.IsZero() {
+				if ttl = entry.ExpiresAt.Sub(now); ttl <= 0 {
+					ttl = NoTTL
+					isExpired = true
+				}
+			}
+			pRec = pDataCache.newRec(entry.KeyList, entry.PDataRec, ttl)
+			pRec.isActive = entry.IsActive
+			pDataCache.storeRecWOLock(pRec)
+			if isExpired {
+				pDataCache.removeRecWOLock(pRec, EventExpire)
+			}
+
+		case walOpAlias:
+			if isOK {
+				pDataCache.aliasRecWOLock(pRec, entry.NewKey)
+			}
+
+		case walOpDeleteRec:
+			if isOK {
+				pDataCache.removeRecWOLock(pRec, EventDelete)
+			}
+
+		case walOpDeleteKey:
+			if isOK {
+				pDataCache.detachKeyWOLock(pRec, entry.Key, EventDelete)
+			}
+
+		case walOpState:
+			if isOK {
+				pRec.isActive = entry.IsActive
+				pRec.publishCOW()
+			}
+
+		case walOpClear:
+			pDataCache.shards = newShards(len(pDataCache.shards))
+			pDataCache.resetCntWOLock()
+			pDataCache.resetCOWWOLock()
+		}
+	}
+
+	return cnt, lastSeq, nil
+}
+
+
+// Rewrites the log as a full dump of the store, i.e., a single add entry per record, and switches to it.
+// If isTry is true, compaction gives up with errWALRecBusy, rather than waiting, in case some record is
+// locked. The log isn't switched in that case.
+// Must be invoked in WR store-lock.
+func (pDataCache *DataCache) compactWALWOLock(isTry bool) error {
+	pWAL := pDataCache.pWAL
+	if pWAL == nil {
+		return nil
+	}
+
+	pWAL.lock.Lock()
+	defer pWAL.lock.Unlock()
+
+	tmpPath := pWAL.opts.Path + ".compact"
+	pFile, err := os.OpenFile(tmpPath, os.O_CREATE | os.O_TRUNC | os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Failed to create compacted WAL: %s", err.Error()))
+	}
+
+	pBufWriter := bufio.NewWriter(pFile)
+	pCountingWriter := &countingWriter {
+		w: pBufWriter,
+	}
+	pEncoder := pWAL.codec.NewEncoder(pCountingWriter)
+
+	startSeq := pWAL.seq
+	now := time.Now()
+	for _, pRec := range pDataCache.uniqueRecs() {
+		if pRec.isExpired(now) {
+			continue
+		}
+
+		if err = checkCodecKeys(pWAL.codec, pRec.KeyList); err != nil {
+			pFile.Close()
+			os.Remove(tmpPath)
+			pWAL.seq = startSeq
+			return err
+		}
+
+		if !isTry {
+			pRec.rlock()
+		} else if !pRec.tryRLock() {
+			pFile.Close()
+			os.Remove(tmpPath)
+			pWAL.seq = startSeq
+			return errWALRecBusy
+		}
+		entry := walEntry {
+			Seq: pWAL.seq + 1,
+			Op: walOpAdd,
+			KeyList: pRec.KeyList,
+			PDataRec: pRec.PDataRec,
+			IsActive: pRec.isActive,
+			ExpiresAt: pRec.expiresAt,
+		}
+		err = pEncoder.Encode(&entry)
+		pRec.runlock()
+
+		if err != nil {
+			pFile.Close()
+			os.Remove(tmpPath)
+			pWAL.seq = startSeq
+			return errors.New(fmt.Sprintf("Failed to write compacted WAL: %s", err.Error()))
+		}
+		pWAL.seq = entry.Seq
+	}
+
+	if err = pBufWriter.Flush(); err == nil {
+		err = pFile.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, pWAL.opts.Path)
+	}
+	if err != nil {
+		pFile.Close()
+		os.Remove(tmpPath)
+		pWAL.seq = startSeq
+		return errors.New(fmt.Sprintf("Failed to switch to compacted WAL: %s", err.Error()))
+	}
+
+	if pWAL.pFile != nil {
+		pWAL.pBufWriter.Flush()
+		pWAL.pFile.Close()
+	}
+	pWAL.pFile = pFile
+	pWAL.pBufWriter = pBufWriter
+	pWAL.pCountingWriter = pCountingWriter
+	pWAL.pEncoder = pEncoder
+	pWAL.isDirty = false
+
+	return nil
+}
+
+
+// Compactor go-routine. Compacts the log whenever it crosses its size threshold. It doesn't wait for
+// records locked by their users whilst holding the WR store-lock. Compaction is rather retried, with
+// backoff, once the store-lock is released.
+func (pDataCache *DataCache) walCompactor(pWAL *wal) {
+	for {
+		select {
+		case <-pWAL.pStopChan:
+			return
+
+		case <-pWAL.compactChan:
+			backoff := minWALCompactBackoff
+			for {
+				var err error
+				pDataCache.cacheLock.Lock()
+				if pDataCache.pWAL == pWAL {
+					err = pDataCache.compactWALWOLock(true)
+				}
+				pDataCache.unlockStore()
+
+				if err != errWALRecBusy {
+					if err != nil {
+						fmt.Println(pDataCache.logTag(), "WAL compaction failed:", err.Error())
+					}
+					break
+				}
+
+				select {
+				case <-pWAL.pStopChan:
+					return
+				case <-time.After(backoff):
+				}
+				if backoff = 2 * backoff; backoff > maxWALCompactBackoff {
+					backoff = maxWALCompactBackoff
+				}
+			}
+		}
+	}
+}
+
+
+// Syncer go-routine. fsync's the log periodically.
+func (pWAL *wal) syncer() {
+	ticker := time.NewTicker(pWAL.opts.FsyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-pWAL.pStopChan:
+			return
+
+		case <-ticker.C:
+			pWAL.sync()
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package datacache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+
+// Opens WAL of a fresh cache at path. Fsync is left to the OS, as the process doesn't crash in the tests.
+func openTestWAL(t *testing.T, path string, compactThreshold int64) *DataCache {
+	t.Helper()
+
+	pDataCache := Create(nil, nil)
+	if _, err := pDataCache.OpenWAL(WALOptions { Path: path, Fsync: FsyncNever, CompactThreshold: compactThreshold }); err != nil {
+		t.Fatalf("OpenWAL: %v", err)
+	}
+
+	return pDataCache
+}
+
+
+func TestWALReplay(t *testing.T) {
+	testList := []struct {
+		name string
+		mutate func(*DataCache)
+		wantMap map[Key]interface{}  // nil payload means the key mustn't exist.
+	}{
+		{"add and alias", func(pDataCache *DataCache) {
+			pDataCache.AddRec([]Key{"a"}, "a1", true)
+			pDataCache.ReAddRec("a", "a2")
+		}, map[Key]interface{} {"a": "a1", "a2": "a1"}},
+
+		{"replace and update", func(pDataCache *DataCache) {
+			pDataCache.AddRec([]Key{"a"}, "a1", true)
+			pDataCache.ForceAddRec([]Key{"a"}, "a2")
+			pDataCache.Update("a", func(interface{}) (interface{}, error) { return "a3", nil })
+		}, map[Key]interface{} {"a": "a3"}},
+
+		{"delete key and record", func(pDataCache *DataCache) {
+			pDataCache.AddRec([]Key{"a", "a2"}, "a1", true)
+			pDataCache.AddRec([]Key{"b"}, "b1", true)
+			pDataCache.DeleteKey("a2")
+			pDataCache.DeleteRec("b")
+		}, map[Key]interface{} {"a": "a1", "a2": nil, "b": nil}},
+
+		{"clear", func(pDataCache *DataCache) {
+			pDataCache.AddRec([]Key{"a"}, "a1", true)
+			pDataCache.DeleteCache()
+			pDataCache.AddRec([]Key{"b"}, "b1", true)
+		}, map[Key]interface{} {"a": nil, "b": "b1"}},
+
+		{"expired", func(pDataCache *DataCache) {
+			pDataCache.AddRecWithTTL([]Key{"a"}, "a1", true, time.Millisecond)
+			pDataCache.AddRecWithTTL([]Key{"b"}, "b1", true, time.Hour)
+			time.Sleep(5 * time.Millisecond)
+		}, map[Key]interface{} {"a": nil, "b": "b1"}},
+
+		{"expired overwrite", func(pDataCache *DataCache) {
+			pDataCache.ForceAddRec([]Key{"a"}, "a1")
+			pDataCache.ForceAddRecWithTTL([]Key{"a"}, "a2", time.Millisecond)
+			time.Sleep(5 * time.Millisecond)
+		}, map[Key]interface{} {"a": nil}},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "wal")
+
+			pDataCache := openTestWAL(t, path, 0)
+			test.mutate(pDataCache)
+			pDataCache.Close()
+
+			pDataCache = openTestWAL(t, path, 0)
+			defer pDataCache.Close()
+			for key, pWantDataRec := range test.wantMap {
+				isOK, pDataRec := pDataCache.GetDataRec(key)
+				if (pWantDataRec == nil) == isOK || (isOK && (pDataRec != pWantDataRec)) {
+					t.Fatalf("GetDataRec(%v) = %v, %v after replay; want %v", key, isOK, pDataRec, pWantDataRec)
+				}
+			}
+		})
+	}
+}
+
+
+func TestWALStateReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal")
+	pDataCache := openTestWAL(t, path, 0)
+	pDataCache.AddRec([]Key{"a"}, "a1", true)
+	pDataCache.UpdateRecState("a", false)
+	pDataCache.Close()
+
+	pDataCache = openTestWAL(t, path, 0)
+	defer pDataCache.Close()
+	isOK, pRec := pDataCache.GetRec("a")
+	if !isOK {
+		t.Fatalf("GetRec(a) failed after replay")
+	}
+	defer pRec.DataCacheRecUnlock()
+	if pRec.IsActive() {
+		t.Fatalf("record is active after replay of its deactivation")
+	}
+}
+
+
+// Log is rewritten as a dump of the store once it crosses the threshold. Replay of the compacted log
+// yields the same contents.
+func TestWALCompaction(t *testing.T) {
+	const compactThreshold = 4 << 10
+
+	path := filepath.Join(t.TempDir(), "wal")
+	pDataCache := openTestWAL(t, path, compactThreshold)
+	for i := 0; i < 2000; i++ {
+		pDataCache.ForceAddRec([]Key{i % 10}, i)
+	}
+	time.Sleep(50 * time.Millisecond)  // compactor catches up.
+	pDataCache.Close()
+
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if fileInfo.Size() > 2 * compactThreshold {
+		t.Fatalf("WAL is %d bytes, threshold %d; not compacted", fileInfo.Size(), compactThreshold)
+	}
+
+	pDataCache = openTestWAL(t, path, compactThreshold)
+	defer pDataCache.Close()
+	for i := 0; i < 10; i++ {
+		if _, pDataRec := pDataCache.GetDataRec(i); pDataRec != 1990 + i {
+			t.Fatalf("GetDataRec(%d) = %v after replay, want %d", i, pDataRec, 1990 + i)
+		}
+	}
+}
+
+
+// Torn entry at the tail ends the replay; entries before it are retained.
+func TestWALTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal")
+	pDataCache := openTestWAL(t, path, 0)
+	pDataCache.AddRec([]Key{"a"}, "a1", true)
+	pDataCache.AddRec([]Key{"b"}, "b1", true)
+	pDataCache.Close()
+
+	pFile, err := os.OpenFile(path, os.O_APPEND | os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	pFile.Write([]byte{0x42, 0xff, 0x00})
+	pFile.Close()
+
+	pDataCache = openTestWAL(t, path, 0)
+	defer pDataCache.Close()
+	if !pDataCache.DoesKeyExist("a") || !pDataCache.DoesKeyExist("b") {
+		t.Fatalf("entries before the torn tail are lost")
+	}
+}
+
+
+// With JSONCodec, a cache holding a non-string key can't open the WAL, and mutations involving one
+// aren't logged.
+func TestWALJSONNonStringKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal")
+
+	pDataCache := Create(nil, nil, WithCodec(JSONCodec))
+	pDataCache.AddRec([]Key{1}, "a1", true)
+	if _, err := pDataCache.OpenWAL(WALOptions { Path: path, Fsync: FsyncNever }); err == nil {
+		t.Fatalf("OpenWAL succeeded with JSONCodec and a non-string key")
+	}
+	pDataCache.Close()
+
+	pDataCache = Create(nil, nil, WithCodec(JSONCodec))
+	if _, err := pDataCache.OpenWAL(WALOptions { Path: path, Fsync: FsyncNever }); err != nil {
+		t.Fatalf("OpenWAL: %v", err)
+	}
+	pDataCache.AddRec([]Key{"a"}, "a1", true)
+	pDataCache.AddRec([]Key{2}, "b1", true)
+	pDataCache.Close()
+
+	pDataCache = Create(nil, nil, WithCodec(JSONCodec))
+	defer pDataCache.Close()
+	if cnt, err := pDataCache.OpenWAL(WALOptions { Path: path, Fsync: FsyncNever }); (err != nil) || (cnt != 1) {
+		t.Fatalf("OpenWAL = %d, %v; want 1 entry replayed", cnt, err)
+	}
+	if !pDataCache.DoesKeyExist("a") {
+		t.Fatalf("record with a string key is lost")
+	}
+}
+
+
+// Compaction triggered by the size threshold doesn't wait for a record held by its user whilst holding
+// the WR store-lock. It's deferred until the record is released.
+func TestWALCompactionLockedRec(t *testing.T) {
+	const compactThreshold = 4 << 10
+
+	path := filepath.Join(t.TempDir(), "wal")
+	pDataCache := openTestWAL(t, path, compactThreshold)
+	defer pDataCache.Close()
+
+	pDataCache.AddRec([]Key{"held"}, "v", true)
+	_, pRec := pDataCache.GetRec("held")
+	for i := 0; i < 2000; i++ {
+		pDataCache.ForceAddRec([]Key{i % 10}, i)
+	}
+	time.Sleep(50 * time.Millisecond)  // compaction has been triggered by now.
+
+	runWithTimeout(t, time.Second, func() {
+		pDataCache.DoesKeyExist(1)
+		pDataCache.ForceAddRec([]Key{1}, 1)
+	})
+	pRec.DataCacheRecUnlock()
+
+	runWithTimeout(t, 5 * time.Second, func() {
+		for {
+			if fileInfo, err := os.Stat(path); (err == nil) && (fileInfo.Size() <= 2 * compactThreshold) {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
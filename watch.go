@@ -0,0 +1,338 @@
+/* ****************************************************************************
+Copyright (c) 2022-2030, sameeroak1110 (sameeroak1110@gmail.com)
+All rights reserved.
+BSD 3-Clause License.
+
+Package     : github.com/sameeroak1110/datacache
+Filename    : github.com/sameeroak1110/datacache/watch.go
+File-type   : golang source code file
+
+Compiler/Runtime: go version go1.18 linux/amd64
+
+Version History
+Version     : 1.0.0
+Author      : sameer oak (sameeroak1110@gmail.com)
+Description :
+- Change-event subscriptions. Watch() returns a channel of events for records added,
+replaced, aliased, updated, activated/deactivated, deleted, evicted and expired.
+- Events are raised whilst the cache is mutated, i.e., in store-locks, however, they're only
+queued there. They're delivered once the write method has released its locks, so that a slow
+subscriber never holds up a store-lock. Each write delivers the events it has raised itself,
+in the order they're raised. Events of concurrent writes may be delivered in any order; Seq
+of an event gives the order in which it's been raised.
+- Each subscriber has a bounded buffer. Slow subscriber policy decides what's done once it's
+full: the event is dropped, the writer waits, or the subscriber is disconnected.
+- Nothing is queued while there's no subscriber.
+**************************************************************************** */
+package datacache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+
+const defaultWatchBufSize = 64
+
+// Cause of a change event.
+type EventCause int
+
+const (
+	EventInsert EventCause = iota + 1  // record added over keys which didn't refer to any record.
+	EventReplace                       // record added over existing keys, for instance, through ForceAddRec().
+	EventAlias                         // key aliased to the record through ReAddRec().
+	EventStateChange                   // record activated or deactivated through UpdateRecState().
+	EventUpdate                        // payload replaced in place through Update(), Upsert() or CompareAndSwap().
+	EventDelete                        // record or one of its keys deleted.
+	EventEvict                         // record evicted by the eviction policy.
+	EventExpire                        // expired record reclaimed.
+	EventClear                         // all records removed through DeleteCache().
+	EventReload                        // cache contents replaced by Reload().
+)
+
+var eventCauseNameList = []string {"", "insert", "replace", "alias", "state-change", "update", "delete", "evict",
+"expire", "clear", "reload"}
+
+
+func (cause EventCause) String() string {
+	if (cause < EventInsert) || (cause > EventReload) {
+		return "unknown"
+	}
+
+	return eventCauseNameList[cause]
+}
+
+
+// Change event.
+type Event struct {
+	Cause EventCause
+	KeyList []Key             // keys of the record after the change. nil for EventClear and EventReload.
+	                          // key removed for EventDelete raised by DeleteKey().
+	OldPayload interface{}    // payload before the change. nil for EventInsert and EventAlias.
+	NewPayload interface{}    // payload after the change. nil for EventDelete, EventEvict and EventExpire.
+	IsActive bool             // state of the record after the change.
+	Time time.Time
+	Seq uint64                // order in which the event is raised. changes of a key are raised in order.
+	                          // 0 for events passed to hooks.
+}
+
+// Decides whether a subscriber receives the event. nil filter receives every event.
+type EventFilter func(Event) bool
+
+// What's done once buffer of a subscriber is full.
+type WatchPolicy int
+
+const (
+	WatchDrop WatchPolicy = iota  // event is dropped for the subscriber. default.
+	WatchBlock                    // writer waits until the subscriber makes room or cancels.
+	WatchDisconnect               // subscriber's channel is closed and it receives no more events.
+)
+
+type watcher struct {
+	filter EventFilter
+	eventChan chan Event
+	doneChan chan struct{}  // closed once the subscriber is cancelled or disconnected.
+	isDone int32            // accessed atomically. set by whichever of cancel and disconnect comes first.
+}
+
+type pendingEvent struct {
+	goroutineID uint64         // go-routine which raised the event. only it delivers the event.
+	event Event
+}
+
+type watchHub struct {
+	lock sync.Mutex            // guards watcherList, pendingList and seq.
+	watcherList []*watcher
+	pendingList []pendingEvent // events raised but not yet delivered.
+	pendingCnt int32           // accessed atomically. len(pendingList).
+	seq uint64                 // sequence number of the last raised event.
+	watcherCnt int32           // accessed atomically. events aren't raised while it's 0.
+	dispatchLock sync.Mutex    // serializes delivery, so that events of a write are delivered in order.
+	bufSize int
+	policy WatchPolicy
+}
+
+
+func newWatchHub() *watchHub {
+	return &watchHub {
+		bufSize: defaultWatchBufSize,
+		policy: WatchDrop,
+	}
+}
+
+
+// Buffer size and slow subscriber policy of the subscribers of the cache. bufSize <= 0 means the default,
+// which is 64 events. Default policy is WatchDrop.
+// With WatchBlock, a subscriber mustn't wait for a record lock or a store-lock whilst its buffer is full.
+// It's a deadlock otherwise, as the writer may be waiting for the subscriber whilst holding the lock of a
+// record it's returned to its caller, for instance, by AddAndGetRec().
+func WithWatchPolicy(bufSize int, policy WatchPolicy) Option {
+	return func(pDataCache *DataCache) {
+		if bufSize > 0 {
+			pDataCache.pWatch.bufSize = bufSize
+		}
+		pDataCache.pWatch.policy = policy
+	}
+}
+
+
+/* ****************************************************************************
+Description :
+Subscribes to change events of the cache.
+
+Receiver    :
+pDataCache *DataCache: Instance of datacache.
+
+Implements  : NA
+
+Arguments   :
+1> filter EventFilter: Decides which events are received. nil receives every event.
+Runs in the go-routine which delivers the event; hence, it should be quick and mustn't
+invoke any method of the datacache.
+
+Return value:
+1> <-chan Event: Events. It's closed once cancel is invoked or the subscriber is disconnected
+by WatchDisconnect policy.
+2> func(): Cancels the subscription. It's safe to invoke it more than once.
+
+Additional note:
+- Events raised by WOLock methods are delivered once the caller releases the store-lock through
+WriteUnlock() or ReadUnlock().
+**************************************************************************** */
+func (pDataCache *DataCache) Watch(filter EventFilter) (<-chan Event, func()) {
+	if pDataCache == nil {
+		return nil, func() {}
+	}
+
+	pHub := pDataCache.pWatch
+	pWatcher := &watcher {
+		filter: filter,
+		eventChan: make(chan Event, pHub.bufSize),
+		doneChan: make(chan struct{}),
+	}
+
+	pHub.lock.Lock()
+	pHub.watcherList = append(pHub.watcherList, pWatcher)
+	atomic.AddInt32(&pHub.watcherCnt, 1)
+	pHub.lock.Unlock()
+
+	cancel := func() {
+		if atomic.CompareAndSwapInt32(&pWatcher.isDone, 0, 1) {
+			close(pWatcher.doneChan)  // delivery to the subscriber, if it's waiting, gives up.
+			pHub.dispatchLock.Lock()
+			pHub.detach(pWatcher)
+			pHub.dispatchLock.Unlock()
+		}
+	}
+
+	return pWatcher.eventChan, cancel
+}
+
+
+// Removes the subscriber and closes its channel. Must be invoked in dispatch lock, so that nothing is
+// delivered to the closed channel.
+func (pHub *watchHub) detach(pWatcher *watcher) {
+	pHub.lock.Lock()
+	for i, pTmpWatcher := range pHub.watcherList {
+		if pTmpWatcher == pWatcher {
+			pHub.watcherList = append(pHub.watcherList[:i:i], pHub.watcherList[i+1:]...)
+			if atomic.AddInt32(&pHub.watcherCnt, -1) == 0 {
+				pHub.pendingList = nil  // nobody's left to deliver them to.
+				atomic.StoreInt32(&pHub.pendingCnt, 0)
+			}
+			break
+		}
+	}
+	pHub.lock.Unlock()
+
+	close(pWatcher.eventChan)
+}
+
+
+// Reports whether anyone watches the cache. Callers check it before building an event.
+func (pDataCache *DataCache) isWatched() bool {
+	return (pDataCache.pWatch != nil) && (atomic.LoadInt32(&pDataCache.pWatch.watcherCnt) > 0)
+}
+
+
+// Queues an event for delivery by the calling go-routine. Invoked whilst the cache is mutated, in store-locks.
+func (pDataCache *DataCache) raiseEvent(cause EventCause, keyList []Key, pOldDataRec interface{}, pNewDataRec interface{},
+isActive bool) {
+	if !pDataCache.isWatched() {
+		return
+	}
+
+	pending := pendingEvent {
+		goroutineID: curGoroutineID(),
+		event: newEvent(cause, keyList, pOldDataRec, pNewDataRec, isActive),
+	}
+
+	pHub := pDataCache.pWatch
+	pHub.lock.Lock()
+	pHub.seq = pHub.seq + 1
+	pending.event.Seq = pHub.seq
+	pHub.pendingList = append(pHub.pendingList, pending)
+	atomic.AddInt32(&pHub.pendingCnt, 1)
+	pHub.lock.Unlock()
+}
+
+
+func newEvent(cause EventCause, keyList []Key, pOldDataRec interface{}, pNewDataRec interface{}, isActive bool) Event {
+	event := Event {
+		Cause: cause,
+		OldPayload: pOldDataRec,
+		NewPayload: pNewDataRec,
+		IsActive: isActive,
+		Time: time.Now(),
+	}
+	if keyList != nil {
+		event.KeyList = append([]Key(nil), keyList...)  // key list of the record changes later.
+	}
+
+	return event
+}
+
+
+// Runs hooks and delivers events raised by the calling go-routine. Events raised by other go-routines are left
+// for them, as they may still hold the locks taken by their write methods. Must be invoked once all store-locks
+// and record locks taken by the write method are released.
+func (pDataCache *DataCache) dispatchEvents() {
+	pDataCache.runHooks()
+
+	pHub := pDataCache.pWatch
+	if (pHub == nil) || (atomic.LoadInt32(&pHub.pendingCnt) == 0) {
+		return
+	}
+
+	id := curGoroutineID()
+	var eventList []Event
+	pHub.lock.Lock()
+	tmpList := pHub.pendingList[:0]
+	for _, pending := range pHub.pendingList {
+		if pending.goroutineID == id {
+			eventList = append(eventList, pending.event)
+		} else {
+			tmpList = append(tmpList, pending)
+		}
+	}
+	for i := len(tmpList); i < len(pHub.pendingList); i++ {
+		pHub.pendingList[i] = pendingEvent{}  // payloads aren't retained.
+	}
+	pHub.pendingList = tmpList
+	atomic.AddInt32(&pHub.pendingCnt, -int32(len(eventList)))
+	pHub.lock.Unlock()
+
+	if len(eventList) == 0 {
+		return
+	}
+
+	pHub.dispatchLock.Lock()
+	defer pHub.dispatchLock.Unlock()
+
+	pHub.lock.Lock()
+	watcherList := append([]*watcher(nil), pHub.watcherList...)
+	pHub.lock.Unlock()
+
+	for _, event := range eventList {
+		for _, pWatcher := range watcherList {
+			if (pWatcher.filter == nil) || pWatcher.filter(event) {
+				pHub.deliver(pWatcher, event)
+			}
+		}
+	}
+}
+
+
+// Delivers event to the subscriber as per the slow subscriber policy. Must be invoked in dispatch lock.
+func (pHub *watchHub) deliver(pWatcher *watcher, event Event) {
+	select {
+	case <-pWatcher.doneChan:
+		return
+	default:
+	}
+
+	switch pHub.policy {
+		case WatchBlock:
+			select {
+			case pWatcher.eventChan <- event:
+			case <-pWatcher.doneChan:
+			}
+
+		case WatchDisconnect:
+			select {
+			case pWatcher.eventChan <- event:
+			default:
+				if atomic.CompareAndSwapInt32(&pWatcher.isDone, 0, 1) {  // cancel detaches it otherwise.
+					close(pWatcher.doneChan)
+					pHub.detach(pWatcher)
+				}
+			}
+
+		default:
+			select {
+			case pWatcher.eventChan <- event:
+			default:
+			}
+	}
+}
@@ -0,0 +1,231 @@
+package datacache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+
+// Returns the events delivered so far.
+func drainEvents(eventChan <-chan Event) []Event {
+	var eventList []Event
+	for {
+		select {
+			case event, isOK := <-eventChan:
+				if !isOK {
+					return eventList
+				}
+				eventList = append(eventList, event)
+			default:
+				return eventList
+		}
+	}
+}
+
+
+// Events of a write are delivered, in order, before the write returns.
+func TestWatchEvents(t *testing.T) {
+	testList := []struct {
+		name string
+		write func(*DataCache)
+		causeList []EventCause
+		keyList []Key            // keys of the last event.
+	}{
+		{"insert", func(pDataCache *DataCache) {
+			pDataCache.AddRec([]Key{"new"}, "v", true)
+		}, []EventCause{EventInsert}, []Key{"new"}},
+		{"replace", func(pDataCache *DataCache) {
+			pDataCache.ForceAddRec([]Key{"k"}, "v2")
+		}, []EventCause{EventReplace}, []Key{"k"}},
+		{"alias", func(pDataCache *DataCache) {
+			pDataCache.ReAddRec("k", "alias2")
+		}, []EventCause{EventAlias}, []Key{"k", "alias", "alias2"}},
+		{"state change", func(pDataCache *DataCache) {
+			pDataCache.UpdateRecState("k", false)
+		}, []EventCause{EventStateChange}, []Key{"k", "alias"}},
+		{"update", func(pDataCache *DataCache) {
+			pDataCache.Update("k", func(interface{}) (interface{}, error) {
+				return "v2", nil
+			})
+		}, []EventCause{EventUpdate}, []Key{"k", "alias"}},
+		{"delete key", func(pDataCache *DataCache) {
+			pDataCache.DeleteKey("alias")
+		}, []EventCause{EventDelete}, []Key{"alias"}},
+		{"delete", func(pDataCache *DataCache) {
+			pDataCache.DeleteRec("k")
+		}, []EventCause{EventDelete}, []Key{"k", "alias"}},
+		{"clear", func(pDataCache *DataCache) {
+			pDataCache.DeleteCache()
+		}, []EventCause{EventClear}, nil},
+		{"WOLock writes", func(pDataCache *DataCache) {
+			pDataCache.WriteLock()
+			pDataCache.AddRecWOLock([]Key{"new"}, "v", true)
+			pDataCache.DeleteRecWOLock("k")
+			pDataCache.WriteUnlock()
+		}, []EventCause{EventInsert, EventDelete}, []Key{"k", "alias"}},
+		{"failed write", func(pDataCache *DataCache) {
+			pDataCache.AddRec([]Key{"k"}, "v", true)
+		}, nil, nil},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			pDataCache := Create(nil, nil, WithShards(8))
+			defer pDataCache.Close()
+			pDataCache.AddRec([]Key{"k", "alias"}, "v", true)
+
+			eventChan, cancel := pDataCache.Watch(nil)
+			defer cancel()
+			test.write(pDataCache)
+
+			eventList := drainEvents(eventChan)
+			causeList := make([]EventCause, len(eventList))
+			for i := range eventList {
+				causeList[i] = eventList[i].Cause
+			}
+			if (len(causeList) != len(test.causeList)) || ((len(causeList) > 0) && !reflect.DeepEqual(causeList, test.causeList)) {
+				t.Fatalf("causes %v, want %v", causeList, test.causeList)
+			}
+			if len(eventList) > 0 {
+				if keyList := eventList[len(eventList) - 1].KeyList; !reflect.DeepEqual(keyList, test.keyList) {
+					t.Fatalf("keys %#v, want %#v", keyList, test.keyList)
+				}
+			}
+		})
+	}
+}
+
+
+func TestWatchFilterAndCancel(t *testing.T) {
+	pDataCache := Create(nil, nil)
+	defer pDataCache.Close()
+
+	eventChan, cancel := pDataCache.Watch(func(event Event) bool {
+		return event.Cause == EventDelete
+	})
+	pDataCache.AddRec([]Key{"k"}, "v", true)
+	pDataCache.DeleteRec("k")
+
+	if eventList := drainEvents(eventChan); (len(eventList) != 1) || (eventList[0].OldPayload != "v") {
+		t.Fatalf("filtered events %+v", eventList)
+	}
+
+	cancel()
+	cancel()
+	if _, isOK := <-eventChan; isOK {
+		t.Fatalf("channel isn't closed on cancel")
+	}
+	if pDataCache.isWatched() {
+		t.Fatalf("cancelled subscriber is still watching")
+	}
+}
+
+
+func TestWatchPolicy(t *testing.T) {
+	testList := []struct {
+		name string
+		policy WatchPolicy
+		eventCnt int
+		isClosed bool
+	}{
+		{"drop", WatchDrop, 1, false},
+		{"disconnect", WatchDisconnect, 1, true},
+	}
+
+	for _, test := range testList {
+		t.Run(test.name, func(t *testing.T) {
+			pDataCache := Create(nil, nil, WithWatchPolicy(1, test.policy))
+			defer pDataCache.Close()
+
+			eventChan, cancel := pDataCache.Watch(nil)
+			defer cancel()
+			for i := 0; i < 3; i++ {
+				pDataCache.AddRec([]Key{i}, i, true)
+			}
+
+			eventCnt := len(drainEvents(eventChan))
+			isClosed := false
+			select {
+				case _, isOK := <-eventChan:
+					isClosed = !isOK
+				default:
+			}
+			if (eventCnt != test.eventCnt) || (isClosed != test.isClosed) {
+				t.Fatalf("%d events, closed %v; want %d, %v", eventCnt, isClosed, test.eventCnt, test.isClosed)
+			}
+		})
+	}
+}
+
+
+// With WatchBlock, the writer waits for a full subscriber and no event is lost.
+func TestWatchBlock(t *testing.T) {
+	pDataCache := Create(nil, nil, WithWatchPolicy(1, WatchBlock))
+	defer pDataCache.Close()
+
+	eventChan, cancel := pDataCache.Watch(nil)
+	defer cancel()
+
+	doneChan := make(chan struct{})
+	go func() {
+		defer close(doneChan)
+		for i := 0; i < 3; i++ {
+			pDataCache.AddRec([]Key{i}, i, true)
+		}
+	}()
+
+	select {
+		case <-doneChan:
+			t.Fatalf("writer didn't wait for the subscriber")
+		case <-time.After(20 * time.Millisecond):
+	}
+
+	for i := 0; i < 3; i++ {
+		if event := <-eventChan; event.NewPayload != i {
+			t.Fatalf("event %d has payload %v", i, event.NewPayload)
+		}
+	}
+	runWithTimeout(t, time.Second, func() {
+		<-doneChan
+	})
+}
+
+
+// Events are delivered by the go-routine which has raised them, once it's released its locks; never by
+// some other write which dispatches meanwhile.
+func TestWatchDispatchOwnEvents(t *testing.T) {
+	pDataCache := Create(nil, nil)
+	defer pDataCache.Close()
+
+	eventChan, cancel := pDataCache.Watch(nil)
+	defer cancel()
+
+	raisedChan := make(chan struct{})
+	dispatchChan := make(chan struct{})
+	doneChan := make(chan struct{})
+	go func() {
+		defer close(doneChan)
+		pDataCache.raiseEvent(EventInsert, []Key{"held"}, nil, "v", true)  // as if raised in a store-lock still held.
+		close(raisedChan)
+		<-dispatchChan
+		pDataCache.dispatchEvents()
+	}()
+
+	<-raisedChan
+	pDataCache.AddRec([]Key{"k"}, "v", true)
+	eventList := drainEvents(eventChan)
+	if (len(eventList) != 1) || !reflect.DeepEqual(eventList[0].KeyList, []Key{"k"}) {
+		t.Fatalf("events %+v delivered by AddRec(k), want its own only", eventList)
+	}
+
+	close(dispatchChan)
+	<-doneChan
+	eventList = append(eventList, drainEvents(eventChan)...)
+	if (len(eventList) != 2) || !reflect.DeepEqual(eventList[1].KeyList, []Key{"held"}) {
+		t.Fatalf("events %+v, want the held one delivered by its own go-routine", eventList)
+	}
+	if eventList[1].Seq >= eventList[0].Seq {
+		t.Fatalf("Seq %d of the held event isn't before Seq %d", eventList[1].Seq, eventList[0].Seq)
+	}
+}